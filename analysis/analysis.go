@@ -1,9 +1,12 @@
 package analysis
 
 import (
+	"fmt"
 	"log"
 	"math"
 	"math/cmplx"
+	"runtime"
+	"sync"
 
 	"github.com/steinarvk/abora/snippet"
 	"github.com/steinarvk/abora/stats"
@@ -29,6 +32,34 @@ type Params struct {
 	NumberOfFrequencyBuckets  int
 	PwelchPadding             *int
 	PerformPureFFT            bool
+
+	// FrequencyScale determines the spacing of the frequency buckets. With
+	// scales other than LinearScale, bucket values are divided by the
+	// bucket width so that wide buckets are comparable to narrow ones.
+	FrequencyScale FrequencyScale
+
+	// ReferenceA4 is the tuning of A4 in Hz for SemitoneScale.
+	ReferenceA4 float64
+
+	// Window is applied to each frame by the Pwelch, pure FFT and
+	// constant-Q analyses. (The reassigned spectrogram always uses Hann.)
+	Window WindowFunction
+
+	// Overlap, if non-zero, is the fraction by which consecutive analysis
+	// windows overlap, and overrides AnalysesPerSecond for spectral
+	// analyses.
+	Overlap float64
+
+	// Transform selects the spectral analysis used by Analyze.
+	Transform Transform
+
+	// QualityFactor is the ratio of frequency to bandwidth for
+	// ConstantQTransform.
+	QualityFactor float64
+
+	// Workers is the number of goroutines computing spectra in Analyze.
+	// The results do not depend on it. Zero means one per CPU.
+	Workers int
 }
 
 var (
@@ -47,6 +78,29 @@ var (
 	}
 )
 
+// CacheKey returns a string identifying the parameters, such that
+// analyses with equal keys give equal results.
+func (p *Params) CacheKey() string {
+	normalized := *p
+	normalizeParams(nil, &normalized)
+
+	return fmt.Sprintf("windowSeconds=%v loudnessWindow=%v rate=%v range=%v-%v buckets=%d padding=%d pureFFT=%v scale=%v a4=%v transform=%v q=%v windowFunction=%v overlap=%v",
+		normalized.MinWindowSizeSeconds,
+		normalized.LoudnessWindowSizeSeconds,
+		normalized.AnalysesPerSecond,
+		normalized.Range.LowHz,
+		normalized.Range.HighHz,
+		normalized.NumberOfFrequencyBuckets,
+		*normalized.PwelchPadding,
+		normalized.PerformPureFFT,
+		normalized.FrequencyScale,
+		normalized.ReferenceA4,
+		normalized.Transform,
+		normalized.QualityFactor,
+		normalized.Window,
+		normalized.Overlap)
+}
+
 type PureFFTPoint struct {
 	Raw       []complex128
 	Freqs     []float64
@@ -123,30 +177,36 @@ func (r FrequencyRange) Subdivide(n int) []FrequencyRange {
 	return rv
 }
 
-func onWindows(sz int, mod int, ch <-chan float64, f func(int64, []float64) error) error {
-	n := 10
-	bufsz := sz * n
-	buf := make([]float64, bufsz)
-	i := 0
-	smplno := int64(0)
-
-	for x := range ch {
-		if i >= bufsz {
-			copy(buf, buf[bufsz-sz:])
-			i = sz
-		}
-
-		buf[i] = x
-		smplno++
-		i++
-
-		if smplno >= int64(sz) {
-			if smplno%int64(mod) != 0 {
-				continue
+// readBlockSize is the number of samples onWindows reads from a snippet at
+// a time.
+const readBlockSize = 65536
+
+// onWindows calls f with each window of sz samples of s ending on a
+// multiple of mod, along with the number of samples up to its end. The
+// window is only valid during the call.
+func onWindows(sz int, mod int, s snippet.Snippet, f func(int64, []float64) error) error {
+	var buf []float64
+	base := 0
+	for _, end := range windowEnds(sz, mod, s.TotalSamples()) {
+		for base+len(buf) < int(end) {
+			// Only the current window need be kept of what has been read.
+			if begin := int(end) - sz; begin >= base+len(buf) {
+				buf = buf[:0]
+				base = begin
+			} else if begin > base {
+				buf = append(buf[:0], buf[begin-base:]...)
+				base = begin
 			}
-			if err := f(smplno, buf[i-sz:i]); err != nil {
-				return err
+
+			block := s.Slice(base+len(buf), readBlockSize)
+			if len(block) == 0 {
+				return fmt.Errorf("snippet ended at sample %d, before %d", base+len(buf), end)
 			}
+			buf = append(buf, block...)
+		}
+
+		if err := f(end, buf[int(end)-sz-base:int(end)-base]); err != nil {
+			return err
 		}
 	}
 
@@ -186,12 +246,29 @@ func normalizeParams(s snippet.Snippet, params *Params) error {
 		params.NumberOfFrequencyBuckets = defaultParams.NumberOfFrequencyBuckets
 	}
 
-	return nil
+	if params.Overlap < 0 || params.Overlap >= 1 {
+		return fmt.Errorf("overlap must be in [0, 1) (got %v)", params.Overlap)
+	}
+
+	if params.ReferenceA4 == 0 {
+		params.ReferenceA4 = defaultReferenceA4
+	}
+
+	if params.QualityFactor == 0 {
+		params.QualityFactor = defaultQualityFactor
+	}
+
+	if params.Workers == 0 {
+		params.Workers = runtime.NumCPU()
+	}
+
+	return params.FrequencyScale.CheckRange(*params.Range)
 }
 
 func (a *Analysis) pwelchOpts() *spectral.PwelchOptions {
 	rv := &spectral.PwelchOptions{
-		NFFT: a.WindowSize,
+		NFFT:   a.WindowSize,
+		Window: a.Params.Window.Coefficients,
 	}
 	if a.Params.PwelchPadding != nil {
 		rv.Pad = *a.Params.PwelchPadding
@@ -213,9 +290,10 @@ func (a *Analysis) newPoint(sampleNo int, pxx, freqs []float64) (*AnalysisPoint,
 		Values:      make([]float64, len(a.FrequencyBuckets)),
 	}
 	for i, bucket := range a.FrequencyBuckets {
-		value := bucket.density(point.RawPXX, point.RawFreqs)
-		point.Values[i] = value
-		a.ValueStats.Add(value)
+		point.Values[i] = bucket.density(point.RawPXX, point.RawFreqs)
+		if a.Params.FrequencyScale != LinearScale {
+			point.Values[i] /= bucket.HighHz - bucket.LowHz
+		}
 	}
 	return point, nil
 }
@@ -235,9 +313,15 @@ func (a *LoudnessAnalysis) addPoint(_ int64, frames []float64) error {
 	return nil
 }
 
-func calculatePureFFT(frames []float64, sampleRate int) *PureFFTPoint {
-	val := fft.FFTReal(frames)
+func calculatePureFFT(frames []float64, sampleRate int, windowFunction WindowFunction) *PureFFTPoint {
 	N := len(frames)
+	windowed := make([]float64, N)
+	gain := 0.0
+	for i, w := range windowFunction.Coefficients(N) {
+		windowed[i] = frames[i] * w
+		gain += w
+	}
+	val := fft.FFTReal(windowed)
 	rv := &PureFFTPoint{}
 	// Note FFTReal output is a "mirror image"; the last half contains no new information.
 	for k, x := range val[:len(val)/2] {
@@ -246,7 +330,7 @@ func calculatePureFFT(frames []float64, sampleRate int) *PureFFTPoint {
 		// which is 10 cycles per second
 		// which is 10 Hz
 		freq := float64(k) * float64(sampleRate) / float64(N)
-		amp := cmplx.Abs(x) / float64(N)
+		amp := cmplx.Abs(x) / gain
 		phase := cmplx.Phase(x)
 		rv.Raw = append(rv.Raw, x)
 		rv.Freqs = append(rv.Freqs, freq)
@@ -256,23 +340,106 @@ func calculatePureFFT(frames []float64, sampleRate int) *PureFFTPoint {
 	return rv
 }
 
-func (a *Analysis) addPoint(sampleNo int64, frames []float64) error {
+// computePoint analyses a single window. It does not modify the Analysis,
+// so it may be called concurrently.
+func (a *Analysis) computePoint(sampleNo int64, frames []float64) (*AnalysisPoint, error) {
 	//	log.Printf("performing spectral.Pwelch([...%d...], %v, %v)", len(frames), a.SampleRate, a.pwelchOpts())
 	pxx, freqs := spectral.Pwelch(frames, float64(a.SampleRate), a.pwelchOpts())
 	point, err := a.newPoint(int(sampleNo), pxx, freqs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if a.Params.PerformPureFFT {
-		point.PureFFT = calculatePureFFT(frames, a.SampleRate)
+		point.PureFFT = calculatePureFFT(frames, a.SampleRate, a.Params.Window)
 	}
 
+	return point, nil
+}
+
+func (a *Analysis) appendPoint(point *AnalysisPoint) {
+	for _, value := range point.Values {
+		a.ValueStats.Add(value)
+	}
 	a.Points = append(a.Points, point)
+}
+
+func (a *Analysis) addPoint(sampleNo int64, frames []float64) error {
+	point, err := a.computePoint(sampleNo, frames)
+	if err != nil {
+		return err
+	}
+
+	a.appendPoint(point)
+
+	return nil
+}
+
+// windowEnds lists the sample numbers at which onWindows would analyse a
+// snippet of n samples.
+func windowEnds(sz, mod, n int) []int64 {
+	var rv []int64
+	for smplno := mod; smplno <= n; smplno += mod {
+		if smplno >= sz {
+			rv = append(rv, int64(smplno))
+		}
+	}
+	return rv
+}
+
+// parallelFor calls f(i) for each i in [0,n) on the given number of
+// goroutines.
+func parallelFor(n, workers int, f func(i int)) {
+	indices := make(chan int, n)
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// analyzeFrames computes the points ending at each of the sample numbers
+// at which onWindows would analyse the snippet, on several goroutines,
+// and appends them in order.
+func (a *Analysis) analyzeFrames(s snippet.Snippet, workers int, compute func(end int64) (*AnalysisPoint, error)) error {
+	ends := windowEnds(a.WindowSize, a.FramesBetweenAnalyses, s.TotalSamples())
+	points := make([]*AnalysisPoint, len(ends))
+	errs := make([]error, len(ends))
+
+	parallelFor(len(ends), workers, func(i int) {
+		points[i], errs[i] = compute(ends[i])
+	})
+
+	for i, point := range points {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		a.appendPoint(point)
+	}
 
 	return nil
 }
 
+// analyzeParallel computes the same points as onWindows with addPoint,
+// reading the windows directly from the snippet.
+func (a *Analysis) analyzeParallel(s snippet.Snippet, workers int) error {
+	return a.analyzeFrames(s, workers, func(end int64) (*AnalysisPoint, error) {
+		frames := s.Slice(int(end)-a.WindowSize, a.WindowSize)
+		return a.computePoint(end, frames)
+	})
+}
+
 func AnalyzeLoudness(s snippet.Snippet, params *Params) (*LoudnessAnalysis, error) {
 	if params == nil {
 		params = &Params{}
@@ -290,13 +457,50 @@ func AnalyzeLoudness(s snippet.Snippet, params *Params) (*LoudnessAnalysis, erro
 	rv.WindowSize = int(float64(s.SampleRate()) * params.LoudnessWindowSizeSeconds)
 	rv.FramesBetweenAnalyses = int(float64(s.SampleRate()) / params.AnalysesPerSecond)
 
-	if err := onWindows(rv.WindowSize, rv.FramesBetweenAnalyses, snippet.Scan(s), rv.addPoint); err != nil {
+	if err := onWindows(rv.WindowSize, rv.FramesBetweenAnalyses, s, rv.addPoint); err != nil {
 		return nil, err
 	}
 
 	return rv, nil
 }
 
+// ValueAt returns the loudness value computed closest to the given sample.
+func (a *LoudnessAnalysis) ValueAt(sampleNo int) float64 {
+	mod := a.FramesBetweenAnalyses
+	first := ((a.WindowSize + mod - 1) / mod) * mod
+	i := (sampleNo - first + mod/2) / mod
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(a.Values) {
+		i = len(a.Values) - 1
+	}
+	return a.Values[i]
+}
+
+// frameLayout returns the size of the spectral analysis windows and the
+// number of samples between them.
+func (p *Params) frameLayout(sampleRate int) (windowSize, hop int) {
+	windowSize = nextPowerOfTwo(float64(sampleRate) * p.MinWindowSizeSeconds)
+	hop = int(float64(sampleRate) / p.AnalysesPerSecond)
+	if p.Overlap > 0 {
+		hop = int(float64(windowSize) * (1 - p.Overlap))
+	}
+	if hop < 1 {
+		hop = 1
+	}
+	return windowSize, hop
+}
+
+// EffectiveAnalysesPerSecond returns the number of spectral analysis
+// frames per second at a sample rate, taking Overlap into account.
+func (p *Params) EffectiveAnalysesPerSecond(sampleRate int) float64 {
+	normalized := *p
+	normalizeParams(nil, &normalized)
+	_, hop := normalized.frameLayout(sampleRate)
+	return float64(sampleRate) / float64(hop)
+}
+
 func Analyze(s snippet.Snippet, params *Params) (*Analysis, error) {
 	if params == nil {
 		params = &Params{}
@@ -307,17 +511,37 @@ func Analyze(s snippet.Snippet, params *Params) (*Analysis, error) {
 
 	rv := &Analysis{
 		Params:           params,
-		FrequencyBuckets: params.Range.Subdivide(params.NumberOfFrequencyBuckets),
+		FrequencyBuckets: params.Range.SubdivideScale(params.NumberOfFrequencyBuckets, params.FrequencyScale, params.ReferenceA4),
 		SampleRate:       s.SampleRate(),
 		ValueStats:       stats.New(),
 	}
 
-	rv.WindowSize = nextPowerOfTwo(float64(s.SampleRate()) * params.MinWindowSizeSeconds)
-	rv.FramesBetweenAnalyses = int(float64(s.SampleRate()) / params.AnalysesPerSecond)
+	rv.WindowSize, rv.FramesBetweenAnalyses = params.frameLayout(s.SampleRate())
 
 	log.Printf("window size %d everyNth %d opts %v", rv.WindowSize, rv.FramesBetweenAnalyses, rv.pwelchOpts())
 
-	if err := onWindows(rv.WindowSize, rv.FramesBetweenAnalyses, snippet.Scan(s), rv.addPoint); err != nil {
+	switch params.Transform {
+	case ConstantQTransform:
+		if err := rv.analyzeConstantQ(s); err != nil {
+			return nil, err
+		}
+		return rv, nil
+
+	case ReassignedTransform:
+		if err := rv.analyzeReassigned(s); err != nil {
+			return nil, err
+		}
+		return rv, nil
+	}
+
+	if params.Workers > 1 {
+		if err := rv.analyzeParallel(s, params.Workers); err != nil {
+			return nil, err
+		}
+		return rv, nil
+	}
+
+	if err := onWindows(rv.WindowSize, rv.FramesBetweenAnalyses, s, rv.addPoint); err != nil {
 		return nil, err
 	}
 
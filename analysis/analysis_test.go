@@ -3,6 +3,7 @@ package analysis
 import (
 	"log"
 	"math"
+	"reflect"
 	"testing"
 
 	"github.com/steinarvk/abora/snippet"
@@ -153,3 +154,135 @@ func TestAnalyzeTone192(t *testing.T) {
 		}
 	}
 }
+
+func TestParallelAnalysisIsIdentical(t *testing.T) {
+	snip := &testSnippet{tone: 1444.4, sampleRate: 44100, samples: 30000}
+
+	serial, err := Analyze(snip, &Params{Workers: 1, PerformPureFFT: true})
+	if err != nil {
+		t.Fatalf("serial analysis failed: %v", err)
+	}
+
+	parallel, err := Analyze(snip, &Params{Workers: 4, PerformPureFFT: true})
+	if err != nil {
+		t.Fatalf("parallel analysis failed: %v", err)
+	}
+
+	if len(serial.Points) == 0 || len(serial.Points) != len(parallel.Points) {
+		t.Fatalf("serial analysis has %d points, parallel has %d", len(serial.Points), len(parallel.Points))
+	}
+
+	for i := range serial.Points {
+		if !reflect.DeepEqual(serial.Points[i], parallel.Points[i]) {
+			t.Errorf("point #%d differs between serial and parallel analysis", i)
+		}
+	}
+
+	if serial.ValueStats.Median() != parallel.ValueStats.Median() {
+		t.Errorf("median differs: %v (serial) != %v (parallel)", serial.ValueStats.Median(), parallel.ValueStats.Median())
+	}
+}
+
+func TestOnWindows(t *testing.T) {
+	samples := make([]float64, 3*readBlockSize/2)
+	for i := range samples {
+		samples[i] = float64(i)
+	}
+	snip := snippet.New(44100, samples)
+
+	for _, c := range [][2]int{{4096, 441}, {5, 7}, {100, 100}} {
+		sz, mod := c[0], c[1]
+		var ends []int64
+		err := onWindows(sz, mod, snip, func(end int64, window []float64) error {
+			ends = append(ends, end)
+			if len(window) != sz || window[0] != float64(int(end)-sz) || window[sz-1] != float64(end-1) {
+				t.Fatalf("onWindows(%d, %d) gave window %v..%v for end %d", sz, mod, window[0], window[len(window)-1], end)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("onWindows(%d, %d) = %v", sz, mod, err)
+		}
+		if want := windowEnds(sz, mod, len(samples)); !reflect.DeepEqual(ends, want) {
+			t.Errorf("onWindows(%d, %d) visited %d windows, want %d", sz, mod, len(ends), len(want))
+		}
+	}
+}
+
+func TestConstantQTone(t *testing.T) {
+	tone := 1444.4
+	snip := &testSnippet{tone: tone, sampleRate: 44100, samples: 30000}
+
+	anal, err := Analyze(snip, &Params{Transform: ConstantQTransform})
+	if err != nil {
+		t.Fatalf("unable to analyze test snippet: %v", err)
+	}
+
+	if len(anal.Points) < 1 {
+		t.Fatalf("expected at least one point, got none")
+	}
+
+	for i, point := range anal.Points {
+		if len(point.Values) != len(anal.FrequencyBuckets) {
+			t.Fatalf("point %d has %d values, want %d", i, len(point.Values), len(anal.FrequencyBuckets))
+		}
+
+		heaviestBucket := 0
+		for j := range point.Values {
+			if point.Values[j] > point.Values[heaviestBucket] {
+				heaviestBucket = j
+			}
+		}
+
+		dist := math.Abs(tone - anal.FrequencyBuckets[heaviestBucket].Midpoint())
+		if dist > 20 {
+			t.Errorf("on %d: expected heaviest bucket close to %v but %v", i, tone, anal.FrequencyBuckets[heaviestBucket])
+		}
+	}
+}
+
+func TestReassignedToneIsSharp(t *testing.T) {
+	tone := 1444.4
+	snip := &testSnippet{tone: tone, sampleRate: 44100, samples: 30000}
+
+	anal, err := Analyze(snip, &Params{Transform: ReassignedTransform})
+	if err != nil {
+		t.Fatalf("unable to analyze test snippet: %v", err)
+	}
+
+	whichBucket := -1
+	for index, bucket := range anal.FrequencyBuckets {
+		if bucket.LowHz <= tone && tone < bucket.HighHz {
+			whichBucket = index
+		}
+	}
+	if whichBucket == -1 {
+		t.Fatalf("no bucket found matching tone %v", tone)
+	}
+
+	// Skip the edges, where the time reassignment has fewer frames to
+	// draw energy from.
+	points := anal.Points[2 : len(anal.Points)-2]
+	if len(points) < 1 {
+		t.Fatalf("expected some points, got %d", len(anal.Points))
+	}
+
+	for i, point := range points {
+		total, near := 0.0, 0.0
+		for j, x := range point.Values {
+			total += x
+			if j >= whichBucket-1 && j <= whichBucket+1 {
+				near += x
+			}
+		}
+		if near < 0.95*total {
+			t.Errorf("on %d: only %v of %v energy within a bucket of the tone", i, near, total)
+		}
+	}
+
+	// The default mapping should cope with mostly-zero values.
+	mapper := anal.DefaultValueMapper()
+	if v := mapper(anal.ValueStats.Max()); math.IsNaN(v) || v < 1 {
+		t.Errorf("mapped value of heaviest bucket = %v, want >= 1", v)
+	}
+}
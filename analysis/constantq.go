@@ -0,0 +1,176 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/steinarvk/abora/snippet"
+)
+
+// Transform selects the spectral analysis performed by Analyze.
+type Transform int
+
+const (
+	// PwelchTransform estimates the spectrum with Welch's method over a
+	// window of fixed size, giving the same resolution at all frequencies.
+	PwelchTransform Transform = iota
+
+	// ConstantQTransform analyses each bucket with a window inversely
+	// proportional to its frequency, so the resolution in Hz is finer at
+	// low frequencies and the resolution in time finer at high ones.
+	ConstantQTransform
+
+	// ReassignedTransform moves the energy of each spectral bin to its
+	// estimated instantaneous frequency and time, which concentrates
+	// sinusoids into thin ridges.
+	ReassignedTransform
+)
+
+var (
+	transformNames = map[Transform]string{
+		PwelchTransform:     "pwelch",
+		ConstantQTransform:  "constantq",
+		ReassignedTransform: "reassigned",
+	}
+)
+
+const (
+	// Resolves quarter-tones: 1/(2^(1/24)-1).
+	defaultQualityFactor = 34.13
+
+	minConstantQWindow        = 16
+	maxConstantQWindowSeconds = 1.0
+)
+
+func (t Transform) String() string {
+	if name, ok := transformNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Transform(%d)", int(t))
+}
+
+// ParseTransform parses the name of a transform: "pwelch", "constantq" or
+// "reassigned".
+func ParseTransform(name string) (Transform, error) {
+	for transform, transformName := range transformNames {
+		if name == transformName {
+			return transform, nil
+		}
+	}
+	return PwelchTransform, fmt.Errorf("unknown transform %q", name)
+}
+
+// constantQKernel is a windowed complex sinusoid at a bucket's
+// centre frequency, normalized so that a sine of amplitude A gives a
+// response of magnitude A/2.
+type constantQKernel struct {
+	freq     float64
+	re, im   []float64
+	halfSize int
+}
+
+func newConstantQKernel(freq, q float64, sampleRate int, windowFunction WindowFunction) *constantQKernel {
+	n := int(q * float64(sampleRate) / freq)
+	if max := int(maxConstantQWindowSeconds * float64(sampleRate)); n > max {
+		n = max
+	}
+	if n < minConstantQWindow {
+		n = minConstantQWindow
+	}
+
+	k := &constantQKernel{
+		freq:     freq,
+		re:       make([]float64, n),
+		im:       make([]float64, n),
+		halfSize: n / 2,
+	}
+
+	sum := 0.0
+	for i, w := range windowFunction.Coefficients(n) {
+		phase := 2 * math.Pi * freq * float64(i) / float64(sampleRate)
+		k.re[i] = w * math.Cos(phase)
+		k.im[i] = -w * math.Sin(phase)
+		sum += w
+	}
+	for i := range k.re {
+		k.re[i] /= sum
+		k.im[i] /= sum
+	}
+
+	return k
+}
+
+// power returns the squared magnitude of the kernel's response to a
+// window centred at the given index into xs.
+func (k *constantQKernel) power(xs []float64, centre int) float64 {
+	start := centre - k.halfSize
+	var re, im float64
+	for i := range k.re {
+		x := xs[start+i]
+		re += k.re[i] * x
+		im += k.im[i] * x
+	}
+	return re*re + im*im
+}
+
+// paddedSlice reads samples from a snippet, with zeros outside of it.
+func paddedSlice(s snippet.Snippet, start, n int) []float64 {
+	rv := make([]float64, n)
+	from, to := start, start+n
+	if from < 0 {
+		from = 0
+	}
+	if to > s.TotalSamples() {
+		to = s.TotalSamples()
+	}
+	if from < to {
+		copy(rv[from-start:], s.Slice(from, to-from))
+	}
+	return rv
+}
+
+// analyzeConstantQ computes a point per frame as Analyze does, using a
+// constant-Q transform centred on each Pwelch window. The value of each
+// bucket is the power at its centre frequency.
+func (a *Analysis) analyzeConstantQ(s snippet.Snippet) error {
+	kernels := make([]*constantQKernel, len(a.FrequencyBuckets))
+	maxHalfSize := 0
+	for i, bucket := range a.FrequencyBuckets {
+		centre := bucket.Midpoint()
+		if a.Params.FrequencyScale != LinearScale {
+			centre = math.Sqrt(bucket.LowHz * bucket.HighHz)
+		}
+		kernels[i] = newConstantQKernel(centre, a.Params.QualityFactor, a.SampleRate, a.Params.Window)
+		if kernels[i].halfSize > maxHalfSize {
+			maxHalfSize = kernels[i].halfSize
+		}
+	}
+
+	freqs := make([]float64, len(kernels))
+	for i, k := range kernels {
+		freqs[i] = k.freq
+	}
+
+	return a.analyzeFrames(s, a.Params.Workers, func(end int64) (*AnalysisPoint, error) {
+		centre := int(end) - a.WindowSize/2
+		// Enough context on either side for the longest kernel.
+		padding := maxHalfSize + 1
+		xs := paddedSlice(s, centre-padding, 2*padding)
+
+		point := &AnalysisPoint{
+			FrameNumber: int(end),
+			RawFreqs:    freqs,
+			Values:      make([]float64, len(kernels)),
+		}
+		for i, k := range kernels {
+			point.Values[i] = k.power(xs, padding)
+		}
+		point.RawPXX = point.Values
+
+		if a.Params.PerformPureFFT {
+			point.PureFFT = calculatePureFFT(s.Slice(int(end)-a.WindowSize, a.WindowSize), a.SampleRate, a.Params.Window)
+		}
+
+		return point, nil
+	})
+}
@@ -0,0 +1,399 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/steinarvk/abora/snippet"
+	"github.com/steinarvk/abora/stats"
+)
+
+// BoundaryKind distinguishes the beginning of a note from its end.
+type BoundaryKind int
+
+const (
+	Onset BoundaryKind = iota
+	Offset
+)
+
+// BoundaryCause is the feature of the signal that revealed a boundary.
+type BoundaryCause int
+
+const (
+	// LoudnessCause is a note starting after, or ending in, silence.
+	LoudnessCause BoundaryCause = iota
+
+	// SpectralFluxCause is a sudden change in the spectrum, such as a
+	// re-attack of the same note.
+	SpectralFluxCause
+
+	// PitchJumpCause is a jump in the fundamental frequency, such as a
+	// legato change of note.
+	PitchJumpCause
+)
+
+var (
+	boundaryKindNames = map[BoundaryKind]string{
+		Onset:  "onset",
+		Offset: "offset",
+	}
+
+	boundaryCauseNames = map[BoundaryCause]string{
+		LoudnessCause:     "loudness",
+		SpectralFluxCause: "flux",
+		PitchJumpCause:    "pitch",
+	}
+)
+
+func (k BoundaryKind) String() string {
+	if name, ok := boundaryKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("BoundaryKind(%d)", int(k))
+}
+
+func (c BoundaryCause) String() string {
+	if name, ok := boundaryCauseNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("BoundaryCause(%d)", int(c))
+}
+
+// NoteBoundary is the beginning or end of a note.
+type NoteBoundary struct {
+	// Time of the boundary, in seconds.
+	Time float64
+
+	Kind BoundaryKind
+
+	// Confidence is between 0 and 1.
+	Confidence float64
+
+	// Cause is the strongest of the cues that found the boundary.
+	Cause BoundaryCause
+}
+
+type boundariesByTime []NoteBoundary
+
+func (b boundariesByTime) Len() int           { return len(b) }
+func (b boundariesByTime) Less(i, j int) bool { return b[i].Time < b[j].Time }
+func (b boundariesByTime) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// OnsetParams configures DetectNotes. Zero values select defaults.
+type OnsetParams struct {
+	// Analysis configures the spectral and loudness analyses, and the
+	// range of fundamental frequencies for pitch tracking.
+	Analysis *Params
+
+	// SilenceThreshold is the loudness (relative to the near-maximum
+	// loudness) above which a note starts.
+	SilenceThreshold float64
+
+	// Hysteresis is the fraction of SilenceThreshold below which a
+	// sounding note ends.
+	Hysteresis float64
+
+	// FluxRatio is how much the spectral flux must exceed its local
+	// median for a re-attack to be detected.
+	FluxRatio float64
+
+	// FluxWindowSeconds is the size of the neighbourhood in which the
+	// local median of the spectral flux is taken.
+	FluxWindowSeconds float64
+
+	// PitchJumpSemitones is the smallest pitch change between frames
+	// that ends one note and begins another.
+	PitchJumpSemitones float64
+
+	// MinGapSeconds is the shortest time between two boundaries of the
+	// same kind; closer cues are merged.
+	MinGapSeconds float64
+}
+
+var (
+	defaultOnsetParams = OnsetParams{
+		SilenceThreshold:   0.1,
+		Hysteresis:         0.5,
+		FluxRatio:          1.5,
+		FluxWindowSeconds:  0.1,
+		PitchJumpSemitones: 1.5,
+		MinGapSeconds:      0.05,
+	}
+)
+
+func normalizeOnsetParams(params *OnsetParams) {
+	if params.Analysis == nil {
+		params.Analysis = &Params{}
+	}
+	if params.SilenceThreshold == 0 {
+		params.SilenceThreshold = defaultOnsetParams.SilenceThreshold
+	}
+	if params.Hysteresis == 0 {
+		params.Hysteresis = defaultOnsetParams.Hysteresis
+	}
+	if params.FluxRatio == 0 {
+		params.FluxRatio = defaultOnsetParams.FluxRatio
+	}
+	if params.FluxWindowSeconds == 0 {
+		params.FluxWindowSeconds = defaultOnsetParams.FluxWindowSeconds
+	}
+	if params.PitchJumpSemitones == 0 {
+		params.PitchJumpSemitones = defaultOnsetParams.PitchJumpSemitones
+	}
+	if params.MinGapSeconds == 0 {
+		params.MinGapSeconds = defaultOnsetParams.MinGapSeconds
+	}
+}
+
+// CacheKey returns a string that identifies the boundaries detected with
+// these parameters.
+func (p *OnsetParams) CacheKey() string {
+	normalized := *p
+	normalizeOnsetParams(&normalized)
+
+	return fmt.Sprintf("%s silence=%v hysteresis=%v flux=%v fluxWindow=%v jump=%v gap=%v",
+		normalized.Analysis.CacheKey(),
+		normalized.SilenceThreshold,
+		normalized.Hysteresis,
+		normalized.FluxRatio,
+		normalized.FluxWindowSeconds,
+		normalized.PitchJumpSemitones,
+		normalized.MinGapSeconds)
+}
+
+// ratioConfidence maps how far a value exceeds a threshold to [0, 1).
+func ratioConfidence(value, threshold float64) float64 {
+	if value <= threshold || value <= 0 {
+		return 0
+	}
+	return 1 - threshold/value
+}
+
+func maxInRange(xs []float64, from, to int) float64 {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(xs) {
+		to = len(xs)
+	}
+	rv := 0.0
+	for _, x := range xs[from:to] {
+		rv = math.Max(rv, x)
+	}
+	return rv
+}
+
+// spectralFlux measures the increase in (log-compressed) spectral energy
+// from each analysis point to the next.
+func spectralFlux(anal *Analysis) []float64 {
+	rv := make([]float64, len(anal.Points))
+
+	scale := anal.ValueStats.Quantile(0.99)
+	if scale <= 0 {
+		return rv
+	}
+	compress := func(x float64) float64 {
+		return math.Log1p(100 * x / scale)
+	}
+
+	for i := 1; i < len(anal.Points); i++ {
+		prev, cur := anal.Points[i-1].Values, anal.Points[i].Values
+		sum := 0.0
+		for j := range cur {
+			sum += math.Max(0, compress(cur[j])-compress(prev[j]))
+		}
+		rv[i] = sum / float64(len(cur))
+	}
+
+	return rv
+}
+
+// mergeBoundaries combines boundaries of the same kind that are closer
+// than minGap, treating them as independent evidence.
+func mergeBoundaries(cues []NoteBoundary, minGap float64) []NoteBoundary {
+	sort.Stable(boundariesByTime(cues))
+
+	var rv []NoteBoundary
+	for _, cue := range cues {
+		merged := false
+		for j := len(rv) - 1; j >= 0 && cue.Time-rv[j].Time < minGap; j-- {
+			if rv[j].Kind != cue.Kind {
+				continue
+			}
+			combined := rv[j]
+			if cue.Confidence > combined.Confidence {
+				combined = cue
+			}
+			combined.Confidence = 1 - (1-rv[j].Confidence)*(1-cue.Confidence)
+			rv[j] = combined
+			merged = true
+			break
+		}
+		if !merged {
+			rv = append(rv, cue)
+		}
+	}
+
+	sort.Stable(boundariesByTime(rv))
+	return rv
+}
+
+// DetectNotes finds the beginnings and ends of notes in a snippet. Notes
+// start and end at silences (with hysteresis on the loudness envelope),
+// and within a sounding stretch a peak in spectral flux or a jump in pitch
+// ends one note and starts the next. Times are relative to the start of
+// the snippet.
+func DetectNotes(s snippet.Snippet, params *OnsetParams) ([]NoteBoundary, error) {
+	if params == nil {
+		params = &OnsetParams{}
+	}
+	normalizeOnsetParams(params)
+
+	anal, err := Analyze(s, params.Analysis)
+	if err != nil {
+		return nil, err
+	}
+	if len(anal.Points) < 2 {
+		return nil, errors.New("snippet too short to detect notes")
+	}
+
+	// Only the flux and the timing of each point are needed from the
+	// spectra, which are large for long snippets; they are released before
+	// the other analyses run.
+	flux := spectralFlux(anal)
+	n := len(anal.Points)
+	centres := make([]int, n)
+	ends := make([]float64, n)
+	for i, point := range anal.Points {
+		centres[i] = point.FrameNumber - anal.WindowSize/2
+		ends[i] = float64(point.FrameNumber) / float64(anal.SampleRate)
+	}
+	sampleRate := anal.SampleRate
+	anal = nil
+
+	loud, err := AnalyzeLoudness(s, params.Analysis)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(loud.Values) == 0 {
+		return nil, errors.New("snippet too short to detect notes")
+	}
+
+	framesPerSecond := params.Analysis.EffectiveAnalysesPerSecond(s.SampleRate())
+
+	pitch, err := TrackPitch(s, &PitchParams{
+		Range:           params.Analysis.Range,
+		FramesPerSecond: framesPerSecond,
+		Workers:         params.Analysis.Workers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	times := make([]float64, n)
+	levels := make([]float64, n)
+	for i, centre := range centres {
+		times[i] = float64(centre) / float64(sampleRate)
+		levels[i] = loud.ValueAt(centre)
+	}
+
+	neighbourhood := int(params.FluxWindowSeconds * framesPerSecond)
+	if neighbourhood < 1 {
+		neighbourhood = 1
+	}
+
+	var cues []NoteBoundary
+	addBoundary := func(t, confidence float64, cause BoundaryCause) {
+		cues = append(cues,
+			NoteBoundary{Time: t, Kind: Offset, Confidence: confidence, Cause: cause},
+			NoteBoundary{Time: t, Kind: Onset, Confidence: confidence, Cause: cause})
+	}
+
+	// Loudness: a note sounds from when the level rises above the
+	// threshold until it falls below the lower hysteresis threshold.
+	nearMax := loud.ValueStats.Quantile(0.99)
+	onThreshold := params.SilenceThreshold * nearMax
+	offThreshold := params.Hysteresis * onThreshold
+
+	// settled[i] is whether frame i is well within a sounding stretch,
+	// where boundaries within notes are looked for.
+	settled := make([]bool, n)
+	sounding := false
+	soundingSince := 0.0
+	for i, level := range levels {
+		switch {
+		case !sounding && level >= onThreshold:
+			sounding = true
+			soundingSince = times[i]
+			peak := maxInRange(levels, i, i+neighbourhood)
+			cues = append(cues, NoteBoundary{Time: times[i], Kind: Onset, Confidence: ratioConfidence(peak, offThreshold), Cause: LoudnessCause})
+		case sounding && level < offThreshold:
+			sounding = false
+			peak := maxInRange(levels, i-neighbourhood, i)
+			cues = append(cues, NoteBoundary{Time: times[i], Kind: Offset, Confidence: ratioConfidence(peak, offThreshold), Cause: LoudnessCause})
+		}
+		settled[i] = sounding && times[i]-soundingSince >= params.MinGapSeconds
+	}
+
+	// Spectral flux: peaks above an adaptive threshold.
+	fluxStats := stats.New()
+	for _, x := range flux {
+		fluxStats.Add(x)
+	}
+	delta := 0.1 * fluxStats.Quantile(0.9)
+
+	for i := 1; i < n-1; i++ {
+		if !settled[i] || flux[i] < maxInRange(flux, i-neighbourhood, i+neighbourhood+1) {
+			continue
+		}
+
+		local := stats.New()
+		for j := i - neighbourhood; j <= i+neighbourhood; j++ {
+			if j >= 0 && j < n {
+				local.Add(flux[j])
+			}
+		}
+		threshold := params.FluxRatio*local.Median() + delta
+
+		// Because of the log compression, the flux peaks as soon as a new
+		// sound enters the window, i.e. at its end rather than its centre.
+		if confidence := ratioConfidence(flux[i], threshold); confidence > 0 {
+			addBoundary(ends[i], confidence, SpectralFluxCause)
+		}
+	}
+
+	// Pitch: jumps between voiced frames. The pitch tracker tends to pass
+	// through a brief unvoiced stretch at a change of note, so the gap is
+	// bridged.
+	settledAt := func(t float64) bool {
+		i := sort.SearchFloat64s(times, t)
+		if i == n {
+			i--
+		}
+		return settled[i]
+	}
+	var lastVoiced *PitchFrame
+	for i := range pitch.Frames {
+		cur := &pitch.Frames[i]
+		if !cur.Voiced {
+			continue
+		}
+		prev := lastVoiced
+		lastVoiced = cur
+
+		if prev == nil || cur.Time-prev.Time > params.MinGapSeconds || !settledAt(cur.Time) {
+			continue
+		}
+		jump := math.Abs(Semitones(cur.Frequency, prev.Frequency))
+		if jump <= params.PitchJumpSemitones {
+			continue
+		}
+		confidence := math.Min(1, jump/(2*params.PitchJumpSemitones)) * math.Min(prev.Confidence, cur.Confidence)
+		addBoundary((prev.Time+cur.Time)/2, confidence, PitchJumpCause)
+	}
+
+	return mergeBoundaries(cues, params.MinGapSeconds), nil
+}
@@ -0,0 +1,70 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+
+	"github.com/steinarvk/abora/snippet"
+)
+
+func TestDetectNotes(t *testing.T) {
+	const rate = 16000
+
+	// Two notes played legato, a silence, and a third note.
+	notes := []struct {
+		begin, end, freq float64
+	}{
+		{0.1, 0.4, 440},
+		{0.4, 0.7, 660},
+		{0.9, 1.2, 440},
+	}
+
+	xs := make([]float64, int(1.4*rate))
+	phase := 0.0
+	for i := range xs {
+		t := float64(i) / rate
+		for _, note := range notes {
+			if t >= note.begin && t < note.end {
+				phase += 2 * math.Pi * note.freq / rate
+				xs[i] = math.Sin(phase)
+			}
+		}
+	}
+
+	boundaries, err := DetectNotes(snippet.New(rate, xs), &OnsetParams{
+		Analysis: &Params{
+			Range: &FrequencyRange{LowHz: 200, HighHz: 2000},
+		},
+	})
+	if err != nil {
+		t.Fatalf("DetectNotes() = %v", err)
+	}
+
+	want := []struct {
+		time float64
+		kind BoundaryKind
+	}{
+		{0.1, Onset},
+		{0.4, Offset},
+		{0.4, Onset},
+		{0.7, Offset},
+		{0.9, Onset},
+		{1.2, Offset},
+	}
+
+	var confident []NoteBoundary
+	for _, b := range boundaries {
+		if b.Confidence >= 0.5 {
+			confident = append(confident, b)
+		}
+	}
+
+	if len(confident) != len(want) {
+		t.Fatalf("DetectNotes() = %+v, want boundaries %+v", boundaries, want)
+	}
+	for i, b := range confident {
+		if b.Kind != want[i].kind || math.Abs(b.Time-want[i].time) > 0.04 {
+			t.Errorf("boundary %d = %v at %.3fs (%v, confidence %.2f), want %v at %.3fs", i, b.Kind, b.Time, b.Cause, b.Confidence, want[i].kind, want[i].time)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+
+	"github.com/steinarvk/abora/snippet"
+)
+
+// PitchParams configures TrackPitch. Zero values select defaults.
+type PitchParams struct {
+	// Range of fundamental frequencies considered.
+	Range *FrequencyRange
+
+	// IntegrationWindowSeconds is the length over which each frame's
+	// periodicity is measured (in addition to the longest period).
+	IntegrationWindowSeconds float64
+
+	FramesPerSecond float64
+
+	// MaxSemitonesPerFrame is the typical pitch change between frames;
+	// larger jumps (such as octave errors) are penalized when choosing
+	// the most likely path through the candidates.
+	MaxSemitonesPerFrame float64
+
+	Workers int
+}
+
+var (
+	defaultPitchParams = PitchParams{
+		Range: &FrequencyRange{
+			LowHz:  500.0,
+			HighHz: 5000.0,
+		},
+		IntegrationWindowSeconds: 0.02,
+		FramesPerSecond:          100.0,
+		MaxSemitonesPerFrame:     1.0,
+	}
+)
+
+const (
+	// Prior over YIN thresholds, as in pYIN: a Beta(2, 18) distribution
+	// (with mean 0.1) discretized in steps of 0.01.
+	pitchThresholdSteps = 100
+	pitchBetaA          = 2.0
+	pitchBetaB          = 18.0
+
+	// Probability mass given to the global minimum when no dip falls
+	// below the threshold.
+	pitchAbsoluteMinimumWeight = 0.01
+
+	// Probability of switching between voiced and unvoiced per frame.
+	pitchVoicingSwitch = 0.01
+)
+
+// PitchFrame is the pitch estimate for a single frame.
+type PitchFrame struct {
+	// Time of the centre of the frame, in seconds.
+	Time float64
+
+	// Frequency is the estimated fundamental in Hz, or 0 if unvoiced.
+	Frequency float64
+
+	// VoicedProbability is the probability that the frame is pitched at
+	// all, regardless of the frequency.
+	VoicedProbability float64
+
+	// Confidence measures the periodicity at the chosen frequency: 1 for
+	// a perfectly periodic signal, 0 for none.
+	Confidence float64
+
+	Voiced bool
+}
+
+// PitchTrack is a fundamental frequency track.
+type PitchTrack struct {
+	Frames []PitchFrame
+}
+
+// CacheKey returns a string that identifies the pitch track computed with
+// these parameters.
+func (p *PitchParams) CacheKey() string {
+	normalized := *p
+	normalizePitchParams(&normalized)
+
+	return fmt.Sprintf("range=%v-%v integration=%v rate=%v jump=%v",
+		normalized.Range.LowHz,
+		normalized.Range.HighHz,
+		normalized.IntegrationWindowSeconds,
+		normalized.FramesPerSecond,
+		normalized.MaxSemitonesPerFrame)
+}
+
+// pitchCandidate is a dip in the YIN difference function.
+type pitchCandidate struct {
+	freq        float64
+	probability float64
+	aperiodic   float64
+}
+
+func normalizePitchParams(params *PitchParams) {
+	if params.Range == nil {
+		params.Range = defaultPitchParams.Range
+	}
+	if params.IntegrationWindowSeconds == 0 {
+		params.IntegrationWindowSeconds = defaultPitchParams.IntegrationWindowSeconds
+	}
+	if params.FramesPerSecond == 0 {
+		params.FramesPerSecond = defaultPitchParams.FramesPerSecond
+	}
+	if params.MaxSemitonesPerFrame == 0 {
+		params.MaxSemitonesPerFrame = defaultPitchParams.MaxSemitonesPerFrame
+	}
+	if params.Workers == 0 {
+		params.Workers = runtime.NumCPU()
+	}
+}
+
+func thresholdPrior() (thresholds, weights []float64) {
+	total := 0.0
+	for i := 1; i <= pitchThresholdSteps; i++ {
+		s := float64(i) / pitchThresholdSteps
+		w := math.Pow(s, pitchBetaA-1) * math.Pow(1-s, pitchBetaB-1)
+		thresholds = append(thresholds, s)
+		weights = append(weights, w)
+		total += w
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+	return thresholds, weights
+}
+
+// cumulativeMeanNormalizedDifference computes YIN's d'(tau) for tau in
+// [0, maxLag].
+func cumulativeMeanNormalizedDifference(xs []float64, window, maxLag int) []float64 {
+	d := make([]float64, maxLag+1)
+	for tau := 1; tau <= maxLag; tau++ {
+		sum := 0.0
+		for j := 0; j < window; j++ {
+			delta := xs[j] - xs[j+tau]
+			sum += delta * delta
+		}
+		d[tau] = sum
+	}
+
+	d[0] = 1
+	running := 0.0
+	for tau := 1; tau <= maxLag; tau++ {
+		running += d[tau]
+		if running > 0 {
+			d[tau] *= float64(tau) / running
+		} else {
+			d[tau] = 1
+		}
+	}
+	return d
+}
+
+// parabolicMinimum refines the position and value of a minimum at i.
+func parabolicMinimum(d []float64, i int) (float64, float64) {
+	if i <= 0 || i >= len(d)-1 {
+		return float64(i), d[i]
+	}
+	a, b, c := d[i-1], d[i], d[i+1]
+	denom := a - 2*b + c
+	if denom <= 0 {
+		return float64(i), b
+	}
+	offset := 0.5 * (a - c) / denom
+	return float64(i) + offset, b - 0.25*(a-c)*offset
+}
+
+// yinCandidates finds the dips of d' between the lags, and assigns them
+// probabilities by integrating over the threshold prior. For each
+// threshold, the dip chosen is the first (shortest period) below it; this
+// prefers the true fundamental over its subharmonics.
+func yinCandidates(d []float64, minLag, maxLag, sampleRate int, thresholds, weights []float64) []pitchCandidate {
+	var dips []int
+	for tau := minLag; tau <= maxLag; tau++ {
+		if tau > 0 && tau < len(d)-1 && d[tau] < d[tau-1] && d[tau] <= d[tau+1] {
+			dips = append(dips, tau)
+		}
+	}
+	if len(dips) == 0 {
+		return nil
+	}
+
+	globalMin := 0
+	for i, tau := range dips {
+		if d[tau] < d[dips[globalMin]] {
+			globalMin = i
+		}
+	}
+
+	probabilities := make([]float64, len(dips))
+	for k, threshold := range thresholds {
+		chosen := -1
+		for i, tau := range dips {
+			if d[tau] < threshold {
+				chosen = i
+				break
+			}
+		}
+		if chosen >= 0 {
+			probabilities[chosen] += weights[k]
+		} else {
+			probabilities[globalMin] += weights[k] * pitchAbsoluteMinimumWeight
+		}
+	}
+
+	var rv []pitchCandidate
+	for i, tau := range dips {
+		if probabilities[i] == 0 {
+			continue
+		}
+		lag, value := parabolicMinimum(d, tau)
+		rv = append(rv, pitchCandidate{
+			freq:        float64(sampleRate) / lag,
+			probability: probabilities[i],
+			aperiodic:   value,
+		})
+	}
+	return rv
+}
+
+// TrackPitch estimates the fundamental frequency of a snippet over time
+// with the probabilistic YIN (pYIN) method: each frame yields candidate
+// frequencies with probabilities, and a Viterbi search picks the most
+// likely smooth path through them, including unvoiced stretches.
+func TrackPitch(s snippet.Snippet, params *PitchParams) (*PitchTrack, error) {
+	if params == nil {
+		params = &PitchParams{}
+	}
+	normalizePitchParams(params)
+
+	if params.Range.LowHz <= 0 || params.Range.HighHz <= params.Range.LowHz {
+		return nil, fmt.Errorf("invalid pitch range %v-%v Hz", params.Range.LowHz, params.Range.HighHz)
+	}
+
+	sampleRate := s.SampleRate()
+	maxLag := int(math.Ceil(float64(sampleRate)/params.Range.LowHz)) + 1
+	minLag := int(math.Floor(float64(sampleRate) / params.Range.HighHz))
+	if minLag < 2 {
+		minLag = 2
+	}
+	window := int(params.IntegrationWindowSeconds * float64(sampleRate))
+	if window < maxLag {
+		window = maxLag
+	}
+	frameSize := window + maxLag + 1
+	hop := int(float64(sampleRate) / params.FramesPerSecond)
+	if hop < 1 {
+		hop = 1
+	}
+
+	var starts []int
+	for start := 0; start+frameSize <= s.TotalSamples(); start += hop {
+		starts = append(starts, start)
+	}
+
+	thresholds, weights := thresholdPrior()
+
+	candidates := make([][]pitchCandidate, len(starts))
+	parallelFor(len(starts), params.Workers, func(i int) {
+		xs := s.Slice(starts[i], frameSize)
+		d := cumulativeMeanNormalizedDifference(xs, window, maxLag)
+		all := yinCandidates(d, minLag, maxLag, sampleRate, thresholds, weights)
+		for _, c := range all {
+			if c.freq >= params.Range.LowHz && c.freq <= params.Range.HighHz {
+				candidates[i] = append(candidates[i], c)
+			}
+		}
+	})
+
+	path := viterbiPitch(candidates, params.MaxSemitonesPerFrame)
+
+	rv := &PitchTrack{}
+	for i, start := range starts {
+		frame := PitchFrame{
+			Time: float64(start+window/2) / float64(sampleRate),
+		}
+		for _, c := range candidates[i] {
+			frame.VoicedProbability += c.probability
+		}
+		frame.VoicedProbability = math.Min(frame.VoicedProbability, 1)
+
+		if choice := path[i]; choice >= 0 {
+			c := candidates[i][choice]
+			frame.Voiced = true
+			frame.Frequency = c.freq
+			frame.Confidence = math.Max(0, math.Min(1, 1-c.aperiodic))
+		}
+
+		rv.Frames = append(rv.Frames, frame)
+	}
+
+	return rv, nil
+}
+
+// viterbiPitch finds the most likely sequence of states, where the state
+// of each frame is the index of a candidate or -1 for unvoiced.
+func viterbiPitch(candidates [][]pitchCandidate, sigmaSemitones float64) []int {
+	const epsilon = 1e-9
+
+	n := len(candidates)
+	if n == 0 {
+		return nil
+	}
+
+	type state struct {
+		score float64
+		prev  int
+	}
+
+	// Column j of frame i: j < len(candidates[i]) is a candidate, the
+	// last column is the unvoiced state.
+	trellis := make([][]state, n)
+
+	emissions := func(i int) []float64 {
+		total := 0.0
+		rv := make([]float64, len(candidates[i])+1)
+		for j, c := range candidates[i] {
+			rv[j] = math.Log(c.probability + epsilon)
+			total += c.probability
+		}
+		rv[len(candidates[i])] = math.Log(math.Max(1-total, 0) + epsilon)
+		return rv
+	}
+
+	stay := math.Log(1 - pitchVoicingSwitch)
+	change := math.Log(pitchVoicingSwitch)
+
+	first := emissions(0)
+	trellis[0] = make([]state, len(first))
+	for j, e := range first {
+		trellis[0][j] = state{score: e, prev: -1}
+	}
+
+	for i := 1; i < n; i++ {
+		emission := emissions(i)
+		prevUnvoiced := len(candidates[i-1])
+		trellis[i] = make([]state, len(emission))
+
+		for j := range emission {
+			best := state{score: math.Inf(-1), prev: -1}
+			for k, prev := range trellis[i-1] {
+				var transition float64
+				switch {
+				case j == len(candidates[i]) && k == prevUnvoiced:
+					transition = stay
+				case j == len(candidates[i]) || k == prevUnvoiced:
+					transition = change
+				default:
+					jump := Semitones(candidates[i][j].freq, candidates[i-1][k].freq) / sigmaSemitones
+					transition = stay - 0.5*jump*jump
+				}
+				if score := prev.score + transition; score > best.score {
+					best = state{score: score, prev: k}
+				}
+			}
+			best.score += emission[j]
+			trellis[i][j] = best
+		}
+	}
+
+	path := make([]int, n)
+	best := 0
+	for j, st := range trellis[n-1] {
+		if st.score > trellis[n-1][best].score {
+			best = j
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		path[i] = best
+		if best == len(candidates[i]) {
+			path[i] = -1
+		}
+		best = trellis[i][best].prev
+	}
+
+	return path
+}
+
+// Offset shifts the times of the track, e.g. when it was computed for a
+// subsnippet.
+func (t *PitchTrack) Offset(seconds float64) {
+	for i := range t.Frames {
+		t.Frames[i].Time += seconds
+	}
+}
+
+// WriteJSON writes the track as a JSON array of frames.
+func (t *PitchTrack) WriteJSON(w io.Writer) error {
+	frames := t.Frames
+	if frames == nil {
+		frames = []PitchFrame{}
+	}
+	return json.NewEncoder(w).Encode(frames)
+}
+
+// WriteCSV writes the track as CSV with a header row.
+func (t *PitchTrack) WriteCSV(w io.Writer) error {
+	wr := csv.NewWriter(w)
+	if err := wr.Write([]string{"time", "frequency", "voiced_probability", "confidence", "voiced"}); err != nil {
+		return err
+	}
+	for _, f := range t.Frames {
+		voiced := "0"
+		if f.Voiced {
+			voiced = "1"
+		}
+		record := []string{
+			fmt.Sprintf("%.6f", f.Time),
+			fmt.Sprintf("%.3f", f.Frequency),
+			fmt.Sprintf("%.4f", f.VoicedProbability),
+			fmt.Sprintf("%.4f", f.Confidence),
+			voiced,
+		}
+		if err := wr.Write(record); err != nil {
+			return err
+		}
+	}
+	wr.Flush()
+	return wr.Error()
+}
@@ -0,0 +1,53 @@
+package analysis
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/steinarvk/abora/snippet"
+)
+
+func TestTrackPitch(t *testing.T) {
+	const rate = 16000
+
+	// A tone with a strong second harmonic, followed by silence.
+	var xs []float64
+	for i := 0; i < rate/2; i++ {
+		t := float64(i) / rate
+		xs = append(xs, math.Sin(2*math.Pi*440*t)+0.8*math.Sin(2*math.Pi*880*t))
+	}
+	xs = append(xs, make([]float64, rate/2)...)
+
+	track, err := TrackPitch(snippet.New(rate, xs), &PitchParams{
+		Range: &FrequencyRange{LowHz: 100, HighHz: 2000},
+	})
+	if err != nil {
+		t.Fatalf("TrackPitch() = %v", err)
+	}
+
+	for _, frame := range track.Frames {
+		switch {
+		case frame.Time > 0.05 && frame.Time < 0.45:
+			if !frame.Voiced || math.Abs(frame.Frequency-440) > 1 {
+				t.Errorf("frame at %.2fs: voiced=%v frequency=%v, want 440 Hz", frame.Time, frame.Voiced, frame.Frequency)
+			}
+			if frame.Confidence < 0.9 {
+				t.Errorf("frame at %.2fs: confidence %v", frame.Time, frame.Confidence)
+			}
+		case frame.Time > 0.55:
+			if frame.Voiced {
+				t.Errorf("frame at %.2fs: silence is voiced at %v Hz", frame.Time, frame.Frequency)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := track.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(track.Frames)+1 {
+		t.Errorf("CSV has %d lines, want %d", lines, len(track.Frames)+1)
+	}
+}
@@ -0,0 +1,147 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+
+	"github.com/steinarvk/abora/snippet"
+
+	"github.com/mjibson/go-dsp/fft"
+)
+
+const (
+	// Bins weaker than this relative to the strongest bin of their frame
+	// have too little energy for reliable estimates, and are dropped.
+	reassignmentFloor = 1e-10
+)
+
+// reassignmentWindows returns a Hann window of size n, its derivative,
+// and the window multiplied by time relative to its centre (in samples).
+func reassignmentWindows(n int) (h, dh, th []float64) {
+	h = make([]float64, n)
+	dh = make([]float64, n)
+	th = make([]float64, n)
+	centre := float64(n-1) / 2
+	for i := 0; i < n; i++ {
+		phase := 2 * math.Pi * float64(i) / float64(n)
+		h[i] = 0.5 - 0.5*math.Cos(phase)
+		dh[i] = math.Pi / float64(n) * math.Sin(phase)
+		th[i] = (float64(i) - centre) * h[i]
+	}
+	return h, dh, th
+}
+
+func windowed(xs, w []float64) []float64 {
+	rv := make([]float64, len(xs))
+	for i := range xs {
+		rv[i] = xs[i] * w[i]
+	}
+	return rv
+}
+
+// reassignedBin is the energy of one spectral bin, relocated to an
+// estimated time (in samples) and frequency (in Hz).
+type reassignedBin struct {
+	time   float64
+	freq   float64
+	energy float64
+}
+
+// analyzeReassigned computes a reassigned spectrogram on the same frames
+// as the Pwelch analysis. Each frame's energy is scattered into the frame
+// and bucket nearest to its reassigned time and frequency, so values are
+// energies rather than densities.
+func (a *Analysis) analyzeReassigned(s snippet.Snippet) error {
+	n := a.WindowSize
+	h, dh, th := reassignmentWindows(n)
+
+	norm := 0.0
+	for _, w := range h {
+		norm += w
+	}
+	norm *= norm
+
+	ends := windowEnds(n, a.FramesBetweenAnalyses, s.TotalSamples())
+	bins := make([][]reassignedBin, len(ends))
+
+	parallelFor(len(ends), a.Params.Workers, func(i int) {
+		xs := s.Slice(int(ends[i])-n, n)
+		centre := float64(ends[i]) - float64(n) + float64(n-1)/2
+
+		xh := fft.FFTReal(windowed(xs, h))
+		xdh := fft.FFTReal(windowed(xs, dh))
+		xth := fft.FFTReal(windowed(xs, th))
+
+		maxEnergy := 0.0
+		for k := 0; k <= n/2; k++ {
+			if e := real(xh[k])*real(xh[k]) + imag(xh[k])*imag(xh[k]); e > maxEnergy {
+				maxEnergy = e
+			}
+		}
+
+		for k := 0; k <= n/2; k++ {
+			energy := real(xh[k])*real(xh[k]) + imag(xh[k])*imag(xh[k])
+			if energy == 0 || energy < maxEnergy*reassignmentFloor {
+				continue
+			}
+
+			binFreq := float64(k) * float64(a.SampleRate) / float64(n)
+			freq := binFreq - imag(xdh[k]/xh[k])*float64(a.SampleRate)/(2*math.Pi)
+			time := centre + real(xth[k]/xh[k])
+
+			bins[i] = append(bins[i], reassignedBin{
+				time:   time,
+				freq:   freq,
+				energy: energy / norm,
+			})
+		}
+	})
+
+	points := make([]*AnalysisPoint, len(ends))
+	freqs := make([]float64, len(a.FrequencyBuckets))
+	for j, bucket := range a.FrequencyBuckets {
+		freqs[j] = bucket.Midpoint()
+	}
+	for i, end := range ends {
+		points[i] = &AnalysisPoint{
+			FrameNumber: int(end),
+			RawFreqs:    freqs,
+			Values:      make([]float64, len(a.FrequencyBuckets)),
+		}
+		points[i].RawPXX = points[i].Values
+	}
+
+	if len(ends) == 0 {
+		return nil
+	}
+
+	firstCentre := float64(ends[0]) - float64(n) + float64(n-1)/2
+	hop := float64(a.FramesBetweenAnalyses)
+
+	for _, frameBins := range bins {
+		for _, bin := range frameBins {
+			column := int(math.Floor((bin.time-firstCentre)/hop + 0.5))
+			if column < 0 || column >= len(points) {
+				continue
+			}
+
+			bucket := sort.Search(len(a.FrequencyBuckets), func(j int) bool {
+				return a.FrequencyBuckets[j].HighHz > bin.freq
+			})
+			if bucket >= len(a.FrequencyBuckets) || bin.freq < a.FrequencyBuckets[bucket].LowHz {
+				continue
+			}
+
+			points[column].Values[bucket] += bin.energy
+		}
+	}
+
+	for i, point := range points {
+		if a.Params.PerformPureFFT {
+			point.PureFFT = calculatePureFFT(s.Slice(int(ends[i])-n, n), a.SampleRate, a.Params.Window)
+		}
+		a.appendPoint(point)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+)
+
+// FrequencyScale determines how frequency buckets are spaced.
+type FrequencyScale int
+
+const (
+	// LinearScale gives buckets of equal width in Hz.
+	LinearScale FrequencyScale = iota
+
+	// LogScale gives buckets of equal width in octaves.
+	LogScale
+
+	// MelScale gives buckets of equal width in mels.
+	MelScale
+
+	// SemitoneScale is a log scale whose range is widened to the band
+	// edges of the notes (tuned relative to the A4 reference) at either
+	// end. The buckets line up with the notes when their number is a
+	// multiple of the number of notes covered.
+	SemitoneScale
+)
+
+var (
+	frequencyScaleNames = map[FrequencyScale]string{
+		LinearScale:   "linear",
+		LogScale:      "log",
+		MelScale:      "mel",
+		SemitoneScale: "semitone",
+	}
+)
+
+const (
+	defaultReferenceA4 = 440.0
+)
+
+func (s FrequencyScale) String() string {
+	if name, ok := frequencyScaleNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("FrequencyScale(%d)", int(s))
+}
+
+// ParseFrequencyScale parses the name of a frequency scale: "linear",
+// "log", "mel" or "semitone".
+func ParseFrequencyScale(name string) (FrequencyScale, error) {
+	for scale, scaleName := range frequencyScaleNames {
+		if name == scaleName {
+			return scale, nil
+		}
+	}
+	return LinearScale, fmt.Errorf("unknown frequency scale %q", name)
+}
+
+// CheckRange returns an error if the scale cannot cover the range. Scales
+// other than the linear one cannot reach down to zero.
+func (s FrequencyScale) CheckRange(r FrequencyRange) error {
+	if s != LinearScale && r.LowHz <= 0 {
+		return fmt.Errorf("%v scale needs a positive lowest frequency (got %v Hz)", s, r.LowHz)
+	}
+	return nil
+}
+
+// warp maps a frequency to a coordinate in which the scale's buckets are
+// equally wide.
+func (s FrequencyScale) warp(hz float64) float64 {
+	switch s {
+	case LogScale, SemitoneScale:
+		return math.Log(hz)
+	case MelScale:
+		return 2595 * math.Log10(1+hz/700)
+	default:
+		return hz
+	}
+}
+
+func (s FrequencyScale) unwarp(x float64) float64 {
+	switch s {
+	case LogScale, SemitoneScale:
+		return math.Exp(x)
+	case MelScale:
+		return 700 * (math.Pow(10, x/2595) - 1)
+	default:
+		return x
+	}
+}
+
+// Semitones returns the (fractional) number of semitones from A4 to the
+// given frequency.
+func Semitones(hz, referenceA4 float64) float64 {
+	return 12 * math.Log2(hz/referenceA4)
+}
+
+// SemitoneFrequency returns the frequency a number of semitones from A4.
+func SemitoneFrequency(semitones, referenceA4 float64) float64 {
+	return referenceA4 * math.Pow(2, semitones/12)
+}
+
+// Align returns the range actually covered by the scale: for the semitone
+// scale, the range widened to the band edges of the notes at either end;
+// otherwise the range unchanged.
+func (r FrequencyRange) Align(scale FrequencyScale, referenceA4 float64) FrequencyRange {
+	if scale != SemitoneScale {
+		return r
+	}
+	low := math.Floor(Semitones(r.LowHz, referenceA4)+0.5) - 0.5
+	high := math.Ceil(Semitones(r.HighHz, referenceA4)-0.5) + 0.5
+	return FrequencyRange{
+		LowHz:  SemitoneFrequency(low, referenceA4),
+		HighHz: SemitoneFrequency(high, referenceA4),
+	}
+}
+
+// SubdivideScale divides the range into n buckets equally wide on the
+// given scale. Unlike Subdivide, the range is first aligned to the scale,
+// so for the semitone scale each bucket spans a whole number of notes, or
+// a note a whole number of buckets, only if n is divisible accordingly.
+func (r FrequencyRange) SubdivideScale(n int, scale FrequencyScale, referenceA4 float64) []FrequencyRange {
+	if scale == LinearScale {
+		return r.Subdivide(n)
+	}
+
+	r = r.Align(scale, referenceA4)
+
+	low := scale.warp(r.LowHz)
+	span := scale.warp(r.HighHz) - low
+
+	var rv []FrequencyRange
+	for i := 0; i < n; i++ {
+		rv = append(rv, FrequencyRange{
+			LowHz:  scale.unwarp(low + span*float64(i)/float64(n)),
+			HighHz: scale.unwarp(low + span*float64(i+1)/float64(n)),
+		})
+	}
+
+	// Avoid rounding errors at the edges.
+	rv[0].LowHz = r.LowHz
+	rv[n-1].HighHz = r.HighHz
+
+	return rv
+}
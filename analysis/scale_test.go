@@ -0,0 +1,50 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSubdivideScale(t *testing.T) {
+	r := FrequencyRange{LowHz: 500, HighHz: 5000}
+
+	logBuckets := r.SubdivideScale(10, LogScale, 440)
+	for i, bucket := range logBuckets {
+		if ratio := bucket.HighHz / bucket.LowHz; math.Abs(ratio-math.Pow(10, 0.1)) > 1e-9 {
+			t.Errorf("log bucket #%d %v has ratio %v", i, bucket, ratio)
+		}
+	}
+	if logBuckets[0].LowHz != 500 || logBuckets[9].HighHz != 5000 {
+		t.Errorf("log buckets cover %v-%v, want 500-5000", logBuckets[0].LowHz, logBuckets[9].HighHz)
+	}
+
+	melBuckets := r.SubdivideScale(4, MelScale, 440)
+	if w0, w3 := melBuckets[0].HighHz-melBuckets[0].LowHz, melBuckets[3].HighHz-melBuckets[3].LowHz; w3 <= w0 {
+		t.Errorf("mel buckets should widen with frequency, got widths %v and %v", w0, w3)
+	}
+
+	// 500-5000 Hz spans from B4 (~494 Hz) to D#8 (~4978 Hz) with A4=440, so
+	// the aligned range is 41 semitones wide.
+	semitoneBuckets := r.SubdivideScale(41, SemitoneScale, 440)
+	for i, bucket := range semitoneBuckets {
+		centre := Semitones(math.Sqrt(bucket.LowHz*bucket.HighHz), 440)
+		if math.Abs(centre-math.Floor(centre+0.5)) > 1e-9 {
+			t.Errorf("semitone bucket #%d %v is centred %v semitones from A4, want a whole number", i, bucket, centre)
+		}
+	}
+	if got := Semitones(math.Sqrt(semitoneBuckets[0].LowHz*semitoneBuckets[0].HighHz), 440); math.Abs(got-2) > 1e-9 {
+		t.Errorf("lowest semitone bucket is %v semitones from A4, want 2 (B4)", got)
+	}
+
+	zero := FrequencyRange{LowHz: 0, HighHz: 5000}
+	if err := LogScale.CheckRange(zero); err == nil {
+		t.Errorf("LogScale.CheckRange(%v) succeeded, want error", zero)
+	}
+	if err := LinearScale.CheckRange(zero); err != nil {
+		t.Errorf("LinearScale.CheckRange(%v) = %v", zero, err)
+	}
+
+	if scale, err := ParseFrequencyScale("mel"); err != nil || scale != MelScale {
+		t.Errorf("ParseFrequencyScale(\"mel\") = %v, %v", scale, err)
+	}
+}
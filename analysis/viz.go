@@ -4,12 +4,24 @@ import (
 	"image"
 	"image/color"
 	"math"
+
+	"github.com/steinarvk/abora/stats"
 )
 
 func (a *Analysis) DefaultValueMapper() func(x float64) float64 {
 	nearmax := a.ValueStats.Quantile(0.995) * 0.99
 	threshold := a.ValueStats.Quantile(0.8)
 
+	if threshold <= 0 {
+		// Sparse analyses (such as reassigned spectrograms) are mostly
+		// zero, so quantiles are taken over the non-zero values, and the
+		// threshold is a fixed dynamic range below the peak.
+		if positive := a.positiveValues(); positive.Count() > 0 {
+			nearmax = positive.Quantile(0.995) * 0.99
+			threshold = nearmax * sparseDynamicRange
+		}
+	}
+
 	logThreshold := math.Log(threshold)
 	logDenom := math.Log(nearmax) - logThreshold
 
@@ -24,6 +36,23 @@ func (a *Analysis) DefaultValueMapper() func(x float64) float64 {
 	return f
 }
 
+const (
+	// 60 dB.
+	sparseDynamicRange = 1e-6
+)
+
+func (a *Analysis) positiveValues() *stats.ValueCollection {
+	rv := stats.New()
+	for _, point := range a.Points {
+		for _, x := range point.Values {
+			if x > 0 {
+				rv.Add(x)
+			}
+		}
+	}
+	return rv
+}
+
 func (a *Analysis) Visualize(mapper func(float64) float64, colorizer func(float64) color.Color) image.Image {
 	width := len(a.Points)
 	height := len(a.FrequencyBuckets)
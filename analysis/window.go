@@ -0,0 +1,162 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mjibson/go-dsp/window"
+)
+
+// WindowKind is the shape of a window function.
+type WindowKind int
+
+const (
+	HannWindow WindowKind = iota
+	HammingWindow
+	BlackmanHarrisWindow
+	KaiserWindow
+	GaussianWindow
+	RectangularWindow
+)
+
+var (
+	windowKindNames = map[WindowKind]string{
+		HannWindow:           "hann",
+		HammingWindow:        "hamming",
+		BlackmanHarrisWindow: "blackmanharris",
+		KaiserWindow:         "kaiser",
+		GaussianWindow:       "gaussian",
+		RectangularWindow:    "rectangular",
+	}
+)
+
+const (
+	defaultKaiserBeta    = 8.6
+	defaultGaussianSigma = 0.4
+)
+
+// WindowFunction describes the window applied to each frame before
+// spectral analysis. The zero value is a Hann window.
+type WindowFunction struct {
+	Kind WindowKind
+
+	// Parameter is the beta of a Kaiser window, or the standard deviation
+	// of a Gaussian window relative to half its length. Zero selects the
+	// default (8.6 and 0.4 respectively).
+	Parameter float64
+}
+
+func (k WindowKind) String() string {
+	if name, ok := windowKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("WindowKind(%d)", int(k))
+}
+
+func (w WindowFunction) parameter() float64 {
+	if w.Parameter != 0 {
+		return w.Parameter
+	}
+	switch w.Kind {
+	case KaiserWindow:
+		return defaultKaiserBeta
+	case GaussianWindow:
+		return defaultGaussianSigma
+	}
+	return 0
+}
+
+func (w WindowFunction) String() string {
+	switch w.Kind {
+	case KaiserWindow, GaussianWindow:
+		return fmt.Sprintf("%v:%v", w.Kind, w.parameter())
+	}
+	return w.Kind.String()
+}
+
+// ParseWindow parses a window function such as "hann", "hamming",
+// "blackmanharris", "rectangular", "kaiser:8.6" or "gaussian:0.4". The
+// parameter of Kaiser and Gaussian windows may be omitted.
+func ParseWindow(spec string) (WindowFunction, error) {
+	name, param := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, param = spec[:i], spec[i+1:]
+	}
+
+	for kind, kindName := range windowKindNames {
+		if name != kindName {
+			continue
+		}
+
+		rv := WindowFunction{Kind: kind}
+
+		if param != "" {
+			if kind != KaiserWindow && kind != GaussianWindow {
+				return rv, fmt.Errorf("window %q takes no parameter", name)
+			}
+			value, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return rv, fmt.Errorf("invalid parameter for window %q: %v", name, err)
+			}
+			if value <= 0 {
+				return rv, fmt.Errorf("parameter for window %q must be positive (got %v)", name, value)
+			}
+			rv.Parameter = value
+		}
+
+		return rv, nil
+	}
+
+	return WindowFunction{}, fmt.Errorf("unknown window function %q", spec)
+}
+
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-16 {
+			break
+		}
+	}
+	return sum
+}
+
+// Coefficients returns the (symmetric) window of length n.
+func (w WindowFunction) Coefficients(n int) []float64 {
+	if w.Kind == HannWindow {
+		// The window used by spectral.Pwelch by default.
+		return window.Hann(n)
+	}
+
+	rv := make([]float64, n)
+	if n == 1 {
+		rv[0] = 1
+		return rv
+	}
+
+	param := w.parameter()
+	denom := float64(n - 1)
+
+	for i := range rv {
+		x := float64(i) / denom
+		switch w.Kind {
+		case HammingWindow:
+			rv[i] = 0.54 - 0.46*math.Cos(2*math.Pi*x)
+		case BlackmanHarrisWindow:
+			rv[i] = 0.35875 - 0.48829*math.Cos(2*math.Pi*x) + 0.14128*math.Cos(4*math.Pi*x) - 0.01168*math.Cos(6*math.Pi*x)
+		case KaiserWindow:
+			r := 2*x - 1
+			rv[i] = besselI0(param*math.Sqrt(1-r*r)) / besselI0(param)
+		case GaussianWindow:
+			r := (2*x - 1) / param
+			rv[i] = math.Exp(-0.5 * r * r)
+		case RectangularWindow:
+			rv[i] = 1
+		}
+	}
+
+	return rv
+}
@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseWindow(t *testing.T) {
+	for _, spec := range []string{"hann", "hamming", "blackmanharris", "rectangular", "kaiser:5", "gaussian:0.3"} {
+		w, err := ParseWindow(spec)
+		if err != nil {
+			t.Errorf("ParseWindow(%q) = %v", spec, err)
+			continue
+		}
+		if w.String() != spec {
+			t.Errorf("ParseWindow(%q).String() = %q", spec, w.String())
+		}
+
+		coeffs := w.Coefficients(65)
+		if math.Abs(coeffs[32]-1) > 1e-9 {
+			t.Errorf("%s window peaks at %v, want 1", spec, coeffs[32])
+		}
+		if math.Abs(coeffs[0]-coeffs[64]) > 1e-12 {
+			t.Errorf("%s window is not symmetric", spec)
+		}
+	}
+
+	for _, spec := range []string{"triangle", "hann:2", "kaiser:x", "gaussian:-1"} {
+		if _, err := ParseWindow(spec); err == nil {
+			t.Errorf("ParseWindow(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestPureFFTWindowReducesLeakage(t *testing.T) {
+	const rate = 8000
+	const n = 1024
+
+	// A tone halfway between two bins leaks the most.
+	freq := 100.5 * rate / n
+	frames := make([]float64, n)
+	for i := range frames {
+		frames[i] = math.Sin(2 * math.Pi * freq * float64(i) / rate)
+	}
+
+	farLeakage := func(w WindowFunction) float64 {
+		point := calculatePureFFT(frames, rate, w)
+		return point.Amplitude[200] / point.Amplitude[100]
+	}
+
+	rectangular := farLeakage(WindowFunction{Kind: RectangularWindow})
+	for _, kind := range []WindowKind{HannWindow, BlackmanHarrisWindow, KaiserWindow} {
+		if got := farLeakage(WindowFunction{Kind: kind}); got > rectangular/100 {
+			t.Errorf("%v window leakage %v, want well below rectangular %v", kind, got, rectangular)
+		}
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	params := &Params{MinWindowSizeSeconds: 0.05, Overlap: 0.75}
+	normalizeParams(nil, params)
+	size, hop := params.frameLayout(44100)
+	if size != 4096 || hop != 1024 {
+		t.Errorf("frameLayout() = %d, %d; want 4096, 1024", size, hop)
+	}
+	if got := params.EffectiveAnalysesPerSecond(44100); math.Abs(got-44100.0/1024) > 1e-9 {
+		t.Errorf("EffectiveAnalysesPerSecond() = %v", got)
+	}
+}
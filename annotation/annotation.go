@@ -0,0 +1,151 @@
+// Package annotation converts between the line segments drawn in the
+// abora-studio editor and Chirps, and stores them as projects on disk.
+package annotation
+
+import (
+	"fmt"
+
+	aborapb "github.com/steinarvk/abora/proto"
+)
+
+// Point is a single vertex of an annotated line segment.
+type Point struct {
+	Time      float64
+	Frequency float64
+}
+
+// Segment is a line traced over the spectrogram: a frequency contour over
+// time.
+type Segment struct {
+	Points []Point
+}
+
+var (
+	// Annotations are rendered as plain tones with a short attack and
+	// release, so that they can be compared to the original by ear.
+	defaultsContext = &aborapb.Context{
+		Oscillator: &aborapb.Oscillator{
+			Oscillators: &aborapb.Oscillator_Sine{},
+		},
+		Envelope: &aborapb.Envelope{
+			EnvelopeKind: &aborapb.Envelope_Adsr{
+				Adsr: &aborapb.ADSREnvelope{
+					AttackDuration:  0.02,
+					DecayDuration:   0.0,
+					SustainLevel:    1.0,
+					ReleaseDuration: 0.02,
+				},
+			},
+		},
+		Initial: &aborapb.PointSettings{
+			Amplitude: value(0.25),
+		},
+	}
+)
+
+func value(x float64) *aborapb.DoubleOrHold {
+	return &aborapb.DoubleOrHold{
+		ValueOrHold: &aborapb.DoubleOrHold_Value{
+			Value: x,
+		},
+	}
+}
+
+func (s Segment) validate() error {
+	if len(s.Points) < 2 {
+		return fmt.Errorf("segment has %d points; need at least 2", len(s.Points))
+	}
+	for i, p := range s.Points {
+		if p.Time < 0 {
+			return fmt.Errorf("point #%d has negative time %v", i, p.Time)
+		}
+		if p.Frequency <= 0 {
+			return fmt.Errorf("point #%d has non-positive frequency %v", i, p.Frequency)
+		}
+		if i > 0 && p.Time < s.Points[i-1].Time {
+			return fmt.Errorf("point #%d moves backwards in time (%v < %v)", i, p.Time, s.Points[i-1].Time)
+		}
+	}
+	if s.Points[len(s.Points)-1].Time == s.Points[0].Time {
+		return fmt.Errorf("segment has zero duration")
+	}
+	return nil
+}
+
+// ToChirp converts a segment to a chirp following its frequency contour.
+func (s Segment) ToChirp() (*aborapb.Chirp, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	begin := s.Points[0].Time
+	end := s.Points[len(s.Points)-1].Time
+
+	rv := &aborapb.Chirp{
+		BeginTime: begin,
+		Duration:  end - begin,
+	}
+
+	for _, p := range s.Points {
+		rv.Points = append(rv.Points, &aborapb.Point{
+			T: p.Time - begin,
+			Settings: &aborapb.PointSettings{
+				Freq: value(p.Frequency),
+			},
+		})
+	}
+
+	return rv, nil
+}
+
+// ToChirps converts segments to a score with one chirp per segment.
+func ToChirps(segments []Segment) (*aborapb.Chirps, error) {
+	rv := &aborapb.Chirps{
+		Defaults: defaultsContext,
+	}
+
+	for i, segment := range segments {
+		chirp, err := segment.ToChirp()
+		if err != nil {
+			return nil, fmt.Errorf("segment #%d: %v", i, err)
+		}
+		rv.Chirp = append(rv.Chirp, chirp)
+	}
+
+	return rv, nil
+}
+
+// FromChirps recovers the frequency contours of a score. Chirp points
+// that hold the frequency become vertices at the previous frequency.
+func FromChirps(chirps *aborapb.Chirps) ([]Segment, error) {
+	var rv []Segment
+
+	for i, chirp := range chirps.GetChirp() {
+		var segment Segment
+
+		// A zero value means the frequency is held (or unset).
+		freq := chirps.GetDefaults().GetInitial().GetFreq().GetValue()
+		if f := chirp.GetContextOverride().GetInitial().GetFreq().GetValue(); f > 0 {
+			freq = f
+		}
+
+		for _, p := range chirp.Points {
+			if f := p.GetSettings().GetFreq().GetValue(); f > 0 {
+				freq = f
+			}
+			if freq <= 0 {
+				return nil, fmt.Errorf("chirp #%d: no frequency at t=%v", i, p.T)
+			}
+			segment.Points = append(segment.Points, Point{
+				Time:      chirp.BeginTime + p.T,
+				Frequency: freq,
+			})
+		}
+
+		if len(segment.Points) > 0 {
+			rv = append(rv, segment)
+		}
+	}
+
+	return rv, nil
+}
@@ -0,0 +1,128 @@
+package annotation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/steinarvk/abora/synth/chirp"
+
+	aborapb "github.com/steinarvk/abora/proto"
+)
+
+var testSegments = []Segment{
+	{Points: []Point{{1.0, 440}, {1.5, 450}, {2.0, 400}}},
+	{Points: []Point{{2.5, 1000}, {3.0, 1000}}},
+}
+
+func TestChirpsRoundTrip(t *testing.T) {
+	chirps, err := ToChirps(testSegments)
+	if err != nil {
+		t.Fatalf("ToChirps() = %v", err)
+	}
+
+	if _, err := chirp.ScoreFromProto(chirps); err != nil {
+		t.Errorf("ScoreFromProto(ToChirps()) = %v", err)
+	}
+
+	got, err := FromChirps(chirps)
+	if err != nil {
+		t.Fatalf("FromChirps() = %v", err)
+	}
+	if !reflect.DeepEqual(got, testSegments) {
+		t.Errorf("FromChirps(ToChirps(%v)) = %v", testSegments, got)
+	}
+
+	if _, err := ToChirps([]Segment{{Points: []Point{{1, 440}}}}); err == nil {
+		t.Errorf("ToChirps() of single-point segment succeeded, want error")
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "annotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := ProjectName("/some/dir/bird song.flac")
+	if name != "bird_song.flac" {
+		t.Errorf("ProjectName() = %q", name)
+	}
+
+	if _, err := store.Load(name); err != ErrNoSuchProject {
+		t.Errorf("Load() before saving = %v, want ErrNoSuchProject", err)
+	}
+
+	chirps, err := ToChirps(testSegments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(name, &aborapb.Project{
+		Input:       "bird song.flac",
+		Annotations: chirps,
+		Editor:      &aborapb.EditorState{Offset: 4, Channel: -1},
+	}); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+
+	project, err := store.Load(name)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if project.Input != "bird song.flac" || project.GetEditor().Offset != 4 || project.GetEditor().Channel != -1 {
+		t.Errorf("Load() = %v", project)
+	}
+	if got, _ := FromChirps(project.Annotations); !reflect.DeepEqual(got, testSegments) {
+		t.Errorf("Load() annotations = %v, want %v", got, testSegments)
+	}
+
+	infos, err := store.List()
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if len(infos) != 1 || infos[0].Name != name || infos[0].Chirps != 2 {
+		t.Errorf("List() = %v", infos)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "corrupt"+projectSuffix), []byte("not a proto"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	infos, err = store.List()
+	if err != nil {
+		t.Fatalf("List() with corrupt project = %v", err)
+	}
+	if len(infos) != 1 || infos[0].Name != name {
+		t.Errorf("List() with corrupt project = %v", infos)
+	}
+
+	if err := store.Save("../escape", &aborapb.Project{}); err == nil {
+		t.Errorf("Save() with invalid name succeeded, want error")
+	}
+}
+
+func TestProjectNameIsValid(t *testing.T) {
+	for _, input := range []string{
+		"bird song.flac",
+		"/tmp/fågelsång.wav",
+		"...hidden",
+		"-dash.wav",
+		"..",
+		"/",
+		"a+b=c&d;e'f.wav",
+		"日本語.flac",
+	} {
+		name := ProjectName(input)
+		if !validProjectName.MatchString(name) {
+			t.Errorf("ProjectName(%q) = %q, which is not a valid project name", input, name)
+		}
+	}
+}
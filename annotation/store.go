@@ -0,0 +1,159 @@
+package annotation
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/slice"
+	"github.com/golang/protobuf/proto"
+
+	aborapb "github.com/steinarvk/abora/proto"
+)
+
+const (
+	projectSuffix = ".project.pb_text"
+)
+
+var (
+	validProjectName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+)
+
+// ErrNoSuchProject is returned when loading a project that has not been
+// saved.
+var ErrNoSuchProject = errors.New("no such project")
+
+// Store keeps projects as text protos in a directory.
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store in the given directory, creating it if needed.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// ProjectName derives the default project name for an input file. The
+// result is always a valid project name.
+func ProjectName(inputFilename string) string {
+	name := filepath.Base(inputFilename)
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', strings.ContainsRune("_.-", r):
+			return r
+		}
+		return '_'
+	}, name)
+	name = strings.TrimLeft(name, ".-")
+	if name == "" {
+		return "untitled"
+	}
+	return name
+}
+
+func (s *Store) filename(name string) (string, error) {
+	if !validProjectName.MatchString(name) {
+		return "", fmt.Errorf("invalid project name %q", name)
+	}
+	return filepath.Join(s.dir, name+projectSuffix), nil
+}
+
+// Load reads a saved project.
+func (s *Store) Load(name string) (*aborapb.Project, error) {
+	filename, err := s.filename(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, ErrNoSuchProject
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &aborapb.Project{}
+	if err := proto.UnmarshalText(string(data), rv); err != nil {
+		return nil, fmt.Errorf("project %q is corrupt: %v", name, err)
+	}
+
+	return rv, nil
+}
+
+// Save writes a project, replacing any previous version atomically. It
+// sets the project's save time.
+func (s *Store) Save(name string, project *aborapb.Project) error {
+	filename, err := s.filename(name)
+	if err != nil {
+		return err
+	}
+
+	project.SavedTime = time.Now().Unix()
+
+	f, err := ioutil.TempFile(s.dir, ".tmp-"+name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(proto.MarshalTextString(project)); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+// ProjectInfo summarizes a saved project.
+type ProjectInfo struct {
+	Name      string
+	Input     string
+	Chirps    int
+	SavedTime time.Time
+}
+
+// List returns the saved projects, most recently saved first.
+func (s *Store) List() ([]ProjectInfo, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*"+projectSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	var rv []ProjectInfo
+	for _, match := range matches {
+		name := strings.TrimSuffix(filepath.Base(match), projectSuffix)
+		project, err := s.Load(name)
+		if err != nil {
+			// One unreadable project should not hide the others.
+			log.Printf("skipping project %q: %v", name, err)
+			continue
+		}
+		rv = append(rv, ProjectInfo{
+			Name:      name,
+			Input:     project.Input,
+			Chirps:    len(project.GetAnnotations().GetChirp()),
+			SavedTime: time.Unix(project.SavedTime, 0),
+		})
+	}
+
+	slice.Sort(rv, func(i, j int) bool {
+		return rv[i].SavedTime.After(rv[j].SavedTime)
+	})
+
+	return rv, nil
+}
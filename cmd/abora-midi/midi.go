@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/steinarvk/abora/midi"
+
+	aborapb "github.com/steinarvk/abora/proto"
+)
+
+var (
+	fromScoreFile = flag.String("score", "", "score filename (a Chirps message) to convert to MIDI")
+	fromMIDIFile  = flag.String("midi", "", "MIDI filename to convert to a score")
+	outputFile    = flag.String("output", "", "output filename (a MIDI file, or a text proto score); a score is printed to stdout if empty")
+
+	bendRange       = flag.Float64("bend_range", 2, "pitch bend range in semitones (when reading, used only if the file doesn't set it)")
+	tempo           = flag.Float64("tempo", 120, "tempo of the MIDI file, in beats per minute")
+	ticksPerQuarter = flag.Int("ticks_per_quarter", 480, "time resolution of the MIDI file, in ticks per quarter note")
+	bendsPerSecond  = flag.Float64("bends_per_second", 100, "rate at which frequency contours are sampled as pitch bends")
+	referenceA4     = flag.Float64("a4", 440, "frequency of A4 (note 69), in Hz")
+)
+
+func exportScore() error {
+	if *outputFile == "" {
+		return errors.New("--output is required with --score")
+	}
+
+	data, err := ioutil.ReadFile(*fromScoreFile)
+	if err != nil {
+		return err
+	}
+
+	spec := &aborapb.Chirps{}
+	if err := proto.UnmarshalText(string(data), spec); err != nil {
+		return err
+	}
+
+	log.Printf("writing %d chirps to %q", len(spec.Chirp), *outputFile)
+
+	return midi.WriteFile(*outputFile, spec,
+		midi.BendRange(*bendRange),
+		midi.Tempo(*tempo),
+		midi.TicksPerQuarter(*ticksPerQuarter),
+		midi.BendsPerSecond(*bendsPerSecond),
+		midi.ReferenceA4(*referenceA4))
+}
+
+func importMIDI() error {
+	spec, err := midi.ReadFile(*fromMIDIFile,
+		midi.BendRange(*bendRange),
+		midi.ReferenceA4(*referenceA4))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("read %d notes from %q", len(spec.Chirp), *fromMIDIFile)
+
+	rvText := proto.MarshalTextString(spec)
+
+	if *outputFile == "" {
+		fmt.Println(rvText)
+		return nil
+	}
+
+	return ioutil.WriteFile(*outputFile, []byte(rvText), 0644)
+}
+
+func mainCore() error {
+	if (*fromScoreFile == "") == (*fromMIDIFile == "") {
+		return errors.New("exactly one of --score and --midi is required")
+	}
+
+	if *fromScoreFile != "" {
+		return exportScore()
+	}
+	return importMIDI()
+}
+
+func main() {
+	flag.Parse()
+
+	if err := mainCore(); err != nil {
+		log.Fatalf("failure: %v", err)
+	}
+}
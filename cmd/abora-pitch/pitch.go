@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/steinarvk/abora/analysis"
+	"github.com/steinarvk/abora/snippet"
+)
+
+var (
+	inputFile       = flag.String("input", "", "input filename")
+	channel         = flag.String("channel", "average", "channel to analyze: a channel number, \"average\", \"mid\" or \"side\"")
+	sampleRate      = flag.Int("sample_rate", 0, "resample input to this sample rate before analysis (0 to use the input's rate)")
+	outputFile      = flag.String("output", "", "output filename; if empty, print to stdout")
+	outputFormat    = flag.String("format", "csv", "output format: \"csv\" or \"json\"")
+	lowFrequency    = flag.Float64("low_freq", 500.0, "lowest fundamental frequency to consider")
+	highFrequency   = flag.Float64("high_freq", 5000.0, "highest fundamental frequency to consider")
+	framesPerSecond = flag.Float64("frames_per_second", 100.0, "number of pitch estimates per second")
+	integrationSecs = flag.Float64("integration_window_seconds", 0.02, "length of signal compared in each frame (seconds)")
+	maxPitchJump    = flag.Float64("max_pitch_jump", 1.0, "typical pitch change (semitones) between frames; larger jumps are penalized")
+)
+
+func mainCore() error {
+	if *inputFile == "" {
+		return errors.New("--input is required")
+	}
+
+	var write func(*analysis.PitchTrack, io.Writer) error
+	switch *outputFormat {
+	case "csv":
+		write = (*analysis.PitchTrack).WriteCSV
+	case "json":
+		write = (*analysis.PitchTrack).WriteJSON
+	default:
+		return fmt.Errorf("unknown output format %q", *outputFormat)
+	}
+
+	channelOpt, err := snippet.ParseChannel(*channel)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("reading input file %q", *inputFile)
+	snip, err := snippet.Read(*inputFile, channelOpt)
+	if err != nil {
+		return err
+	}
+
+	if *sampleRate > 0 {
+		log.Printf("resampling from %d Hz to %d Hz", snip.SampleRate(), *sampleRate)
+		snip = snippet.Resample(snip, *sampleRate)
+	}
+
+	log.Printf("tracking pitch")
+	track, err := analysis.TrackPitch(snip, &analysis.PitchParams{
+		Range: &analysis.FrequencyRange{
+			LowHz:  *lowFrequency,
+			HighHz: *highFrequency,
+		},
+		IntegrationWindowSeconds: *integrationSecs,
+		FramesPerSecond:          *framesPerSecond,
+		MaxSemitonesPerFrame:     *maxPitchJump,
+	})
+	if err != nil {
+		return err
+	}
+
+	voiced := 0
+	for _, frame := range track.Frames {
+		if frame.Voiced {
+			voiced++
+		}
+	}
+	log.Printf("%d of %d frames are voiced", voiced, len(track.Frames))
+
+	if *outputFile == "" {
+		return write(track, os.Stdout)
+	}
+
+	f, err := os.Create(*outputFile)
+	if err != nil {
+		return err
+	}
+
+	if err := write(track, f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func main() {
+	flag.Parse()
+
+	if err := mainCore(); err != nil {
+		log.Fatalf("failure: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/steinarvk/abora/annotation"
+	"github.com/steinarvk/abora/http/params"
+	"github.com/steinarvk/abora/snippet"
+	"github.com/steinarvk/abora/synth/chirp"
+	"github.com/steinarvk/abora/synth/master"
+	"github.com/steinarvk/abora/synth/mix"
+	"github.com/steinarvk/abora/wav"
+
+	aborapb "github.com/steinarvk/abora/proto"
+)
+
+// previewRequest holds the annotations to preview: either editor segments
+// or a Chirps message in text format.
+type previewRequest struct {
+	Segments []annotation.Segment
+	Chirps   string
+}
+
+func (p *previewRequest) toChirps() (*aborapb.Chirps, error) {
+	if p.Chirps != "" {
+		if len(p.Segments) > 0 {
+			return nil, fmt.Errorf("got both segments and chirps")
+		}
+		rv := &aborapb.Chirps{}
+		if err := proto.UnmarshalText(p.Chirps, rv); err != nil {
+			return nil, fmt.Errorf("malformed chirps: %v", err)
+		}
+		return rv, nil
+	}
+
+	return annotation.ToChirps(p.Segments)
+}
+
+// renderRegion synthesizes the part of a score between begin and begin +
+// duration. Chirps already sounding at the beginning of the region are
+// moved there directly if they can seek; otherwise they are rendered from
+// their start, and the lead-in discarded.
+func renderRegion(score *aborapb.Chirps, sampleRate int, begin, duration float64) ([]float64, error) {
+	end := begin + duration
+
+	relevant := &aborapb.Chirps{
+		Defaults: score.Defaults,
+	}
+	for _, c := range score.Chirp {
+		if c.BeginTime+c.Duration < begin || c.BeginTime > end {
+			continue
+		}
+		relevant.Chirp = append(relevant.Chirp, c)
+	}
+
+	chirps, err := chirp.ScoreFromProto(relevant)
+	if err != nil {
+		return nil, err
+	}
+
+	renderStart := begin
+	for i, c := range chirps {
+		if c.Time >= begin {
+			continue
+		}
+		if chirp.Seek(c.Chirp, begin-c.Time) {
+			chirps[i].Time = begin
+			continue
+		}
+
+		// A failed seek leaves the chirp unusable, so construct it anew.
+		rebuilt, err := chirp.FromProto(relevant.Chirp[i], relevant.Defaults)
+		if err != nil {
+			return nil, err
+		}
+		chirps[i] = *rebuilt
+		renderStart = math.Min(renderStart, c.Time)
+	}
+
+	for i := range chirps {
+		chirps[i].Time -= renderStart
+	}
+
+	skip := int((begin - renderStart) * float64(sampleRate))
+	n := int(duration * float64(sampleRate))
+
+	mixer := mix.NewMixer(chirps, sampleRate, end-renderStart)
+	if skip > 0 {
+		mixer.Read(make([]float64, skip))
+	}
+
+	rv := make([]float64, n)
+	mixer.Read(rv)
+
+	return rv, nil
+}
+
+func (s *studioServer) servePreview(w http.ResponseWriter, req *http.Request) error {
+	v := req.URL.Query()
+	log.Printf("serving preview request: %v", v)
+	defer log.Printf("done serving preview request: %v", v)
+
+	if req.Method != "POST" {
+		return fmt.Errorf("unsupported method %q", req.Method)
+	}
+
+	params := params.Getter(req)
+
+	t := params.Float("t", 0.0)
+	balance := params.Float("balance", 1.0)
+
+	if params.Err() != nil {
+		return params.Err()
+	}
+
+	if balance < 0 || balance > 1 {
+		return fmt.Errorf("balance %v out of range [0, 1]", balance)
+	}
+
+	var body previewRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return fmt.Errorf("malformed preview request: %v", err)
+	}
+
+	score, err := body.toChirps()
+	if err != nil {
+		return err
+	}
+
+	snip, err := s.getSnippet(req)
+	if err != nil {
+		return err
+	}
+
+	duration := math.Min(snippet.Duration(snip), *maxAudioSeconds)
+	sampleRate := snip.SampleRate()
+
+	synthesized, err := renderRegion(score, sampleRate, t, duration)
+	if err != nil {
+		return err
+	}
+
+	original := snip.Slice(0, len(synthesized))
+
+	samples := make([]float64, len(synthesized))
+	for i, x := range synthesized {
+		samples[i] = balance * x
+		if i < len(original) {
+			samples[i] += (1 - balance) * original[i]
+		}
+	}
+
+	if peak := master.Peak(samples); peak > 1 {
+		for i := range samples {
+			samples[i] /= peak
+		}
+	}
+
+	w.Header().Set("Content-Type", "audio/wav")
+
+	if err := wav.Encode(w, sampleRate, samples, wav.BitDepth(16)); err != nil {
+		log.Printf("write/encode error: %v", err)
+		return err
+	}
+
+	return nil
+}
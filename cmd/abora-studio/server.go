@@ -1,42 +1,141 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"image"
 	"image/png"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 
 	"github.com/steinarvk/abora/analysis"
+	"github.com/steinarvk/abora/annotation"
 	"github.com/steinarvk/abora/colorscale"
+	"github.com/steinarvk/abora/filter"
 	"github.com/steinarvk/abora/http/params"
+	"github.com/steinarvk/abora/http/tilecache"
 	"github.com/steinarvk/abora/snippet"
+	"github.com/steinarvk/abora/stretch"
+	"github.com/steinarvk/abora/synth/master"
+	"github.com/steinarvk/abora/wav"
+
+	aborapb "github.com/steinarvk/abora/proto"
 )
 
 var (
-	inputFilename = flag.String("input", "", "input filename")
-	port          = flag.Int("port", 8099, "port on which to listen")
-	staticFiles   = flag.String("static_files_dir", "./static/", "directory with static files")
+	inputFilename   = flag.String("input", "", "input filename")
+	channel         = flag.String("channel", "average", "default channel to display: a channel number, \"average\", \"mid\" or \"side\"")
+	port            = flag.Int("port", 8099, "port on which to listen")
+	staticFiles     = flag.String("static_files_dir", "./static/", "directory with static files")
+	cachedBlocks    = flag.Int("cached_blocks", 64, "number of decoded blocks of input to keep in memory (per channel)")
+	maxAudioSeconds = flag.Float64("max_audio_seconds", 120, "longest region that can be played back at once, in seconds")
+	maxNotesSeconds = flag.Float64("max_notes_seconds", 600, "longest region in which notes can be detected at once, in seconds")
+	tileCacheMB     = flag.Int("tile_cache_mb", 256, "size of the in-memory cache of rendered tiles, in megabytes")
+	tileCacheDir    = flag.String("tile_cache_dir", "", "directory in which to cache rendered tiles across restarts (none if empty)")
+	projectsDir     = flag.String("projects_dir", "./projects/", "directory in which to save annotation projects")
 )
 
 type studioServer struct {
-	snip snippet.Snippet
+	snip     snippet.Snippet
+	channels []snippet.Snippet
+
+	projects    *annotation.Store
+	projectName string
+
+	// inputID identifies the contents of the input file in cache keys.
+	inputID string
+	cache   *tilecache.Cache
 }
 
-func (s *studioServer) getSnippet(req *http.Request) (snippet.Snippet, error) {
+// region identifies the part of the input a request is about.
+type region struct {
+	Time     float64
+	Duration float64
+	Channel  int
+}
+
+func parseRegion(req *http.Request) (region, error) {
 	params := params.Getter(req)
 
-	t := params.Float("t", 0.0)
-	dur := params.Float("duration", 5.0)
+	rv := region{
+		Time:     params.Float("t", 0.0),
+		Duration: params.Float("duration", 5.0),
+		Channel:  params.Int("channel", -1),
+	}
 
-	if params.Err() != nil {
-		return nil, params.Err()
+	return rv, params.Err()
+}
+
+func (s *studioServer) regionSnippet(r region) (snippet.Snippet, error) {
+	snip := s.snip
+	if r.Channel >= 0 {
+		if r.Channel >= len(s.channels) {
+			return nil, fmt.Errorf("channel %d out of range (input has %d channels)", r.Channel, len(s.channels))
+		}
+		snip = s.channels[r.Channel]
+	}
+
+	return snippet.SubsnippetByTime(snip, r.Time, r.Duration), nil
+}
+
+func (s *studioServer) getSnippet(req *http.Request) (snippet.Snippet, error) {
+	r, err := parseRegion(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.regionSnippet(r)
+}
+
+// cacheKeyer is implemented by the parameters of each kind of analysis.
+type cacheKeyer interface {
+	CacheKey() string
+}
+
+// cacheKey identifies a rendering of a region of the input.
+func (s *studioServer) cacheKey(kind string, r region, params cacheKeyer, extra ...interface{}) string {
+	return fmt.Sprintf("%s|%s|t=%v duration=%v channel=%d|%s|%v", kind, s.inputID, r.Time, r.Duration, r.Channel, params.CacheKey(), extra)
+}
+
+// serveCached serves data that is determined by its cache key, computing
+// it only if neither the client nor the cache has it.
+func (s *studioServer) serveCached(w http.ResponseWriter, req *http.Request, contentType, key string, compute func() ([]byte, error)) error {
+	etag := tilecache.ETag(key)
+
+	// The caching headers are only sent with successful responses, lest
+	// the client cache an error.
+	setCacheHeaders := func() {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+	}
+
+	if req.Header.Get("If-None-Match") == etag {
+		setCacheHeaders()
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	data, err := s.cache.Get(key, compute)
+	if err != nil {
+		return err
 	}
 
-	return snippet.SubsnippetByTime(s.snip, t, dur), nil
+	setCacheHeaders()
+	w.Header().Set("Content-Type", contentType)
+	_, err = w.Write(data)
+	return err
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 func (s *studioServer) getAnalysisParams(req *http.Request, snip snippet.Snippet) (*analysis.Params, error) {
@@ -57,10 +156,40 @@ func (s *studioServer) getAnalysisParams(req *http.Request, snip snippet.Snippet
 
 	loudnessWindowSize := params.Float("loudnessWindowSize", 0.001)
 
+	scaleName := params.String("scale", "linear")
+	referenceA4 := params.Float("a4", 440.0)
+	transformName := params.String("transform", "pwelch")
+	qualityFactor := params.Float("q", 0)
+	windowName := params.String("window", "hann")
+	overlap := params.Float("overlap", 0)
+
 	if params.Err() != nil {
 		return nil, params.Err()
 	}
 
+	transform, err := analysis.ParseTransform(transformName)
+	if err != nil {
+		return nil, err
+	}
+
+	windowFunc, err := analysis.ParseWindow(windowName)
+	if err != nil {
+		return nil, err
+	}
+
+	if overlap < 0 || overlap >= 1 {
+		return nil, fmt.Errorf("overlap %v out of range [0, 1)", overlap)
+	}
+
+	scale, err := analysis.ParseFrequencyScale(scaleName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := scale.CheckRange(analysis.FrequencyRange{LowHz: lowHz, HighHz: highHz}); err != nil {
+		return nil, err
+	}
+
 	return &analysis.Params{
 		LoudnessWindowSizeSeconds: loudnessWindowSize,
 		MinWindowSizeSeconds:      windowSize,
@@ -70,6 +199,12 @@ func (s *studioServer) getAnalysisParams(req *http.Request, snip snippet.Snippet
 			HighHz: highHz,
 		},
 		AnalysesPerSecond: timeRes,
+		FrequencyScale:    scale,
+		ReferenceA4:       referenceA4,
+		Transform:         transform,
+		QualityFactor:     qualityFactor,
+		Window:            windowFunc,
+		Overlap:           overlap,
 	}, nil
 }
 
@@ -86,31 +221,31 @@ func (s *studioServer) serveLoudness(w http.ResponseWriter, req *http.Request) e
 		return params.Err()
 	}
 
-	snip, err := s.getSnippet(req)
+	r, err := parseRegion(req)
 	if err != nil {
 		return err
 	}
 
-	analParams, err := s.getAnalysisParams(req, snip)
+	snip, err := s.regionSnippet(r)
 	if err != nil {
 		return err
 	}
 
-	anal, err := analysis.AnalyzeLoudness(snip, analParams)
+	analParams, err := s.getAnalysisParams(req, snip)
 	if err != nil {
 		return err
 	}
 
-	img := anal.Visualize(loudnessHeight, anal.DefaultValueMapper(), colorscale.Viridis)
-
-	w.Header().Set("Content-Type", "image/png")
+	key := s.cacheKey("loudness", r, analParams, loudnessHeight)
 
-	if err := png.Encode(w, img); err != nil {
-		log.Printf("write/encode error: %v", err)
-		return err
-	}
+	return s.serveCached(w, req, "image/png", key, func() ([]byte, error) {
+		anal, err := analysis.AnalyzeLoudness(snip, analParams)
+		if err != nil {
+			return nil, err
+		}
 
-	return nil
+		return encodePNG(anal.Visualize(loudnessHeight, anal.DefaultValueMapper(), colorscale.Viridis))
+	})
 }
 
 func (s *studioServer) serveSpectrogram(w http.ResponseWriter, req *http.Request) error {
@@ -118,31 +253,31 @@ func (s *studioServer) serveSpectrogram(w http.ResponseWriter, req *http.Request
 	log.Printf("serving spectrogram request: %v", v)
 	defer log.Printf("done serving spectrogram request: %v", v)
 
-	snip, err := s.getSnippet(req)
+	r, err := parseRegion(req)
 	if err != nil {
 		return err
 	}
 
-	params, err := s.getAnalysisParams(req, snip)
+	snip, err := s.regionSnippet(r)
 	if err != nil {
 		return err
 	}
 
-	anal, err := analysis.Analyze(snip, params)
+	params, err := s.getAnalysisParams(req, snip)
 	if err != nil {
 		return err
 	}
 
-	img := anal.Visualize(anal.DefaultValueMapper(), colorscale.Viridis)
-
-	w.Header().Set("Content-Type", "image/png")
+	key := s.cacheKey("spectrogram", r, params)
 
-	if err := png.Encode(w, img); err != nil {
-		log.Printf("write/encode error: %v", err)
-		return err
-	}
+	return s.serveCached(w, req, "image/png", key, func() ([]byte, error) {
+		anal, err := analysis.Analyze(snip, params)
+		if err != nil {
+			return nil, err
+		}
 
-	return nil
+		return encodePNG(anal.Visualize(anal.DefaultValueMapper(), colorscale.Viridis))
+	})
 }
 
 func (s *studioServer) serveSpectrogramMetadata(w http.ResponseWriter, req *http.Request) error {
@@ -150,7 +285,12 @@ func (s *studioServer) serveSpectrogramMetadata(w http.ResponseWriter, req *http
 	log.Printf("serving spectrogram metadata request: %v", v)
 	defer log.Printf("done serving spectrogram metadata request: %v", v)
 
-	snip, err := s.getSnippet(req)
+	r, err := parseRegion(req)
+	if err != nil {
+		return err
+	}
+
+	snip, err := s.regionSnippet(r)
 	if err != nil {
 		return err
 	}
@@ -160,22 +300,317 @@ func (s *studioServer) serveSpectrogramMetadata(w http.ResponseWriter, req *http
 		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	encoder := json.NewEncoder(w)
+	// The range covered by the image may be wider than requested. On the
+	// semitone scale, rows line up with notes only if the number of rows
+	// is a multiple of the number of notes in the range.
+	frequencyRange := params.Range.Align(params.FrequencyScale, params.ReferenceA4)
 
 	rv := struct {
 		TimeResolution   float64
 		LowFrequency     float64
 		HighFrequency    float64
 		FrequencyBuckets int
+		FrequencyScale   string
+		ReferenceA4      float64
+		Transform        string
+		Window           string
+		Channels         int
+		InputDuration    float64
 	}{
-		TimeResolution:   params.AnalysesPerSecond,
+		TimeResolution:   params.EffectiveAnalysesPerSecond(snip.SampleRate()),
 		FrequencyBuckets: params.NumberOfFrequencyBuckets,
-		LowFrequency:     params.Range.LowHz,
-		HighFrequency:    params.Range.HighHz,
+		LowFrequency:     frequencyRange.LowHz,
+		HighFrequency:    frequencyRange.HighHz,
+		FrequencyScale:   params.FrequencyScale.String(),
+		ReferenceA4:      params.ReferenceA4,
+		Transform:        params.Transform.String(),
+		Window:           params.Window.String(),
+		Channels:         len(s.channels),
+		InputDuration:    snippet.Duration(s.snip),
+	}
+
+	key := s.cacheKey("metadata", r, params)
+
+	return s.serveCached(w, req, "application/json", key, func() ([]byte, error) {
+		return json.Marshal(&rv)
+	})
+}
+
+func (s *studioServer) servePitch(w http.ResponseWriter, req *http.Request) error {
+	v := req.URL.Query()
+	log.Printf("serving pitch request: %v", v)
+	defer log.Printf("done serving pitch request: %v", v)
+
+	params := params.Getter(req)
+
+	lowHz := params.Float("lowHz", 500.0)
+	highHz := params.Float("highHz", 5000.0)
+	framesPerSecond := params.Float("framesPerSecond", 100.0)
+
+	if params.Err() != nil {
+		return params.Err()
+	}
+
+	r, err := parseRegion(req)
+	if err != nil {
+		return err
+	}
+
+	snip, err := s.regionSnippet(r)
+	if err != nil {
+		return err
+	}
+
+	pitchParams := &analysis.PitchParams{
+		Range: &analysis.FrequencyRange{
+			LowHz:  lowHz,
+			HighHz: highHz,
+		},
+		FramesPerSecond: framesPerSecond,
+	}
+
+	key := s.cacheKey("pitch", r, pitchParams)
+
+	return s.serveCached(w, req, "application/json", key, func() ([]byte, error) {
+		track, err := analysis.TrackPitch(snip, pitchParams)
+		if err != nil {
+			return nil, err
+		}
+
+		// Report absolute times, so that the track can be drawn over any view.
+		track.Offset(r.Time)
+
+		var buf bytes.Buffer
+		if err := track.WriteJSON(&buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	})
+}
+
+func (s *studioServer) serveNotes(w http.ResponseWriter, req *http.Request) error {
+	v := req.URL.Query()
+	log.Printf("serving notes request: %v", v)
+	defer log.Printf("done serving notes request: %v", v)
+
+	r, err := parseRegion(req)
+	if err != nil {
+		return err
+	}
+
+	// Detection holds a spectrogram of the whole region in memory.
+	if r.Duration > *maxNotesSeconds {
+		return fmt.Errorf("region of %v seconds is longer than --max_notes_seconds=%v", r.Duration, *maxNotesSeconds)
+	}
+
+	snip, err := s.regionSnippet(r)
+	if err != nil {
+		return err
+	}
+
+	analParams, err := s.getAnalysisParams(req, snip)
+	if err != nil {
+		return err
+	}
+
+	onsetParams := &analysis.OnsetParams{
+		Analysis: analParams,
+	}
+
+	key := s.cacheKey("notes", r, onsetParams)
+
+	return s.serveCached(w, req, "application/json", key, func() ([]byte, error) {
+		boundaries, err := analysis.DetectNotes(snip, onsetParams)
+		if err != nil {
+			return nil, err
+		}
+
+		type marker struct {
+			Time       float64
+			Kind       string
+			Confidence float64
+			Cause      string
+		}
+
+		rv := []marker{}
+		for _, b := range boundaries {
+			rv = append(rv, marker{
+				Time:       r.Time + b.Time,
+				Kind:       b.Kind.String(),
+				Confidence: b.Confidence,
+				Cause:      b.Cause.String(),
+			})
+		}
+
+		return json.Marshal(rv)
+	})
+}
+
+func (s *studioServer) serveAudio(w http.ResponseWriter, req *http.Request) error {
+	v := req.URL.Query()
+	log.Printf("serving audio request: %v", v)
+	defer log.Printf("done serving audio request: %v", v)
+
+	params := params.Getter(req)
+
+	rate := params.Float("rate", 1.0)
+	bandpass := params.Int("bandpass", 0)
+	lowHz := params.Float("lowHz", 500.0)
+	highHz := params.Float("highHz", 5000.0)
+
+	if params.Err() != nil {
+		return params.Err()
+	}
+
+	if rate < 0.1 || rate > 4 {
+		return fmt.Errorf("playback rate %v out of range [0.1, 4]", rate)
 	}
 
-	return encoder.Encode(&rv)
+	snip, err := s.getSnippet(req)
+	if err != nil {
+		return err
+	}
+
+	if snippet.Duration(snip) > *maxAudioSeconds {
+		snip = snippet.SubsnippetByTime(snip, 0, *maxAudioSeconds)
+	}
+
+	sampleRate := snip.SampleRate()
+
+	// The samples are modified in place below, and Slice may return the
+	// snippet's own data.
+	samples := append([]float64(nil), snip.Slice(0, snip.TotalSamples())...)
+
+	if bandpass != 0 {
+		// The filters are unstable at or above the Nyquist frequency, which
+		// the default may exceed for low sample rates.
+		if maxHz := 0.45 * float64(sampleRate); highHz > maxHz {
+			highHz = maxHz
+		}
+		if lowHz <= 0 || lowHz >= highHz {
+			return fmt.Errorf("invalid band [%v, %v] Hz for sample rate %d", lowHz, highHz, sampleRate)
+		}
+		filter.Band(sampleRate, lowHz, highHz).FilterSlice(samples)
+	}
+
+	if rate != 1 {
+		samples = stretch.Stretch(samples, sampleRate, 1/rate)
+	}
+
+	if peak := master.Peak(samples); peak > 1 {
+		for i := range samples {
+			samples[i] /= peak
+		}
+	}
+
+	w.Header().Set("Content-Type", "audio/wav")
+
+	if err := wav.Encode(w, sampleRate, samples, wav.BitDepth(16)); err != nil {
+		log.Printf("write/encode error: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+type editorState struct {
+	Offset  float64
+	Channel int
+}
+
+type projectJSON struct {
+	Name     string
+	Input    string
+	Exists   bool
+	Segments []annotation.Segment
+	Editor   editorState
+}
+
+func (s *studioServer) serveProjectList(w http.ResponseWriter, req *http.Request) error {
+	infos, err := s.projects.List()
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(infos)
+}
+
+func (s *studioServer) serveProject(w http.ResponseWriter, req *http.Request) error {
+	params := params.Getter(req)
+
+	name := params.String("name", s.projectName)
+
+	switch req.Method {
+	case "GET":
+		return s.loadProject(w, name)
+	case "POST":
+		return s.saveProject(w, req, name)
+	default:
+		return fmt.Errorf("unsupported method %q", req.Method)
+	}
+}
+
+func (s *studioServer) loadProject(w http.ResponseWriter, name string) error {
+	rv := projectJSON{
+		Name:   name,
+		Input:  filepath.Base(*inputFilename),
+		Editor: editorState{Channel: -1},
+	}
+
+	project, err := s.projects.Load(name)
+	switch {
+	case err == annotation.ErrNoSuchProject:
+	case err != nil:
+		return err
+	default:
+		segments, err := annotation.FromChirps(project.GetAnnotations())
+		if err != nil {
+			return err
+		}
+		rv.Exists = true
+		rv.Input = project.Input
+		rv.Segments = segments
+		if editor := project.GetEditor(); editor != nil {
+			rv.Editor = editorState{
+				Offset:  editor.Offset,
+				Channel: int(editor.Channel),
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(&rv)
+}
+
+func (s *studioServer) saveProject(w http.ResponseWriter, req *http.Request, name string) error {
+	var body projectJSON
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return fmt.Errorf("malformed project: %v", err)
+	}
+
+	chirps, err := annotation.ToChirps(body.Segments)
+	if err != nil {
+		return err
+	}
+
+	project := &aborapb.Project{
+		Input:       filepath.Base(*inputFilename),
+		Annotations: chirps,
+		Editor: &aborapb.EditorState{
+			Offset:  body.Editor.Offset,
+			Channel: int32(body.Editor.Channel),
+		},
+	}
+
+	if err := s.projects.Save(name, project); err != nil {
+		return err
+	}
+
+	log.Printf("saved project %q with %d segments", name, len(body.Segments))
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(struct{ SavedTime int64 }{project.SavedTime})
 }
 
 func serveErrorOr(f func(http.ResponseWriter, *http.Request) error) func(http.ResponseWriter, *http.Request) {
@@ -193,17 +628,57 @@ func mainCore() error {
 		return errors.New("--input is required")
 	}
 
-	log.Printf("reading %q", *inputFilename)
-	snip, err := snippet.Read(*inputFilename)
+	channelOpt, err := snippet.ParseChannel(*channel)
 	if err != nil {
 		return err
 	}
 
-	serv := &studioServer{snip}
+	cacheOpt := snippet.CachedBlocks(*cachedBlocks)
+
+	log.Printf("opening %q", *inputFilename)
+	snip, channels, err := snippet.OpenWithChannels(*inputFilename, channelOpt, cacheOpt)
+	if err != nil {
+		return err
+	}
+
+	projects, err := annotation.NewStore(*projectsDir)
+	if err != nil {
+		return err
+	}
+
+	cache, err := tilecache.New(int64(*tileCacheMB)<<20, *tileCacheDir)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(*inputFilename)
+	if err != nil {
+		return err
+	}
+
+	absInput, err := filepath.Abs(*inputFilename)
+	if err != nil {
+		return err
+	}
+
+	serv := &studioServer{
+		snip:        snip,
+		channels:    channels,
+		projects:    projects,
+		projectName: annotation.ProjectName(*inputFilename),
+		inputID:     fmt.Sprintf("%s size=%d mtime=%d downmix=%s", absInput, info.Size(), info.ModTime().UnixNano(), *channel),
+		cache:       cache,
+	}
 
 	http.HandleFunc("/spectrogram/png", serveErrorOr(serv.serveSpectrogram))
 	http.HandleFunc("/spectrogram/metadata", serveErrorOr(serv.serveSpectrogramMetadata))
 	http.HandleFunc("/loudness", serveErrorOr(serv.serveLoudness))
+	http.HandleFunc("/pitch", serveErrorOr(serv.servePitch))
+	http.HandleFunc("/notes", serveErrorOr(serv.serveNotes))
+	http.HandleFunc("/audio", serveErrorOr(serv.serveAudio))
+	http.HandleFunc("/preview", serveErrorOr(serv.servePreview))
+	http.HandleFunc("/projects", serveErrorOr(serv.serveProjectList))
+	http.HandleFunc("/project", serveErrorOr(serv.serveProject))
 
 	staticFiles, err := filepath.Abs(*staticFiles)
 	if err != nil {
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/steinarvk/abora/http/tilecache"
+)
+
+func TestServeCachedSendsNoCacheHeadersOnError(t *testing.T) {
+	cache, err := tilecache.New(1<<20, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &studioServer{cache: cache}
+
+	serve := func(compute func() ([]byte, error), etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/tile", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		serveErrorOr(func(w http.ResponseWriter, req *http.Request) error {
+			return s.serveCached(w, req, "image/png", "key", compute)
+		})(w, req)
+		return w
+	}
+
+	failed := serve(func() ([]byte, error) { return nil, errors.New("failure") }, "")
+	if failed.Code != http.StatusInternalServerError {
+		t.Errorf("failed compute gave status %d, want %d", failed.Code, http.StatusInternalServerError)
+	}
+	for _, header := range []string{"ETag", "Cache-Control"} {
+		if got := failed.Header().Get(header); got != "" {
+			t.Errorf("failed compute sent %s: %q", header, got)
+		}
+	}
+
+	ok := serve(func() ([]byte, error) { return []byte("tile"), nil }, "")
+	if ok.Code != http.StatusOK || ok.Body.String() != "tile" || ok.Header().Get("ETag") == "" {
+		t.Errorf("successful compute gave status %d, body %q, ETag %q", ok.Code, ok.Body.String(), ok.Header().Get("ETag"))
+	}
+
+	notModified := serve(nil, ok.Header().Get("ETag"))
+	if notModified.Code != http.StatusNotModified || notModified.Header().Get("ETag") == "" {
+		t.Errorf("revalidation gave status %d, ETag %q", notModified.Code, notModified.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/steinarvk/abora/analysis"
+	"github.com/steinarvk/abora/snippet"
+	"github.com/steinarvk/abora/transcribe"
+)
+
+var (
+	inputFile         = flag.String("input", "", "input filename")
+	channel           = flag.String("channel", "average", "channel to analyze: a channel number, \"average\", \"mid\" or \"side\"")
+	sampleRate        = flag.Int("sample_rate", 0, "resample input to this sample rate before analysis (0 to use the input's rate)")
+	outputFile        = flag.String("output", "", "output filename (text proto); if empty, print to stdout")
+	lowFrequency      = flag.Float64("low_freq", 500.0, "lowest frequency of interest")
+	highFrequency     = flag.Float64("high_freq", 5000.0, "highest frequency of interest")
+	windowSizeSeconds = flag.Float64("window_size_seconds", 0.05, "analysis window size (seconds)")
+	analysesPerSecond = flag.Float64("analyses_per_second", 100.0, "number of analysis frames per second")
+	threshold         = flag.Float64("threshold", 0.1, "loudness threshold for notes (relative to near-maximum loudness)")
+	windowFunction    = flag.String("window", "hann", "analysis window: hann, hamming, blackmanharris, rectangular, kaiser[:beta] or gaussian[:sigma]")
+	overlap           = flag.Float64("overlap", 0, "overlap between analysis windows (fraction; overrides the number of analyses per second if non-zero)")
+	maxPitchJump      = flag.Float64("max_pitch_jump", 1.5, "largest pitch jump (semitones) between frames within a note")
+	minNoteSeconds    = flag.Float64("min_note_seconds", 0.05, "shortest note to transcribe (seconds)")
+	pointsPerSecond   = flag.Float64("points_per_second", 50.0, "maximum number of points per second of each chirp")
+)
+
+func mainCore() error {
+	if *inputFile == "" {
+		return errors.New("--input is required")
+	}
+
+	channelOpt, err := snippet.ParseChannel(*channel)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("reading input file %q", *inputFile)
+	snip, err := snippet.Read(*inputFile, channelOpt)
+	if err != nil {
+		return err
+	}
+
+	if *sampleRate > 0 {
+		log.Printf("resampling from %d Hz to %d Hz", snip.SampleRate(), *sampleRate)
+		snip = snippet.Resample(snip, *sampleRate)
+	}
+
+	windowFunc, err := analysis.ParseWindow(*windowFunction)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("transcribing")
+	chirps, err := transcribe.Transcribe(snip, &transcribe.Params{
+		Analysis: &analysis.Params{
+			MinWindowSizeSeconds:     *windowSizeSeconds,
+			Window:                   windowFunc,
+			Overlap:                  *overlap,
+			NumberOfFrequencyBuckets: 1000,
+			Range: &analysis.FrequencyRange{
+				LowHz:  *lowFrequency,
+				HighHz: *highFrequency,
+			},
+			AnalysesPerSecond: *analysesPerSecond,
+		},
+		LoudnessThreshold:     *threshold,
+		MaxPitchJumpSemitones: *maxPitchJump,
+		MinNoteSeconds:        *minNoteSeconds,
+		PointsPerSecond:       *pointsPerSecond,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("found %d notes", len(chirps.Chirp))
+
+	rvText := proto.MarshalTextString(chirps)
+
+	if *outputFile == "" {
+		fmt.Println(rvText)
+		return nil
+	}
+
+	return ioutil.WriteFile(*outputFile, []byte(rvText), 0644)
+}
+
+func main() {
+	flag.Parse()
+
+	if err := mainCore(); err != nil {
+		log.Fatalf("failure: %v", err)
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"flag"
 	"io/ioutil"
 	"log"
+	"math"
 
 	"github.com/golang/protobuf/proto"
 
+	"github.com/steinarvk/abora/resample"
 	"github.com/steinarvk/abora/synth/chirp"
+	"github.com/steinarvk/abora/synth/master"
 	"github.com/steinarvk/abora/synth/mix"
 	"github.com/steinarvk/abora/wav"
 
@@ -17,7 +20,19 @@ import (
 
 var (
 	outputFilename = flag.String("output", "", "output filename")
-	fromProtoFile  = flag.String("proto", "", "proto filename")
+	fromProtoFile  = flag.String("proto", "", "proto filename (a single Chirp)")
+	fromScoreFile  = flag.String("score", "", "score filename (a Chirps message)")
+
+	sampleRate       = flag.Int("sample_rate", 44100, "sample rate of output")
+	renderSampleRate = flag.Int("render_sample_rate", 0, "sample rate at which to synthesize, if different from --sample_rate")
+
+	bitDepth       = flag.Int("bit_depth", 32, "bits per sample of output (16, 24 or 32)")
+	floatOutput    = flag.Bool("float_output", false, "write 32-bit floating point samples")
+	outputChannels = flag.Int("channels", 1, "number of channels of output")
+
+	normalize    = flag.String("normalize", "", "normalization of output: \"peak\" or \"loudness\" (or empty for none)")
+	targetLevel  = flag.Float64("target_level", math.NaN(), "normalization target in dBFS (peak) or LUFS (loudness); defaults to -1 dBFS or -16 LUFS")
+	limitCeiling = flag.Float64("limit", 0, "true-peak limiter ceiling in dBTP (0 to disable)")
 )
 
 func mainCore() error {
@@ -25,11 +40,11 @@ func mainCore() error {
 		return errors.New("--output is required")
 	}
 
-	if *fromProtoFile == "" {
-		return errors.New("--proto is required")
+	if (*fromProtoFile == "") == (*fromScoreFile == "") {
+		return errors.New("exactly one of --proto and --score is required")
 	}
 
-	var chrp *chirp.TimedChirp
+	var chirps []chirp.TimedChirp
 
 	if *fromProtoFile != "" {
 		data, err := ioutil.ReadFile(*fromProtoFile)
@@ -42,20 +57,55 @@ func mainCore() error {
 			return err
 		}
 
-		chrp, err = chirp.FromProto(spec, nil)
+		chrp, err := chirp.FromProto(spec, nil)
+		if err != nil {
+			return err
+		}
+
+		chirps = append(chirps, *chrp)
+	}
+
+	if *fromScoreFile != "" {
+		data, err := ioutil.ReadFile(*fromScoreFile)
+		if err != nil {
+			return err
+		}
+
+		spec := &aborapb.Chirps{}
+		if err := proto.UnmarshalText(string(data), spec); err != nil {
+			return err
+		}
+
+		chirps, err = chirp.ScoreFromProto(spec)
 		if err != nil {
 			return err
 		}
+
+		log.Printf("loaded score with %d chirps", len(chirps))
+	}
+
+	renderRate := *sampleRate
+	if *renderSampleRate > 0 {
+		renderRate = *renderSampleRate
 	}
 
-	sampleRate := 44110
+	samples := mix.Render(chirps, renderRate, 0.0)
+
+	samples = resample.Slice(samples, renderRate, *sampleRate)
+
+	masteringOpts, err := master.Options(*normalize, *targetLevel, *limitCeiling)
+	if err != nil {
+		return err
+	}
+	if len(masteringOpts) > 0 {
+		if err := master.Process(samples, *sampleRate, masteringOpts...); err != nil {
+			return err
+		}
+	}
 
-	ch := mix.AsChannel(
-		[]chirp.TimedChirp{*chrp},
-		sampleRate,
-		0.0)
+	samples = wav.DuplicateSlice(samples, *outputChannels)
 
-	return wav.WriteFile(*outputFilename, sampleRate, ch)
+	return wav.WriteSamples(*outputFilename, *sampleRate, samples, wav.FormatOptions(*bitDepth, *floatOutput, *outputChannels)...)
 }
 
 func main() {
@@ -15,12 +15,16 @@ import (
 
 var (
 	inputFile         = flag.String("input", "", "input filename")
+	channel           = flag.String("channel", "average", "channel to analyze: a channel number, \"average\", \"mid\" or \"side\"")
+	sampleRate        = flag.Int("sample_rate", 0, "resample input to this sample rate before analysis (0 to use the input's rate)")
 	windowSizeSeconds = flag.Float64("window_size_seconds", 0.05, "window size in seconds")
 	pwelchNFFT        = flag.Int("pwelch_nfft", 8192, "PWelchOptions.NFFT")
 	pwelchPad         = flag.Int("pwelch_pad", 8192, "PWelchOptions.Pad")
 	lowFrequency      = flag.Float64("low_freq", 500.0, "lowest frequency of interest")
 	highFrequency     = flag.Float64("high_freq", 5000.0, "highest frequency of interest")
 	outputSpectrogram = flag.String("output_spectrogram", "", "output filename of spectrogram")
+	windowFunction    = flag.String("window", "hann", "analysis window: hann, hamming, blackmanharris, rectangular, kaiser[:beta] or gaussian[:sigma]")
+	overlap           = flag.Float64("overlap", 0, "overlap between analysis windows (fraction; overrides the number of analyses per second if non-zero)")
 )
 
 func rootMeanSquare(xs []float64) float64 {
@@ -47,8 +51,23 @@ func mainCore() error {
 		return errors.New("--input is required")
 	}
 
+	channelOpt, err := snippet.ParseChannel(*channel)
+	if err != nil {
+		return err
+	}
+
 	log.Printf("reading input file %q", *inputFile)
-	snip, err := snippet.Read(*inputFile)
+	snip, err := snippet.Read(*inputFile, channelOpt)
+	if err != nil {
+		return err
+	}
+
+	if *sampleRate > 0 {
+		log.Printf("resampling from %d Hz to %d Hz", snip.SampleRate(), *sampleRate)
+		snip = snippet.Resample(snip, *sampleRate)
+	}
+
+	windowFunc, err := analysis.ParseWindow(*windowFunction)
 	if err != nil {
 		return err
 	}
@@ -56,6 +75,8 @@ func mainCore() error {
 	log.Printf("analyzing")
 	anal, err := analysis.Analyze(snip, &analysis.Params{
 		MinWindowSizeSeconds:     *windowSizeSeconds,
+		Window:                   windowFunc,
+		Overlap:                  *overlap,
 		PwelchPadding:            pwelchPad,
 		NumberOfFrequencyBuckets: 1000,
 		Range: &analysis.FrequencyRange{
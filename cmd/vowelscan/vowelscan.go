@@ -18,6 +18,8 @@ import (
 
 var (
 	inputFile         = flag.String("input", "", "input filename")
+	channel           = flag.String("channel", "average", "channel to analyze: a channel number, \"average\", \"mid\" or \"side\"")
+	sampleRate        = flag.Int("sample_rate", 0, "resample input to this sample rate before analysis (0 to use the input's rate)")
 	beginSeconds      = flag.Float64("begin", 0.0, "beginning of region of interest (seconds)")
 	endSeconds        = flag.Float64("end", 0.0, "end of region of interest (seconds)")
 	lowFrequency      = flag.Float64("low_freq", 100.0, "lowest frequency of interest")
@@ -25,6 +27,8 @@ var (
 	windowSizeSeconds = flag.Float64("window_size_seconds", 0.08, "analysis window size (seconds)")
 	analysesPerSecond = flag.Float64("analyses_per_second", 50.0, "number of analysis frames per second")
 	threshold         = flag.Float64("threshold", 0.001, "threshold for inclusion (relative to largest coefficient)")
+	windowFunction    = flag.String("window", "hann", "analysis window: hann, hamming, blackmanharris, rectangular, kaiser[:beta] or gaussian[:sigma]")
+	overlap           = flag.Float64("overlap", 0, "overlap between analysis windows (fraction; overrides the number of analyses per second if non-zero)")
 )
 
 func mainCore() error {
@@ -42,8 +46,23 @@ func mainCore() error {
 		return fmt.Errorf("too short (duration must be at least %v, got %v)", minDuration, duration)
 	}
 
+	channelOpt, err := snippet.ParseChannel(*channel)
+	if err != nil {
+		return err
+	}
+
 	log.Printf("reading input file %q", *inputFile)
-	snip, err := snippet.Read(*inputFile)
+	snip, err := snippet.Read(*inputFile, channelOpt)
+	if err != nil {
+		return err
+	}
+
+	if *sampleRate > 0 {
+		log.Printf("resampling from %d Hz to %d Hz", snip.SampleRate(), *sampleRate)
+		snip = snippet.Resample(snip, *sampleRate)
+	}
+
+	windowFunc, err := analysis.ParseWindow(*windowFunction)
 	if err != nil {
 		return err
 	}
@@ -52,6 +71,8 @@ func mainCore() error {
 
 	anal, err := analysis.Analyze(snip, &analysis.Params{
 		MinWindowSizeSeconds:     *windowSizeSeconds,
+		Window:                   windowFunc,
+		Overlap:                  *overlap,
 		NumberOfFrequencyBuckets: 1000,
 		Range: &analysis.FrequencyRange{
 			LowHz:  *lowFrequency,
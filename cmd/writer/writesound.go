@@ -5,14 +5,17 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/steinarvk/abora/resample"
 	"github.com/steinarvk/abora/synth/chirp"
 	"github.com/steinarvk/abora/synth/envelope"
 	"github.com/steinarvk/abora/synth/harmonics"
 	"github.com/steinarvk/abora/synth/interpolation"
+	"github.com/steinarvk/abora/synth/master"
 	"github.com/steinarvk/abora/synth/mix"
 	"github.com/steinarvk/abora/synth/oscillator"
 	"github.com/steinarvk/abora/synth/varying"
@@ -38,6 +41,17 @@ var (
 	vibratoFrequency  = flag.Float64("vibrato_frequency", 7.0, "vibrato frequency")
 	tremoloIntensity  = flag.Float64("tremolo_intensity", 0.0, "intensity of vibrato")
 	tremoloFrequency  = flag.Float64("tremolo_frequency", 7.0, "vibrato frequency")
+
+	sampleRate       = flag.Int("sample_rate", 44100, "sample rate of output")
+	renderSampleRate = flag.Int("render_sample_rate", 0, "sample rate at which to synthesize, if different from --sample_rate")
+
+	bitDepth       = flag.Int("bit_depth", 32, "bits per sample of output (16, 24 or 32)")
+	floatOutput    = flag.Bool("float_output", false, "write 32-bit floating point samples")
+	outputChannels = flag.Int("channels", 1, "number of channels of output")
+
+	normalize    = flag.String("normalize", "", "normalization of output: \"peak\" or \"loudness\" (or empty for none)")
+	targetLevel  = flag.Float64("target_level", math.NaN(), "normalization target in dBFS (peak) or LUFS (loudness); defaults to -1 dBFS or -16 LUFS")
+	limitCeiling = flag.Float64("limit", 0, "true-peak limiter ceiling in dBTP (0 to disable)")
 )
 
 type sound struct {
@@ -204,12 +218,12 @@ func parseAdHocFormat(filename string) ([]sound, error) {
 	return rv, nil
 }
 
-func playSounds(sounds []sound, sampleRate int) <-chan float64 {
+func playSounds(sounds []sound, sampleRate int) []float64 {
 	var tc []chirp.TimedChirp
 	for _, snd := range sounds {
 		tc = append(tc, snd.asChirp())
 	}
-	return mix.AsChannel(tc, sampleRate, 0.0)
+	return mix.Render(tc, sampleRate, 0.0)
 }
 
 func mainCore() error {
@@ -222,9 +236,26 @@ func mainCore() error {
 		fmt.Println(sound.String())
 	}
 
-	sampleRate := 44100
+	renderRate := *sampleRate
+	if *renderSampleRate > 0 {
+		renderRate = *renderSampleRate
+	}
+
+	samples := resample.Slice(playSounds(sounds, renderRate), renderRate, *sampleRate)
+
+	masteringOpts, err := master.Options(*normalize, *targetLevel, *limitCeiling)
+	if err != nil {
+		return err
+	}
+	if len(masteringOpts) > 0 {
+		if err := master.Process(samples, *sampleRate, masteringOpts...); err != nil {
+			return err
+		}
+	}
+
+	samples = wav.DuplicateSlice(samples, *outputChannels)
 
-	return wav.WriteFile(*outputFile, sampleRate, playSounds(sounds, sampleRate))
+	return wav.WriteSamples(*outputFile, *sampleRate, samples, wav.FormatOptions(*bitDepth, *floatOutput, *outputChannels)...)
 }
 
 func main() {
@@ -0,0 +1,108 @@
+// Package filter implements simple IIR filters for sample streams.
+package filter
+
+import (
+	"math"
+)
+
+// Biquad is a second-order IIR filter in transposed direct form II,
+// normalized so that a0 = 1.
+type Biquad struct {
+	B0, B1, B2 float64
+	A1, A2     float64
+
+	z1, z2 float64
+}
+
+// Filter processes a single sample.
+func (f *Biquad) Filter(x float64) float64 {
+	y := f.B0*x + f.z1
+	f.z1 = f.B1*x - f.A1*y + f.z2
+	f.z2 = f.B2*x - f.A2*y
+	return y
+}
+
+// FilterSlice processes a slice of samples in place.
+func (f *Biquad) FilterSlice(xs []float64) {
+	for i, x := range xs {
+		xs[i] = f.Filter(x)
+	}
+}
+
+// Reset clears the filter state.
+func (f *Biquad) Reset() {
+	f.z1 = 0
+	f.z2 = 0
+}
+
+// Coefficients after the Audio EQ Cookbook (R. Bristow-Johnson).
+func cookbook(sampleRate int, freq, q float64) (cosw, alpha float64) {
+	w := 2 * math.Pi * freq / float64(sampleRate)
+	return math.Cos(w), math.Sin(w) / (2 * q)
+}
+
+func normalized(b0, b1, b2, a0, a1, a2 float64) *Biquad {
+	return &Biquad{
+		B0: b0 / a0,
+		B1: b1 / a0,
+		B2: b2 / a0,
+		A1: a1 / a0,
+		A2: a2 / a0,
+	}
+}
+
+// LowPass creates a low-pass filter with the given cutoff and Q.
+func LowPass(sampleRate int, freq, q float64) *Biquad {
+	cosw, alpha := cookbook(sampleRate, freq, q)
+	return normalized((1-cosw)/2, 1-cosw, (1-cosw)/2, 1+alpha, -2*cosw, 1-alpha)
+}
+
+// HighPass creates a high-pass filter with the given cutoff and Q.
+func HighPass(sampleRate int, freq, q float64) *Biquad {
+	cosw, alpha := cookbook(sampleRate, freq, q)
+	return normalized((1+cosw)/2, -(1 + cosw), (1+cosw)/2, 1+alpha, -2*cosw, 1-alpha)
+}
+
+// BandPass creates a band-pass filter with unity gain at the centre
+// frequency.
+func BandPass(sampleRate int, freq, q float64) *Biquad {
+	cosw, alpha := cookbook(sampleRate, freq, q)
+	return normalized(alpha, 0, -alpha, 1+alpha, -2*cosw, 1-alpha)
+}
+
+// Chain applies several filters in series.
+type Chain []*Biquad
+
+// Filter processes a single sample.
+func (c Chain) Filter(x float64) float64 {
+	for _, f := range c {
+		x = f.Filter(x)
+	}
+	return x
+}
+
+// FilterSlice processes a slice of samples in place.
+func (c Chain) FilterSlice(xs []float64) {
+	for i, x := range xs {
+		xs[i] = c.Filter(x)
+	}
+}
+
+var (
+	// Q values of the second-order sections of a fourth-order Butterworth
+	// filter.
+	butterworth4 = []float64{0.5411961, 1.3065630}
+)
+
+// Band creates a filter passing frequencies between lowHz and highHz, with
+// fourth-order Butterworth slopes (24 dB/octave) on either side.
+func Band(sampleRate int, lowHz, highHz float64) Chain {
+	var rv Chain
+	for _, q := range butterworth4 {
+		rv = append(rv, HighPass(sampleRate, lowHz, q))
+	}
+	for _, q := range butterworth4 {
+		rv = append(rv, LowPass(sampleRate, highHz, q))
+	}
+	return rv
+}
@@ -35,6 +35,13 @@ func (p *ParamGetter) Int(name string, defValue int) int {
 	return v
 }
 
+func (p *ParamGetter) String(name string, defValue string) string {
+	if v := p.r.URL.Query().Get(name); v != "" {
+		return v
+	}
+	return defValue
+}
+
 func intParam(req *http.Request, name string, defaultValue int) (int, error) {
 	val := req.URL.Query().Get(name)
 	if val == "" {
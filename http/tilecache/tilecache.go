@@ -0,0 +1,180 @@
+// Package tilecache caches rendered results (such as spectrogram tiles)
+// in memory and optionally on disk, computing each missing entry only once
+// even when it is requested concurrently.
+package tilecache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type entry struct {
+	key  string
+	data []byte
+}
+
+// call is a computation in progress, shared by all requests for its key.
+type call struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// Cache is a bounded LRU cache of byte slices.
+type Cache struct {
+	maxBytes int64
+	dir      string
+
+	mu       sync.Mutex
+	bytes    int64
+	lru      *list.List
+	entries  map[string]*list.Element
+	inflight map[string]*call
+}
+
+// New creates a cache holding up to maxBytes of data in memory. If dir is
+// not empty, entries are also stored there and survive restarts; the disk
+// cache is not bounded.
+func New(maxBytes int64, dir string) (*Cache, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &Cache{
+		maxBytes: maxBytes,
+		dir:      dir,
+		lru:      list.New(),
+		entries:  map[string]*list.Element{},
+		inflight: map[string]*call{},
+	}, nil
+}
+
+// Hash returns a short stable digest of a key, suitable for filenames and
+// ETags.
+func Hash(key string) string {
+	digest := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(digest[:16])
+}
+
+// ETag returns the entity tag for the data stored under a key.
+func ETag(key string) string {
+	return fmt.Sprintf("%q", Hash(key))
+}
+
+// Get returns the data for a key, calling compute if it is not cached.
+// Concurrent calls for the same key wait for a single computation.
+// Errors are not cached.
+func (c *Cache) Get(key string, compute func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*entry).data, nil
+	}
+	if cl, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-cl.done
+		return cl.data, cl.err
+	}
+	cl := &call{done: make(chan struct{})}
+	c.inflight[key] = cl
+	c.mu.Unlock()
+
+	// If compute panics, the waiters are released with an error before the
+	// panic propagates.
+	completed := false
+	defer func() {
+		if !completed {
+			cl.err = fmt.Errorf("computing %q panicked", key)
+		}
+		c.mu.Lock()
+		delete(c.inflight, key)
+		if cl.err == nil {
+			c.add(key, cl.data)
+		}
+		c.mu.Unlock()
+		close(cl.done)
+	}()
+
+	cl.data, cl.err = c.load(key, compute)
+	completed = true
+
+	return cl.data, cl.err
+}
+
+func (c *Cache) filename(key string) string {
+	return filepath.Join(c.dir, Hash(key))
+}
+
+// load reads an entry from disk or computes it.
+func (c *Cache) load(key string, compute func() ([]byte, error)) ([]byte, error) {
+	if c.dir != "" {
+		data, err := ioutil.ReadFile(c.filename(key))
+		if err == nil {
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			log.Printf("tile cache: unable to read %q: %v", c.filename(key), err)
+		}
+	}
+
+	data, err := compute()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.dir != "" {
+		if err := c.store(key, data); err != nil {
+			log.Printf("tile cache: unable to store %q: %v", c.filename(key), err)
+		}
+	}
+
+	return data, nil
+}
+
+func (c *Cache) store(key string, data []byte) error {
+	f, err := ioutil.TempFile(c.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), c.filename(key))
+}
+
+// add inserts an entry into the in-memory cache, evicting the least
+// recently used entries as needed. The caller must hold the lock.
+func (c *Cache) add(key string, data []byte) {
+	if int64(len(data)) > c.maxBytes {
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&entry{key: key, data: data})
+	c.bytes += int64(len(data))
+
+	for c.bytes > c.maxBytes {
+		oldest := c.lru.Back()
+		e := oldest.Value.(*entry)
+		c.lru.Remove(oldest)
+		delete(c.entries, e.key)
+		c.bytes -= int64(len(e.data))
+	}
+}
@@ -0,0 +1,151 @@
+package tilecache
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCoalescing(t *testing.T) {
+	c, err := New(1<<20, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var computations int32
+	release := make(chan struct{})
+
+	compute := func() ([]byte, error) {
+		atomic.AddInt32(&computations, 1)
+		<-release
+		return []byte("tile"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := c.Get("key", compute)
+			if err != nil || string(data) != "tile" {
+				t.Errorf("Get() = %q, %v", data, err)
+			}
+		}()
+	}
+
+	// Give the goroutines a chance to pile up on the computation.
+	for atomic.LoadInt32(&computations) == 0 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&computations); n != 1 {
+		t.Errorf("computed %d times, want 1", n)
+	}
+}
+
+func TestEvictionAndErrors(t *testing.T) {
+	c, err := New(10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	computations := 0
+	compute := func(s string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			computations++
+			return []byte(s), nil
+		}
+	}
+
+	c.Get("a", compute("aaaa"))
+	c.Get("b", compute("bbbb"))
+	c.Get("a", compute("aaaa"))
+	c.Get("c", compute("cccc"))
+
+	// "b" was least recently used and should have been evicted.
+	c.Get("a", compute("aaaa"))
+	c.Get("b", compute("bbbb"))
+
+	if computations != 4 {
+		t.Errorf("computed %d times, want 4", computations)
+	}
+
+	failure := errors.New("failure")
+	if _, err := c.Get("d", func() ([]byte, error) { return nil, failure }); err != failure {
+		t.Errorf("Get() = %v, want %v", err, failure)
+	}
+	if data, err := c.Get("d", compute("dd")); err != nil || string(data) != "dd" {
+		t.Errorf("Get() after failure = %q, %v", data, err)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tilecache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c1, err := New(1<<20, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c1.Get("key", func() ([]byte, error) { return []byte("tile"), nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	c2, err := New(1<<20, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c2.Get("key", func() ([]byte, error) {
+		t.Errorf("recomputed entry that should have been on disk")
+		return []byte("other"), nil
+	})
+	if err != nil || string(data) != "tile" {
+		t.Errorf("Get() = %q, %v", data, err)
+	}
+}
+
+func TestPanicReleasesWaiters(t *testing.T) {
+	c, err := New(1<<20, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		c.Get("key", func() ([]byte, error) {
+			close(started)
+			<-release
+			panic("compute failed")
+		})
+	}()
+
+	<-started
+	waiter := make(chan error)
+	go func() {
+		_, err := c.Get("key", func() ([]byte, error) { return []byte("tile"), nil })
+		waiter <- err
+	}()
+	close(release)
+
+	if r := <-panicked; r == nil {
+		t.Errorf("Get() did not propagate the panic")
+	}
+	// The waiter either joined the failed computation or started its own.
+	<-waiter
+
+	if data, err := c.Get("key", func() ([]byte, error) { return []byte("tile"), nil }); err != nil || string(data) != "tile" {
+		t.Errorf("Get() after panic = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,499 @@
+// Package midi converts between chirps and Standard MIDI Files, so that
+// transcriptions can be edited in standard tools.
+//
+// Each chirp becomes a note at the semitone nearest the middle of its
+// frequency range, with pitch bends following its frequency contour. Since
+// pitch bends apply to a whole channel, overlapping chirps are placed on
+// different channels.
+package midi
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/steinarvk/abora/synth/chirp"
+	"github.com/steinarvk/abora/synth/interpolation"
+	"github.com/steinarvk/abora/synth/varying"
+
+	aborapb "github.com/steinarvk/abora/proto"
+)
+
+type option interface {
+	Apply(*settings)
+}
+
+type settings struct {
+	bendRange       float64
+	tempo           float64
+	ticksPerQuarter int
+	referenceA4     float64
+	bendsPerSecond  float64
+}
+
+// BendRange is the pitch bend range in semitones (in either direction).
+// When writing, it is widened as needed to cover the widest chirp. When
+// reading, it is used for channels whose range the file doesn't set.
+type BendRange float64
+
+func (r BendRange) Apply(s *settings) { s.bendRange = float64(r) }
+
+// Tempo sets the tempo of written files, in beats per minute.
+type Tempo float64
+
+func (t Tempo) Apply(s *settings) { s.tempo = float64(t) }
+
+// TicksPerQuarter sets the time resolution of written files.
+type TicksPerQuarter int
+
+func (n TicksPerQuarter) Apply(s *settings) { s.ticksPerQuarter = int(n) }
+
+// ReferenceA4 is the frequency of A4 (note 69) in Hz.
+type ReferenceA4 float64
+
+func (f ReferenceA4) Apply(s *settings) { s.referenceA4 = float64(f) }
+
+// BendsPerSecond is the rate at which the frequency contour of a chirp is
+// sampled when writing. Pitch bends are only written when they change.
+type BendsPerSecond float64
+
+func (n BendsPerSecond) Apply(s *settings) { s.bendsPerSecond = float64(n) }
+
+var (
+	defaultSettings = settings{
+		bendRange:       2,
+		tempo:           120,
+		ticksPerQuarter: 480,
+		referenceA4:     440,
+		bendsPerSecond:  100,
+	}
+
+	// Imported notes are rendered as plain tones with a short attack and
+	// release.
+	defaultsContext = &aborapb.Context{
+		Oscillator: &aborapb.Oscillator{
+			Oscillators: &aborapb.Oscillator_Sine{},
+		},
+		Envelope: &aborapb.Envelope{
+			EnvelopeKind: &aborapb.Envelope_Adsr{
+				Adsr: &aborapb.ADSREnvelope{
+					AttackDuration:  0.02,
+					DecayDuration:   0.0,
+					SustainLevel:    1.0,
+					ReleaseDuration: 0.02,
+				},
+			},
+		},
+	}
+)
+
+func settingsFor(opts []option) (settings, error) {
+	s := defaultSettings
+	for _, opt := range opts {
+		opt.Apply(&s)
+	}
+
+	switch {
+	case s.bendRange <= 0 || s.bendRange >= 128:
+		return s, fmt.Errorf("invalid pitch bend range %v semitones", s.bendRange)
+	case s.tempo <= 0:
+		return s, fmt.Errorf("invalid tempo %v", s.tempo)
+	case s.referenceA4 <= 0:
+		return s, fmt.Errorf("invalid reference frequency %v", s.referenceA4)
+	case s.bendsPerSecond <= 0:
+		return s, fmt.Errorf("invalid number of pitch bends per second %v", s.bendsPerSecond)
+	}
+
+	return s, nil
+}
+
+func (s settings) semitone(hz float64) float64 {
+	return 69 + 12*math.Log2(hz/s.referenceA4)
+}
+
+func (s settings) frequency(semitone float64) float64 {
+	return s.referenceA4 * math.Pow(2, (semitone-69)/12)
+}
+
+func value(x float64) *aborapb.DoubleOrHold {
+	return &aborapb.DoubleOrHold{
+		ValueOrHold: &aborapb.DoubleOrHold_Value{
+			Value: x,
+		},
+	}
+}
+
+// valueAt interpolates between points as the synth does, holding the last
+// value.
+func valueAt(points []varying.Point, t float64) float64 {
+	i := sort.Search(len(points), func(i int) bool { return points[i].Time > t })
+	switch {
+	case i == 0:
+		return points[0].Value
+	case i == len(points):
+		return points[len(points)-1].Value
+	}
+	p0, p1 := points[i-1], points[i]
+	u := (t - p0.Time) / (p1.Time - p0.Time)
+	if p0.Curve != nil {
+		return p0.Curve(u, varying.Segment(points, i-1))
+	}
+	return interpolation.Linear(u, p0.Value, p1.Value)
+}
+
+func clamp(x, low, high int) int {
+	if x < low {
+		return low
+	}
+	if x > high {
+		return high
+	}
+	return x
+}
+
+// note is a chirp quantized to a MIDI note.
+type note struct {
+	begin, end int64
+	key        byte
+	velocity   byte
+	bends      []bend
+}
+
+type bend struct {
+	tick      int64
+	semitones float64
+}
+
+type notesByBegin []*note
+
+func (n notesByBegin) Len() int           { return len(n) }
+func (n notesByBegin) Less(i, j int) bool { return n[i].begin < n[j].begin }
+func (n notesByBegin) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+
+type chirpsByBegin []*aborapb.Chirp
+
+func (c chirpsByBegin) Len() int           { return len(c) }
+func (c chirpsByBegin) Less(i, j int) bool { return c[i].BeginTime < c[j].BeginTime }
+func (c chirpsByBegin) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+
+func (s settings) quantize(c *aborapb.Chirp, defaults *aborapb.Context, toTick func(float64) int64) (*note, error) {
+	if c.Duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive (got %v)", c.Duration)
+	}
+
+	freq, amplitude, err := chirp.Contours(c, defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	low, high := math.Inf(1), math.Inf(-1)
+	times := []float64{}
+	for _, p := range freq {
+		if p.Time > c.Duration {
+			continue
+		}
+		if p.Value <= 0 {
+			return nil, fmt.Errorf("non-positive frequency %v at t=%v", p.Value, p.Time)
+		}
+		semitone := s.semitone(p.Value)
+		low = math.Min(low, semitone)
+		high = math.Max(high, semitone)
+		times = append(times, p.Time)
+	}
+
+	loudest := 0.0
+	for _, p := range amplitude {
+		loudest = math.Max(loudest, p.Value)
+	}
+
+	rv := &note{
+		begin:    toTick(c.BeginTime),
+		end:      toTick(c.BeginTime + c.Duration),
+		key:      byte(clamp(int(math.Floor((low+high)/2+0.5)), 0, 127)),
+		velocity: byte(clamp(int(math.Floor(loudest*127+0.5)), 1, 127)),
+	}
+	if rv.end <= rv.begin {
+		rv.end = rv.begin + 1
+	}
+
+	step := 1.0 / s.bendsPerSecond
+	for t := 0.0; t < c.Duration; t += step {
+		times = append(times, t)
+	}
+	sort.Float64s(times)
+
+	for _, t := range times {
+		rv.bends = append(rv.bends, bend{
+			tick:      toTick(c.BeginTime + t),
+			semitones: s.semitone(valueAt(freq, t)) - float64(rv.key),
+		})
+	}
+
+	return rv, nil
+}
+
+// Encode writes chirps as a (format 0) Standard MIDI File.
+func Encode(w io.Writer, chirps *aborapb.Chirps, opts ...option) error {
+	s, err := settingsFor(opts)
+	if err != nil {
+		return err
+	}
+
+	ticksPerSecond := float64(s.ticksPerQuarter) * s.tempo / 60
+	toTick := func(t float64) int64 {
+		return int64(math.Floor(t*ticksPerSecond + 0.5))
+	}
+
+	var notes []*note
+	for i, c := range chirps.GetChirp() {
+		n, err := s.quantize(c, chirps.GetDefaults(), toTick)
+		if err != nil {
+			return fmt.Errorf("chirp #%d (at %vs): %v", i, c.BeginTime, err)
+		}
+		notes = append(notes, n)
+	}
+	sort.Stable(notesByBegin(notes))
+
+	// Rather than cut glides short, the bend range is widened to cover them.
+	for _, n := range notes {
+		for _, b := range n.bends {
+			if width := math.Abs(b.semitones); width > s.bendRange {
+				s.bendRange = math.Ceil(width)
+			}
+		}
+	}
+	if s.bendRange >= 128 {
+		return fmt.Errorf("chirps bend by up to %v semitones, more than MIDI allows", s.bendRange)
+	}
+
+	var noteEvents []event
+
+	// Each note gets the channel that has been free for the longest.
+	var freeFrom [16]int64
+	var used [16]bool
+	for _, n := range notes {
+		ch := -1
+		for c := range freeFrom {
+			if c == percussionChannel || freeFrom[c] > n.begin {
+				continue
+			}
+			if ch < 0 || freeFrom[c] < freeFrom[ch] {
+				ch = c
+			}
+		}
+		if ch < 0 {
+			return fmt.Errorf("more than %d chirps sound at once at tick %d", len(freeFrom)-1, n.begin)
+		}
+		freeFrom[ch] = n.end
+		used[ch] = true
+
+		status := byte(ch)
+		last := -1
+		for _, b := range n.bends {
+			v := clamp(8192+int(math.Floor(b.semitones/s.bendRange*8192+0.5)), 0, 16383)
+			if v == last {
+				continue
+			}
+			noteEvents = append(noteEvents, event{tick: b.tick, status: pitchBend | status, data: []byte{byte(v & 0x7f), byte(v >> 7)}})
+			last = v
+		}
+		noteEvents = append(noteEvents,
+			event{tick: n.begin, status: noteOn | status, data: []byte{n.key, n.velocity}},
+			event{tick: n.end, status: noteOff | status, data: []byte{n.key, 0}})
+	}
+
+	f := &smf{ticksPerQuarter: s.ticksPerQuarter}
+
+	micros := int(math.Floor(60e6/s.tempo + 0.5))
+	f.events = append(f.events, event{
+		status: metaEvent,
+		data:   []byte{metaTempo, byte(micros >> 16), byte(micros >> 8), byte(micros)},
+	})
+
+	// Set the pitch bend range of each channel used before anything else.
+	semitones := math.Floor(s.bendRange)
+	cents := math.Floor((s.bendRange-semitones)*100 + 0.5)
+	for ch, ok := range used {
+		if !ok {
+			continue
+		}
+		status := controlChange | byte(ch)
+		for _, cc := range [][]byte{
+			{ccRPNMSB, 0}, {ccRPNLSB, 0},
+			{ccDataEntry, byte(semitones)}, {ccDataEntryLSB, byte(cents)},
+			{ccRPNMSB, 127}, {ccRPNLSB, 127},
+		} {
+			f.events = append(f.events, event{status: status, data: cc})
+		}
+	}
+
+	f.events = append(f.events, noteEvents...)
+
+	return f.write(w)
+}
+
+// Decode reads the notes and pitch bends of a Standard MIDI File as
+// chirps. Pitch bends become frequency points, between which the synth
+// interpolates.
+func Decode(r io.Reader, opts ...option) (*aborapb.Chirps, error) {
+	s, err := settingsFor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := readSMF(r)
+	if err != nil {
+		return nil, err
+	}
+
+	tempo := newTempoMap(f)
+
+	type channelState struct {
+		bend      float64
+		bendRange float64
+		rpn       [2]byte
+	}
+
+	type activeNote struct {
+		chirp *aborapb.Chirp
+		key   byte
+	}
+
+	var channels [16]channelState
+	for i := range channels {
+		channels[i].bendRange = s.bendRange
+		channels[i].rpn = [2]byte{127, 127}
+	}
+
+	rv := &aborapb.Chirps{
+		Defaults: defaultsContext,
+	}
+
+	// Notes that are playing, by channel and key, in the order they started.
+	active := map[[2]byte][]*activeNote{}
+
+	addPoint := func(c *aborapb.Chirp, t, freq float64) {
+		t -= c.BeginTime
+		if n := len(c.Points); n > 0 && c.Points[n-1].T >= t {
+			c.Points[n-1].Settings.Freq = value(freq)
+			return
+		}
+		c.Points = append(c.Points, &aborapb.Point{
+			T:        t,
+			Settings: &aborapb.PointSettings{Freq: value(freq)},
+		})
+	}
+
+	finish := func(n *activeNote, t float64) {
+		c := n.chirp
+		c.Duration = t - c.BeginTime
+		if c.Duration <= 0 {
+			return
+		}
+		last := c.Points[len(c.Points)-1]
+		if last.T < c.Duration {
+			addPoint(c, t, last.Settings.Freq.GetValue())
+		}
+		rv.Chirp = append(rv.Chirp, c)
+	}
+
+	var end float64
+	for _, e := range f.events {
+		t := tempo.seconds(e.tick)
+		end = t
+
+		if e.status == metaEvent {
+			continue
+		}
+
+		state := &channels[e.channel()]
+		key := [2]byte{e.channel(), e.data[0]}
+
+		switch {
+		case e.kind() == noteOn && e.data[1] > 0:
+			c := &aborapb.Chirp{
+				BeginTime: t,
+				Points: []*aborapb.Point{{
+					Settings: &aborapb.PointSettings{
+						Freq:      value(s.frequency(float64(e.data[0]) + state.bend)),
+						Amplitude: value(float64(e.data[1]) / 127),
+					},
+				}},
+			}
+			active[key] = append(active[key], &activeNote{chirp: c, key: e.data[0]})
+
+		case e.kind() == noteOn || e.kind() == noteOff:
+			if notes := active[key]; len(notes) > 0 {
+				finish(notes[0], t)
+				active[key] = notes[1:]
+			}
+
+		case e.kind() == pitchBend:
+			v := int(e.data[0]) | int(e.data[1])<<7
+			state.bend = float64(v-8192) / 8192 * state.bendRange
+			for k, notes := range active {
+				if k[0] != e.channel() {
+					continue
+				}
+				for _, n := range notes {
+					addPoint(n.chirp, t, s.frequency(float64(n.key)+state.bend))
+				}
+			}
+
+		case e.kind() == controlChange:
+			switch e.data[0] {
+			case ccRPNMSB:
+				state.rpn[0] = e.data[1]
+			case ccRPNLSB:
+				state.rpn[1] = e.data[1]
+			case ccDataEntry:
+				if state.rpn == [2]byte{0, 0} {
+					state.bendRange = float64(e.data[1])
+				}
+			case ccDataEntryLSB:
+				if state.rpn == [2]byte{0, 0} {
+					state.bendRange = math.Floor(state.bendRange) + float64(e.data[1])/100
+				}
+			}
+		}
+	}
+
+	// Notes that are never released end with the file.
+	for _, notes := range active {
+		for _, n := range notes {
+			finish(n, end)
+		}
+	}
+
+	sort.Stable(chirpsByBegin(rv.Chirp))
+
+	return rv, nil
+}
+
+// WriteFile writes chirps to a Standard MIDI File.
+func WriteFile(filename string, chirps *aborapb.Chirps, opts ...option) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := Encode(f, chirps, opts...); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// ReadFile reads chirps from a Standard MIDI File.
+func ReadFile(filename string, opts ...option) (*aborapb.Chirps, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Decode(f, opts...)
+}
@@ -0,0 +1,119 @@
+package midi
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/steinarvk/abora/synth/chirp"
+
+	aborapb "github.com/steinarvk/abora/proto"
+)
+
+func testChirp(begin, duration, amplitude float64, freqs ...float64) *aborapb.Chirp {
+	rv := &aborapb.Chirp{
+		BeginTime: begin,
+		Duration:  duration,
+	}
+	for i, f := range freqs {
+		settings := &aborapb.PointSettings{Freq: value(f)}
+		if i == 0 {
+			settings.Amplitude = value(amplitude)
+		}
+		rv.Points = append(rv.Points, &aborapb.Point{
+			T:        duration * float64(i) / float64(len(freqs)-1),
+			Settings: settings,
+		})
+	}
+	return rv
+}
+
+func TestRoundTrip(t *testing.T) {
+	original := &aborapb.Chirps{
+		Chirp: []*aborapb.Chirp{
+			testChirp(0.5, 1.0, 0.5, 440, 660),
+			// Overlaps the first, so needs a channel of its own.
+			testChirp(0.75, 0.5, 0.25, 880, 830, 880),
+			testChirp(2.0, 0.25, 1.0, 523.25, 523.25),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, original, BendRange(12)); err != nil {
+		t.Fatalf("Encode() = %v", err)
+	}
+
+	// The bend range is read from the file.
+	decoded, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+
+	if len(decoded.Chirp) != len(original.Chirp) {
+		t.Fatalf("decoded %d chirps, want %d: %v", len(decoded.Chirp), len(original.Chirp), decoded)
+	}
+
+	const timeTolerance = 0.002
+
+	for i, want := range original.Chirp {
+		got := decoded.Chirp[i]
+
+		if math.Abs(got.BeginTime-want.BeginTime) > timeTolerance || math.Abs(got.Duration-want.Duration) > timeTolerance {
+			t.Errorf("chirp #%d at %v for %v, want %v for %v", i, got.BeginTime, got.Duration, want.BeginTime, want.Duration)
+		}
+
+		wantFreq, wantAmp, err := chirp.Contours(want, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotFreq, gotAmp, err := chirp.Contours(got, decoded.Defaults)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if math.Abs(gotAmp[0].Value-wantAmp[0].Value) > 1.0/127 {
+			t.Errorf("chirp #%d has amplitude %v, want %v", i, gotAmp[0].Value, wantAmp[0].Value)
+		}
+
+		for tm := 0.01; tm < want.Duration-0.01; tm += 0.01 {
+			offset := got.BeginTime - want.BeginTime
+			w := valueAt(wantFreq, tm)
+			g := valueAt(gotFreq, tm-offset)
+			if cents := 1200 * math.Abs(math.Log2(g/w)); cents > 2 {
+				t.Errorf("chirp #%d at t=%.2f has frequency %v, want %v (off by %.1f cents)", i, tm, g, w, cents)
+			}
+		}
+	}
+}
+
+func TestOctaveGlide(t *testing.T) {
+	original := &aborapb.Chirps{
+		Chirp: []*aborapb.Chirp{
+			testChirp(0, 1.0, 0.5, 440, 880),
+		},
+	}
+
+	// The default bend range of two semitones is widened to fit.
+	var buf bytes.Buffer
+	if err := Encode(&buf, original); err != nil {
+		t.Fatalf("Encode() = %v", err)
+	}
+
+	decoded, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	if len(decoded.Chirp) != 1 {
+		t.Fatalf("decoded %d chirps, want 1: %v", len(decoded.Chirp), decoded)
+	}
+
+	freq, _, err := chirp.Contours(decoded.Chirp[0], decoded.Defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ t, want float64 }{{0.01, 440}, {0.99, 880}} {
+		if got := valueAt(freq, c.t); math.Abs(1200*math.Log2(got/c.want)) > 25 {
+			t.Errorf("frequency at t=%v is %v, want about %v", c.t, got, c.want)
+		}
+	}
+}
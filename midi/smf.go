@@ -0,0 +1,361 @@
+package midi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+const (
+	noteOff       = 0x80
+	noteOn        = 0x90
+	controlChange = 0xb0
+	pitchBend     = 0xe0
+
+	metaEvent   = 0xff
+	sysexEvent  = 0xf0
+	sysexEscape = 0xf7
+
+	metaTempo      = 0x51
+	metaEndOfTrack = 0x2f
+
+	// Controllers used to set the pitch bend range (RPN 0).
+	ccDataEntry    = 6
+	ccDataEntryLSB = 38
+	ccRPNLSB       = 100
+	ccRPNMSB       = 101
+
+	// Percussion is conventionally on channel 10, which can't bend.
+	percussionChannel = 9
+)
+
+// event is a MIDI event at an absolute time in ticks. For channel events,
+// status includes the channel; for meta events, status is metaEvent and
+// data begins with the meta event type.
+type event struct {
+	tick   int64
+	status byte
+	data   []byte
+}
+
+func (e event) kind() byte    { return e.status & 0xf0 }
+func (e event) channel() byte { return e.status & 0x0f }
+
+// order places note-offs before other events at the same tick, so that a
+// channel can be reused immediately.
+func (e event) order() int {
+	switch {
+	case e.status == metaEvent:
+		return 0
+	case e.kind() == noteOff:
+		return 1
+	case e.kind() == noteOn:
+		return 3
+	default:
+		return 2
+	}
+}
+
+type eventsByTime []event
+
+func (e eventsByTime) Len() int      { return len(e) }
+func (e eventsByTime) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e eventsByTime) Less(i, j int) bool {
+	if e[i].tick != e[j].tick {
+		return e[i].tick < e[j].tick
+	}
+	return e[i].order() < e[j].order()
+}
+
+// smf is the contents of a Standard MIDI File, with the events of all its
+// tracks merged.
+type smf struct {
+	ticksPerQuarter int
+	events          []event
+}
+
+func appendVarint(buf []byte, x uint32) []byte {
+	var tmp [5]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(x & 0x7f)
+	for x >>= 7; x > 0; x >>= 7 {
+		i--
+		tmp[i] = byte(x&0x7f) | 0x80
+	}
+	return append(buf, tmp[i:]...)
+}
+
+func appendChunk(buf []byte, kind string, data []byte) []byte {
+	buf = append(buf, kind...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(data)))
+	return append(buf, data...)
+}
+
+// write encodes the file as format 0, i.e. a single track.
+func (f *smf) write(w io.Writer) error {
+	if f.ticksPerQuarter <= 0 || f.ticksPerQuarter > 0x7fff {
+		return fmt.Errorf("invalid number of ticks per quarter note %d", f.ticksPerQuarter)
+	}
+
+	events := append([]event{}, f.events...)
+	sort.Stable(eventsByTime(events))
+
+	var track []byte
+	var last int64
+	for _, e := range events {
+		if e.tick < 0 {
+			return fmt.Errorf("event at negative tick %d", e.tick)
+		}
+		track = appendVarint(track, uint32(e.tick-last))
+		track = append(track, e.status)
+		if e.status == metaEvent {
+			track = append(track, e.data[0])
+			track = appendVarint(track, uint32(len(e.data)-1))
+			track = append(track, e.data[1:]...)
+		} else {
+			track = append(track, e.data...)
+		}
+		last = e.tick
+	}
+	track = append(track, 0, metaEvent, metaEndOfTrack, 0)
+
+	var header []byte
+	header = binary.BigEndian.AppendUint16(header, 0)
+	header = binary.BigEndian.AppendUint16(header, 1)
+	header = binary.BigEndian.AppendUint16(header, uint16(f.ticksPerQuarter))
+
+	var rv []byte
+	rv = appendChunk(rv, "MThd", header)
+	rv = appendChunk(rv, "MTrk", track)
+
+	_, err := w.Write(rv)
+	return err
+}
+
+// trackReader decodes the events of a single track.
+type trackReader struct {
+	data []byte
+	pos  int
+}
+
+var errTruncated = errors.New("truncated MIDI track")
+
+func (r *trackReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errTruncated
+	}
+	r.pos++
+	return r.data[r.pos-1], nil
+}
+
+func (r *trackReader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, errTruncated
+	}
+	r.pos += n
+	return r.data[r.pos-n : r.pos], nil
+}
+
+func (r *trackReader) varint() (uint32, error) {
+	var rv uint32
+	for i := 0; i < 4; i++ {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		rv = rv<<7 | uint32(b&0x7f)
+		if b&0x80 == 0 {
+			return rv, nil
+		}
+	}
+	return 0, errors.New("variable-length quantity too long")
+}
+
+// channelDataBytes is the number of data bytes of each kind of channel
+// event.
+func channelDataBytes(status byte) int {
+	switch status & 0xf0 {
+	case 0xc0, 0xd0:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func readTrack(data []byte) ([]event, error) {
+	r := &trackReader{data: data}
+
+	var rv []event
+	var tick int64
+	var running byte
+
+	for r.pos < len(r.data) {
+		delta, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		tick += int64(delta)
+
+		status, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case status == metaEvent:
+			kind, err := r.byte()
+			if err != nil {
+				return nil, err
+			}
+			n, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			payload, err := r.bytes(int(n))
+			if err != nil {
+				return nil, err
+			}
+			if kind == metaEndOfTrack {
+				return rv, nil
+			}
+			rv = append(rv, event{tick: tick, status: metaEvent, data: append([]byte{kind}, payload...)})
+
+		case status == sysexEvent || status == sysexEscape:
+			n, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := r.bytes(int(n)); err != nil {
+				return nil, err
+			}
+
+		default:
+			var data []byte
+			if status < 0x80 {
+				// Running status: this was the first data byte.
+				if running == 0 {
+					return nil, errors.New("data byte without status in MIDI track")
+				}
+				data = append(data, status)
+				status = running
+			} else {
+				running = status
+			}
+			for len(data) < channelDataBytes(status) {
+				b, err := r.byte()
+				if err != nil {
+					return nil, err
+				}
+				data = append(data, b)
+			}
+			rv = append(rv, event{tick: tick, status: status, data: data})
+		}
+	}
+
+	return rv, nil
+}
+
+// readSMF reads a Standard MIDI File of format 0 or 1.
+func readSMF(r io.Reader) (*smf, error) {
+	data, err := ioutil.ReadAll(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &smf{}
+	sawHeader := false
+
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errors.New("truncated MIDI chunk header")
+		}
+		kind := string(data[:4])
+		size := int(binary.BigEndian.Uint32(data[4:8]))
+		if size < 0 || 8+size > len(data) {
+			return nil, fmt.Errorf("truncated MIDI chunk %q", kind)
+		}
+		body := data[8 : 8+size]
+		data = data[8+size:]
+
+		switch kind {
+		case "MThd":
+			if len(body) < 6 {
+				return nil, errors.New("MIDI header too short")
+			}
+			format := binary.BigEndian.Uint16(body[0:2])
+			division := binary.BigEndian.Uint16(body[4:6])
+			if format > 1 {
+				return nil, fmt.Errorf("unsupported MIDI file format %d", format)
+			}
+			if division&0x8000 != 0 {
+				return nil, errors.New("SMPTE time division is not supported")
+			}
+			rv.ticksPerQuarter = int(division)
+			sawHeader = true
+
+		case "MTrk":
+			if !sawHeader {
+				return nil, errors.New("MIDI track before header")
+			}
+			events, err := readTrack(body)
+			if err != nil {
+				return nil, err
+			}
+			rv.events = append(rv.events, events...)
+		}
+	}
+
+	if !sawHeader {
+		return nil, errors.New("not a MIDI file (no header)")
+	}
+	if rv.ticksPerQuarter == 0 {
+		return nil, errors.New("MIDI file has zero ticks per quarter note")
+	}
+
+	sort.Stable(eventsByTime(rv.events))
+	return rv, nil
+}
+
+// tempoMap converts between ticks and seconds, following the tempo
+// changes of a file.
+type tempoMap struct {
+	ticksPerQuarter int
+	changes         []tempoChange
+}
+
+type tempoChange struct {
+	tick              int64
+	seconds           float64
+	secondsPerQuarter float64
+}
+
+const defaultSecondsPerQuarter = 0.5
+
+func newTempoMap(f *smf) *tempoMap {
+	rv := &tempoMap{
+		ticksPerQuarter: f.ticksPerQuarter,
+		changes:         []tempoChange{{secondsPerQuarter: defaultSecondsPerQuarter}},
+	}
+	for _, e := range f.events {
+		if e.status != metaEvent || e.data[0] != metaTempo || len(e.data) != 4 {
+			continue
+		}
+		micros := int(e.data[1])<<16 | int(e.data[2])<<8 | int(e.data[3])
+		rv.changes = append(rv.changes, tempoChange{
+			tick:              e.tick,
+			seconds:           rv.seconds(e.tick),
+			secondsPerQuarter: float64(micros) / 1e6,
+		})
+	}
+	return rv
+}
+
+func (m *tempoMap) seconds(tick int64) float64 {
+	i := sort.Search(len(m.changes), func(i int) bool { return m.changes[i].tick > tick }) - 1
+	c := m.changes[i]
+	return c.seconds + float64(tick-c.tick)*c.secondsPerQuarter/float64(m.ticksPerQuarter)
+}
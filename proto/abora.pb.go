@@ -13,14 +13,19 @@ It has these top-level messages:
 	Spectrum
 	DoubleOrHold
 	NoOptions
+	PulseOptions
 	Oscillator
 	PointSettings
 	Point
 	ADSREnvelope
+	Breakpoint
+	BreakpointEnvelope
 	Envelope
 	Context
 	Chirp
 	Chirps
+	EditorState
+	Project
 */
 package aborapb
 
@@ -37,6 +42,45 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 const _ = proto.ProtoPackageIsVersion1
 
+// Shape of the transition from one level to the next.
+type Curve int32
+
+const (
+	Curve_LINEAR Curve = 0
+	Curve_COSINE Curve = 1
+	// Fast at first, then settling towards the next level.
+	Curve_EXPONENTIAL Curve = 2
+	// Stays at the first level until the next point.
+	Curve_HOLD Curve = 3
+	// Linear in the logarithm of the value: for frequencies, a constant
+	// number of cents per second.
+	Curve_LOG_FREQUENCY Curve = 4
+	// Smooth spline through the neighbouring points; may overshoot.
+	Curve_CATMULL_ROM Curve = 5
+)
+
+var Curve_name = map[int32]string{
+	0: "LINEAR",
+	1: "COSINE",
+	2: "EXPONENTIAL",
+	3: "HOLD",
+	4: "LOG_FREQUENCY",
+	5: "CATMULL_ROM",
+}
+var Curve_value = map[string]int32{
+	"LINEAR":        0,
+	"COSINE":        1,
+	"EXPONENTIAL":   2,
+	"HOLD":          3,
+	"LOG_FREQUENCY": 4,
+	"CATMULL_ROM":   5,
+}
+
+func (x Curve) String() string {
+	return proto.EnumName(Curve_name, int32(x))
+}
+func (Curve) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
 type SpectrumPoint struct {
 	Amplitude     float64 `protobuf:"fixed64,1,opt,name=amplitude" json:"amplitude,omitempty"`
 	Frequency     float64 `protobuf:"fixed64,2,opt,name=frequency" json:"frequency,omitempty"`
@@ -190,18 +234,31 @@ func (m *NoOptions) String() string            { return proto.CompactTextString(
 func (*NoOptions) ProtoMessage()               {}
 func (*NoOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
 
+type PulseOptions struct {
+	// Fraction of each cycle spent high; 0.5 (or unset) gives a square wave.
+	Width float64 `protobuf:"fixed64,1,opt,name=width" json:"width,omitempty"`
+}
+
+func (m *PulseOptions) Reset()                    { *m = PulseOptions{} }
+func (m *PulseOptions) String() string            { return proto.CompactTextString(m) }
+func (*PulseOptions) ProtoMessage()               {}
+func (*PulseOptions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
 type Oscillator struct {
 	// Types that are valid to be assigned to Oscillators:
 	//	*Oscillator_Sine
 	//	*Oscillator_Square
 	//	*Oscillator_Spectrum
+	//	*Oscillator_Sawtooth
+	//	*Oscillator_Triangle
+	//	*Oscillator_Pulse
 	Oscillators isOscillator_Oscillators `protobuf_oneof:"Oscillators"`
 }
 
 func (m *Oscillator) Reset()                    { *m = Oscillator{} }
 func (m *Oscillator) String() string            { return proto.CompactTextString(m) }
 func (*Oscillator) ProtoMessage()               {}
-func (*Oscillator) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*Oscillator) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 type isOscillator_Oscillators interface {
 	isOscillator_Oscillators()
@@ -216,10 +273,22 @@ type Oscillator_Square struct {
 type Oscillator_Spectrum struct {
 	Spectrum *Spectrum `protobuf:"bytes,3,opt,name=spectrum,oneof"`
 }
+type Oscillator_Sawtooth struct {
+	Sawtooth *NoOptions `protobuf:"bytes,4,opt,name=sawtooth,oneof"`
+}
+type Oscillator_Triangle struct {
+	Triangle *NoOptions `protobuf:"bytes,5,opt,name=triangle,oneof"`
+}
+type Oscillator_Pulse struct {
+	Pulse *PulseOptions `protobuf:"bytes,6,opt,name=pulse,oneof"`
+}
 
 func (*Oscillator_Sine) isOscillator_Oscillators()     {}
 func (*Oscillator_Square) isOscillator_Oscillators()   {}
 func (*Oscillator_Spectrum) isOscillator_Oscillators() {}
+func (*Oscillator_Sawtooth) isOscillator_Oscillators() {}
+func (*Oscillator_Triangle) isOscillator_Oscillators() {}
+func (*Oscillator_Pulse) isOscillator_Oscillators()    {}
 
 func (m *Oscillator) GetOscillators() isOscillator_Oscillators {
 	if m != nil {
@@ -249,12 +318,36 @@ func (m *Oscillator) GetSpectrum() *Spectrum {
 	return nil
 }
 
+func (m *Oscillator) GetSawtooth() *NoOptions {
+	if x, ok := m.GetOscillators().(*Oscillator_Sawtooth); ok {
+		return x.Sawtooth
+	}
+	return nil
+}
+
+func (m *Oscillator) GetTriangle() *NoOptions {
+	if x, ok := m.GetOscillators().(*Oscillator_Triangle); ok {
+		return x.Triangle
+	}
+	return nil
+}
+
+func (m *Oscillator) GetPulse() *PulseOptions {
+	if x, ok := m.GetOscillators().(*Oscillator_Pulse); ok {
+		return x.Pulse
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*Oscillator) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
 	return _Oscillator_OneofMarshaler, _Oscillator_OneofUnmarshaler, _Oscillator_OneofSizer, []interface{}{
 		(*Oscillator_Sine)(nil),
 		(*Oscillator_Square)(nil),
 		(*Oscillator_Spectrum)(nil),
+		(*Oscillator_Sawtooth)(nil),
+		(*Oscillator_Triangle)(nil),
+		(*Oscillator_Pulse)(nil),
 	}
 }
 
@@ -277,6 +370,21 @@ func _Oscillator_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
 		if err := b.EncodeMessage(x.Spectrum); err != nil {
 			return err
 		}
+	case *Oscillator_Sawtooth:
+		b.EncodeVarint(4<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Sawtooth); err != nil {
+			return err
+		}
+	case *Oscillator_Triangle:
+		b.EncodeVarint(5<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Triangle); err != nil {
+			return err
+		}
+	case *Oscillator_Pulse:
+		b.EncodeVarint(6<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Pulse); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("Oscillator.Oscillators has unexpected type %T", x)
@@ -311,6 +419,30 @@ func _Oscillator_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buf
 		err := b.DecodeMessage(msg)
 		m.Oscillators = &Oscillator_Spectrum{msg}
 		return true, err
+	case 4: // Oscillators.sawtooth
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(NoOptions)
+		err := b.DecodeMessage(msg)
+		m.Oscillators = &Oscillator_Sawtooth{msg}
+		return true, err
+	case 5: // Oscillators.triangle
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(NoOptions)
+		err := b.DecodeMessage(msg)
+		m.Oscillators = &Oscillator_Triangle{msg}
+		return true, err
+	case 6: // Oscillators.pulse
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(PulseOptions)
+		err := b.DecodeMessage(msg)
+		m.Oscillators = &Oscillator_Pulse{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -335,6 +467,21 @@ func _Oscillator_OneofSizer(msg proto.Message) (n int) {
 		n += proto.SizeVarint(3<<3 | proto.WireBytes)
 		n += proto.SizeVarint(uint64(s))
 		n += s
+	case *Oscillator_Sawtooth:
+		s := proto.Size(x.Sawtooth)
+		n += proto.SizeVarint(4<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Oscillator_Triangle:
+		s := proto.Size(x.Triangle)
+		n += proto.SizeVarint(5<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Oscillator_Pulse:
+		s := proto.Size(x.Pulse)
+		n += proto.SizeVarint(6<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
 	case nil:
 	default:
 		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
@@ -354,7 +501,7 @@ type PointSettings struct {
 func (m *PointSettings) Reset()                    { *m = PointSettings{} }
 func (m *PointSettings) String() string            { return proto.CompactTextString(m) }
 func (*PointSettings) ProtoMessage()               {}
-func (*PointSettings) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*PointSettings) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 func (m *PointSettings) GetFreq() *DoubleOrHold {
 	if m != nil {
@@ -402,12 +549,15 @@ type Point struct {
 	// Relative time; first should be 0. Must be ascending.
 	T        float64        `protobuf:"fixed64,1,opt,name=t" json:"t,omitempty"`
 	Settings *PointSettings `protobuf:"bytes,2,opt,name=settings" json:"settings,omitempty"`
+	// Shape of the transition from each of this point's settings to its
+	// next value.
+	Curve Curve `protobuf:"varint,3,opt,name=curve,enum=aborapb.Curve" json:"curve,omitempty"`
 }
 
 func (m *Point) Reset()                    { *m = Point{} }
 func (m *Point) String() string            { return proto.CompactTextString(m) }
 func (*Point) ProtoMessage()               {}
-func (*Point) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*Point) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
 
 func (m *Point) GetSettings() *PointSettings {
 	if m != nil {
@@ -421,23 +571,58 @@ type ADSREnvelope struct {
 	DecayDuration   float64 `protobuf:"fixed64,2,opt,name=decay_duration" json:"decay_duration,omitempty"`
 	ReleaseDuration float64 `protobuf:"fixed64,3,opt,name=release_duration" json:"release_duration,omitempty"`
 	SustainLevel    float64 `protobuf:"fixed64,4,opt,name=sustain_level" json:"sustain_level,omitempty"`
+	// Shape of the attack, decay and release.
+	Curve Curve `protobuf:"varint,5,opt,name=curve,enum=aborapb.Curve" json:"curve,omitempty"`
 }
 
 func (m *ADSREnvelope) Reset()                    { *m = ADSREnvelope{} }
 func (m *ADSREnvelope) String() string            { return proto.CompactTextString(m) }
 func (*ADSREnvelope) ProtoMessage()               {}
-func (*ADSREnvelope) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*ADSREnvelope) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+type Breakpoint struct {
+	// Time since the beginning of the chirp, in seconds. Must be ascending.
+	T     float64 `protobuf:"fixed64,1,opt,name=t" json:"t,omitempty"`
+	Level float64 `protobuf:"fixed64,2,opt,name=level" json:"level,omitempty"`
+	// Shape of the segment from this point to the next.
+	Curve Curve `protobuf:"varint,3,opt,name=curve,enum=aborapb.Curve" json:"curve,omitempty"`
+}
+
+func (m *Breakpoint) Reset()                    { *m = Breakpoint{} }
+func (m *Breakpoint) String() string            { return proto.CompactTextString(m) }
+func (*Breakpoint) ProtoMessage()               {}
+func (*Breakpoint) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+// An envelope given by its level at a sequence of points. After the last
+// point it holds the last level. It ends with the chirp, or at the last
+// point if the last level is zero.
+type BreakpointEnvelope struct {
+	Points []*Breakpoint `protobuf:"bytes,1,rep,name=points" json:"points,omitempty"`
+}
+
+func (m *BreakpointEnvelope) Reset()                    { *m = BreakpointEnvelope{} }
+func (m *BreakpointEnvelope) String() string            { return proto.CompactTextString(m) }
+func (*BreakpointEnvelope) ProtoMessage()               {}
+func (*BreakpointEnvelope) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *BreakpointEnvelope) GetPoints() []*Breakpoint {
+	if m != nil {
+		return m.Points
+	}
+	return nil
+}
 
 type Envelope struct {
 	// Types that are valid to be assigned to EnvelopeKind:
 	//	*Envelope_Adsr
+	//	*Envelope_Breakpoints
 	EnvelopeKind isEnvelope_EnvelopeKind `protobuf_oneof:"EnvelopeKind"`
 }
 
 func (m *Envelope) Reset()                    { *m = Envelope{} }
 func (m *Envelope) String() string            { return proto.CompactTextString(m) }
 func (*Envelope) ProtoMessage()               {}
-func (*Envelope) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*Envelope) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
 
 type isEnvelope_EnvelopeKind interface {
 	isEnvelope_EnvelopeKind()
@@ -446,8 +631,12 @@ type isEnvelope_EnvelopeKind interface {
 type Envelope_Adsr struct {
 	Adsr *ADSREnvelope `protobuf:"bytes,1,opt,name=adsr,oneof"`
 }
+type Envelope_Breakpoints struct {
+	Breakpoints *BreakpointEnvelope `protobuf:"bytes,2,opt,name=breakpoints,oneof"`
+}
 
-func (*Envelope_Adsr) isEnvelope_EnvelopeKind() {}
+func (*Envelope_Adsr) isEnvelope_EnvelopeKind()        {}
+func (*Envelope_Breakpoints) isEnvelope_EnvelopeKind() {}
 
 func (m *Envelope) GetEnvelopeKind() isEnvelope_EnvelopeKind {
 	if m != nil {
@@ -463,10 +652,18 @@ func (m *Envelope) GetAdsr() *ADSREnvelope {
 	return nil
 }
 
+func (m *Envelope) GetBreakpoints() *BreakpointEnvelope {
+	if x, ok := m.GetEnvelopeKind().(*Envelope_Breakpoints); ok {
+		return x.Breakpoints
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*Envelope) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
 	return _Envelope_OneofMarshaler, _Envelope_OneofUnmarshaler, _Envelope_OneofSizer, []interface{}{
 		(*Envelope_Adsr)(nil),
+		(*Envelope_Breakpoints)(nil),
 	}
 }
 
@@ -479,6 +676,11 @@ func _Envelope_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
 		if err := b.EncodeMessage(x.Adsr); err != nil {
 			return err
 		}
+	case *Envelope_Breakpoints:
+		b.EncodeVarint(2<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Breakpoints); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("Envelope.EnvelopeKind has unexpected type %T", x)
@@ -497,6 +699,14 @@ func _Envelope_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffe
 		err := b.DecodeMessage(msg)
 		m.EnvelopeKind = &Envelope_Adsr{msg}
 		return true, err
+	case 2: // EnvelopeKind.breakpoints
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(BreakpointEnvelope)
+		err := b.DecodeMessage(msg)
+		m.EnvelopeKind = &Envelope_Breakpoints{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -511,6 +721,11 @@ func _Envelope_OneofSizer(msg proto.Message) (n int) {
 		n += proto.SizeVarint(1<<3 | proto.WireBytes)
 		n += proto.SizeVarint(uint64(s))
 		n += s
+	case *Envelope_Breakpoints:
+		s := proto.Size(x.Breakpoints)
+		n += proto.SizeVarint(2<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
 	case nil:
 	default:
 		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
@@ -527,7 +742,7 @@ type Context struct {
 func (m *Context) Reset()                    { *m = Context{} }
 func (m *Context) String() string            { return proto.CompactTextString(m) }
 func (*Context) ProtoMessage()               {}
-func (*Context) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (*Context) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
 
 func (m *Context) GetInitial() *PointSettings {
 	if m != nil {
@@ -560,7 +775,7 @@ type Chirp struct {
 func (m *Chirp) Reset()                    { *m = Chirp{} }
 func (m *Chirp) String() string            { return proto.CompactTextString(m) }
 func (*Chirp) ProtoMessage()               {}
-func (*Chirp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+func (*Chirp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
 
 func (m *Chirp) GetPoints() []*Point {
 	if m != nil {
@@ -584,7 +799,7 @@ type Chirps struct {
 func (m *Chirps) Reset()                    { *m = Chirps{} }
 func (m *Chirps) String() string            { return proto.CompactTextString(m) }
 func (*Chirps) ProtoMessage()               {}
-func (*Chirps) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+func (*Chirps) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
 
 func (m *Chirps) GetChirp() []*Chirp {
 	if m != nil {
@@ -600,60 +815,123 @@ func (m *Chirps) GetDefaults() *Context {
 	return nil
 }
 
+type EditorState struct {
+	// Start of the visible region, in seconds.
+	Offset float64 `protobuf:"fixed64,1,opt,name=offset" json:"offset,omitempty"`
+	// Displayed channel, or -1 for the mixdown.
+	Channel int32 `protobuf:"varint,2,opt,name=channel" json:"channel,omitempty"`
+}
+
+func (m *EditorState) Reset()                    { *m = EditorState{} }
+func (m *EditorState) String() string            { return proto.CompactTextString(m) }
+func (*EditorState) ProtoMessage()               {}
+func (*EditorState) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+type Project struct {
+	// Base name of the annotated input file.
+	Input       string       `protobuf:"bytes,1,opt,name=input" json:"input,omitempty"`
+	Annotations *Chirps      `protobuf:"bytes,2,opt,name=annotations" json:"annotations,omitempty"`
+	Editor      *EditorState `protobuf:"bytes,3,opt,name=editor" json:"editor,omitempty"`
+	// Time of the last save, in seconds since the Unix epoch.
+	SavedTime int64 `protobuf:"varint,4,opt,name=saved_time,json=savedTime" json:"saved_time,omitempty"`
+}
+
+func (m *Project) Reset()                    { *m = Project{} }
+func (m *Project) String() string            { return proto.CompactTextString(m) }
+func (*Project) ProtoMessage()               {}
+func (*Project) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *Project) GetAnnotations() *Chirps {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+func (m *Project) GetEditor() *EditorState {
+	if m != nil {
+		return m.Editor
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*SpectrumPoint)(nil), "aborapb.SpectrumPoint")
 	proto.RegisterType((*Spectrum)(nil), "aborapb.Spectrum")
 	proto.RegisterType((*DoubleOrHold)(nil), "aborapb.DoubleOrHold")
 	proto.RegisterType((*NoOptions)(nil), "aborapb.NoOptions")
+	proto.RegisterType((*PulseOptions)(nil), "aborapb.PulseOptions")
 	proto.RegisterType((*Oscillator)(nil), "aborapb.Oscillator")
 	proto.RegisterType((*PointSettings)(nil), "aborapb.PointSettings")
 	proto.RegisterType((*Point)(nil), "aborapb.Point")
 	proto.RegisterType((*ADSREnvelope)(nil), "aborapb.ADSREnvelope")
+	proto.RegisterType((*Breakpoint)(nil), "aborapb.Breakpoint")
+	proto.RegisterType((*BreakpointEnvelope)(nil), "aborapb.BreakpointEnvelope")
 	proto.RegisterType((*Envelope)(nil), "aborapb.Envelope")
 	proto.RegisterType((*Context)(nil), "aborapb.Context")
 	proto.RegisterType((*Chirp)(nil), "aborapb.Chirp")
 	proto.RegisterType((*Chirps)(nil), "aborapb.Chirps")
+	proto.RegisterType((*EditorState)(nil), "aborapb.EditorState")
+	proto.RegisterType((*Project)(nil), "aborapb.Project")
+	proto.RegisterEnum("aborapb.Curve", Curve_name, Curve_value)
 }
 
 var fileDescriptor0 = []byte{
-	// 613 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x84, 0x54, 0x5d, 0x4f, 0x14, 0x31,
-	0x14, 0x65, 0xd9, 0x9d, 0x65, 0xb9, 0xfb, 0xc1, 0x6e, 0x0d, 0xb8, 0x3e, 0x68, 0x4c, 0x15, 0xd9,
-	0x68, 0x02, 0x09, 0xbe, 0x1a, 0x13, 0x04, 0x13, 0x12, 0xa2, 0x18, 0x49, 0x7c, 0x9d, 0x74, 0x66,
-	0xca, 0x4e, 0x63, 0xb7, 0x1d, 0xda, 0xce, 0x2a, 0x6f, 0xfe, 0x00, 0x7f, 0x81, 0xbf, 0xd6, 0x4e,
-	0xa7, 0x33, 0xc3, 0x42, 0x80, 0xb7, 0xf6, 0xf6, 0xf6, 0xdc, 0x73, 0x4f, 0xcf, 0x2d, 0x4c, 0x32,
-	0x25, 0x8d, 0x3c, 0x20, 0x91, 0x54, 0x64, 0xdf, 0xad, 0xd1, 0x86, 0xdb, 0x64, 0x11, 0xd6, 0x30,
-	0xbc, 0xc8, 0x68, 0x6c, 0x54, 0xbe, 0xf8, 0x26, 0x99, 0x30, 0x68, 0x02, 0x9b, 0x64, 0x91, 0x71,
-	0x66, 0xf2, 0x84, 0x4e, 0x5b, 0x2f, 0x5b, 0xb3, 0x56, 0x11, 0xba, 0x54, 0xf4, 0x2a, 0xa7, 0x22,
-	0xbe, 0x9e, 0xae, 0xbb, 0xd0, 0x10, 0x82, 0x2c, 0x25, 0x9a, 0x4e, 0x03, 0xb7, 0xdd, 0x86, 0x21,
-	0x97, 0xbf, 0xc2, 0x26, 0xab, 0xed, 0xc2, 0x3b, 0x30, 0x4a, 0xd9, 0x3c, 0xbd, 0x11, 0xef, 0x14,
-	0x71, 0xfc, 0x05, 0x7a, 0x55, 0x51, 0xf4, 0x06, 0xba, 0x59, 0x51, 0x58, 0xdb, 0x62, 0xed, 0x59,
-	0xff, 0x70, 0x67, 0xdf, 0x53, 0xdb, 0x5f, 0xe5, 0xf5, 0x0c, 0x26, 0x42, 0x2e, 0x98, 0x20, 0x3c,
-	0xbc, 0x45, 0x06, 0x7f, 0x84, 0xc1, 0x89, 0xcc, 0x23, 0x4e, 0xcf, 0xd5, 0xa9, 0xe4, 0x09, 0xda,
-	0x82, 0x60, 0x49, 0x78, 0xee, 0xe9, 0x9f, 0xae, 0xa1, 0x11, 0x74, 0x52, 0x7b, 0xe0, 0xd2, 0x7b,
-	0xa7, 0x6b, 0x9f, 0x86, 0xd0, 0xff, 0x51, 0x24, 0x94, 0xf9, 0xb8, 0x0f, 0x9b, 0x5f, 0xe5, 0x79,
-	0x66, 0x98, 0x14, 0x1a, 0xff, 0x6d, 0x01, 0x9c, 0xeb, 0x98, 0x71, 0x4e, 0x8c, 0x54, 0x08, 0x43,
-	0x47, 0x33, 0x51, 0x42, 0xf5, 0x0f, 0x51, 0x4d, 0xae, 0xbe, 0x60, 0xe1, 0x5f, 0x43, 0x57, 0x5f,
-	0xe5, 0x44, 0x51, 0x57, 0xe0, 0xbe, 0xac, 0x5d, 0xe8, 0x69, 0xdf, 0x91, 0x93, 0xa7, 0x7f, 0x38,
-	0xb9, 0xd3, 0x6a, 0xc9, 0xad, 0x29, 0xaf, 0xf1, 0xbf, 0x75, 0x18, 0x3a, 0x01, 0x2e, 0xa8, 0x31,
-	0x4c, 0xcc, 0x35, 0x7a, 0x05, 0x9d, 0x42, 0x00, 0xcf, 0x68, 0xbb, 0xc6, 0x58, 0x91, 0x60, 0x76,
-	0xf3, 0x15, 0xd7, 0x1f, 0xca, 0x3c, 0x80, 0xb1, 0x51, 0x74, 0x21, 0xb9, 0x0c, 0xb5, 0x5d, 0x88,
-	0xb9, 0x49, 0x3d, 0xbd, 0x7b, 0x2e, 0xbc, 0x83, 0x41, 0x75, 0xc1, 0xf1, 0xe8, 0x3c, 0x82, 0xbe,
-	0x64, 0x91, 0xb2, 0xbd, 0x34, 0xe8, 0xc1, 0x23, 0xe8, 0xd5, 0x05, 0x87, 0xde, 0x7d, 0x20, 0x19,
-	0x7f, 0x80, 0xa0, 0x34, 0xc7, 0x26, 0xb4, 0x8c, 0x37, 0xeb, 0xcc, 0xca, 0xec, 0xa5, 0xf2, 0x8d,
-	0x37, 0x8e, 0x5a, 0x11, 0x12, 0x67, 0x30, 0x38, 0x3a, 0xb9, 0xf8, 0xfe, 0x59, 0x2c, 0x29, 0x97,
-	0x19, 0x45, 0x4f, 0x61, 0x8b, 0x18, 0x43, 0xe2, 0x9f, 0x61, 0x92, 0x5b, 0x02, 0xf6, 0xd9, 0x3c,
-	0xa4, 0xb5, 0x71, 0x42, 0x63, 0x72, 0xdd, 0xc4, 0xcb, 0x21, 0x98, 0xc2, 0x58, 0x51, 0x4e, 0xed,
-	0x18, 0x34, 0x27, 0xed, 0x6a, 0x1e, 0x74, 0xae, 0x0d, 0x61, 0x22, 0xe4, 0xd4, 0xc2, 0x7b, 0xdf,
-	0x1f, 0x41, 0xaf, 0xae, 0xb6, 0x0b, 0x1d, 0x92, 0x68, 0x75, 0xe7, 0x19, 0x6f, 0x52, 0xb2, 0x76,
-	0x18, 0xc1, 0xa0, 0xda, 0x9d, 0x31, 0x91, 0xe0, 0x3f, 0x2d, 0xd8, 0x38, 0x96, 0xc2, 0xd0, 0xdf,
-	0x06, 0xed, 0xc1, 0x06, 0x13, 0xcc, 0x30, 0xc2, 0x3d, 0xca, 0x3d, 0x9d, 0x5a, 0xcb, 0xf4, 0xa8,
-	0x07, 0xf1, 0x9a, 0x34, 0xd6, 0xab, 0x09, 0xed, 0x01, 0xc8, 0xda, 0x78, 0xde, 0x02, 0x4f, 0xea,
-	0xb4, 0xc6, 0x93, 0xf8, 0x1a, 0x82, 0xe3, 0x94, 0xa9, 0x0c, 0x21, 0x80, 0x88, 0xce, 0x6d, 0x8f,
-	0x86, 0x2d, 0xaa, 0xbf, 0x62, 0x0c, 0xbd, 0x5b, 0x2a, 0xbd, 0xa8, 0x07, 0xbc, 0xed, 0x06, 0x7c,
-	0xb4, 0x4a, 0x12, 0xbd, 0x85, 0x71, 0x5c, 0x36, 0x14, 0xca, 0x25, 0x55, 0x8a, 0x59, 0xc7, 0x96,
-	0x9e, 0x1a, 0xd7, 0x99, 0xbe, 0x63, 0x7c, 0x06, 0x5d, 0x57, 0x5a, 0xa3, 0xe7, 0x10, 0xc4, 0xc5,
-	0xca, 0xff, 0x1a, 0x0d, 0x68, 0x49, 0x0d, 0x5b, 0x1a, 0xf4, 0x92, 0xe4, 0xdc, 0x54, 0x2e, 0xb8,
-	0x03, 0x16, 0x75, 0xdd, 0x57, 0xf8, 0xfe, 0x7f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x2b, 0x5c, 0xcd,
-	0x5d, 0x1f, 0x05, 0x00, 0x00,
+	// 894 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x55, 0x5d, 0x6f, 0xdb, 0x36,
+	0x14, 0xb5, 0x6c, 0xcb, 0x51, 0xae, 0x3f, 0x22, 0x73, 0x6b, 0xa6, 0x61, 0xe8, 0x50, 0x28, 0x6d,
+	0x1a, 0x74, 0x40, 0x0a, 0x78, 0x4f, 0x7b, 0x19, 0x90, 0x38, 0xde, 0x1c, 0xd4, 0xb1, 0xb3, 0xb8,
+	0x1d, 0x36, 0x0c, 0x98, 0x41, 0x4b, 0x8c, 0xc5, 0x95, 0x26, 0x55, 0x92, 0x72, 0x9a, 0xb7, 0x3d,
+	0xec, 0x7d, 0xef, 0xfb, 0xb5, 0x83, 0xbe, 0x6d, 0x27, 0x75, 0xdf, 0xa4, 0xeb, 0xc3, 0xc3, 0x73,
+	0xce, 0xbd, 0x57, 0x86, 0x6e, 0x28, 0x85, 0x16, 0xaf, 0xf1, 0x5c, 0x48, 0x7c, 0x9a, 0x3c, 0xa3,
+	0xbd, 0xe4, 0x25, 0x9c, 0xbb, 0x0a, 0xda, 0xd3, 0x90, 0x78, 0x5a, 0x46, 0xcb, 0x6b, 0x41, 0xb9,
+	0x46, 0x5d, 0xd8, 0xc7, 0xcb, 0x90, 0x51, 0x1d, 0xf9, 0xc4, 0x31, 0x9e, 0x19, 0x27, 0x46, 0x5c,
+	0xba, 0x95, 0xe4, 0x43, 0x44, 0xb8, 0x77, 0xef, 0x54, 0x93, 0x52, 0x1b, 0xcc, 0x30, 0xc0, 0x8a,
+	0x38, 0x66, 0xf2, 0xfa, 0x04, 0xda, 0x4c, 0xdc, 0xcd, 0x4a, 0x54, 0x2d, 0x29, 0x1f, 0x42, 0x27,
+	0xa0, 0x8b, 0x60, 0xad, 0x5e, 0x8f, 0xeb, 0xee, 0x15, 0x58, 0xf9, 0xa5, 0xe8, 0x18, 0x1a, 0x61,
+	0x7c, 0xb1, 0x72, 0x8c, 0x67, 0xb5, 0x93, 0x66, 0xef, 0xf0, 0x34, 0x93, 0x76, 0xba, 0xa9, 0xeb,
+	0x6b, 0xe8, 0x72, 0xb1, 0xa4, 0x1c, 0xb3, 0xd9, 0x96, 0x18, 0xf7, 0x47, 0x68, 0x5d, 0x88, 0x68,
+	0xce, 0xc8, 0x44, 0x0e, 0x05, 0xf3, 0xd1, 0x01, 0x98, 0x2b, 0xcc, 0xa2, 0x4c, 0xfe, 0xb0, 0x82,
+	0x3a, 0x50, 0x0f, 0x04, 0xf3, 0x13, 0xb8, 0x35, 0xac, 0x9c, 0xb7, 0xa1, 0xf9, 0x6b, 0x0c, 0x48,
+	0xf1, 0x6e, 0x13, 0xf6, 0xc7, 0x62, 0x12, 0x6a, 0x2a, 0xb8, 0x72, 0x9f, 0x42, 0xeb, 0x3a, 0x62,
+	0x8a, 0x64, 0xef, 0xb1, 0xd3, 0x3b, 0xea, 0xeb, 0x20, 0x25, 0x73, 0xff, 0xa9, 0x02, 0x4c, 0x94,
+	0x47, 0x19, 0xc3, 0x5a, 0x48, 0xe4, 0x42, 0x5d, 0x51, 0x9e, 0xde, 0xd4, 0xec, 0xa1, 0x42, 0x7b,
+	0xc1, 0x37, 0xac, 0xa0, 0xe7, 0xd0, 0x50, 0x1f, 0x22, 0x2c, 0x89, 0x53, 0xdd, 0x81, 0x7a, 0x01,
+	0x96, 0xca, 0x0c, 0x27, 0xe9, 0x35, 0x7b, 0xdd, 0x07, 0x49, 0x0c, 0x2b, 0xe8, 0x18, 0x2c, 0x85,
+	0xef, 0xb4, 0x10, 0x3a, 0x70, 0xea, 0x3b, 0xe8, 0x8e, 0xc1, 0xd2, 0x92, 0x62, 0xbe, 0x60, 0x69,
+	0x8f, 0x3e, 0x8d, 0x33, 0xc3, 0xd8, 0xae, 0xd3, 0x48, 0x40, 0x4f, 0x0a, 0xd0, 0x7a, 0x08, 0x69,
+	0x64, 0xa5, 0x6d, 0xe5, 0xfe, 0x57, 0x85, 0x76, 0xd2, 0x97, 0x29, 0xd1, 0x9a, 0xf2, 0x85, 0x42,
+	0x47, 0x50, 0x8f, 0xfb, 0xe2, 0x18, 0x5b, 0x3c, 0x1b, 0x9d, 0x39, 0x59, 0x1f, 0xae, 0xea, 0x2e,
+	0xe4, 0x6b, 0xb0, 0xb5, 0x24, 0x4b, 0xc1, 0xc4, 0x4c, 0x69, 0x49, 0xf8, 0x42, 0x07, 0x4e, 0x6d,
+	0xd7, 0x81, 0xef, 0xa0, 0x95, 0x1f, 0x48, 0x74, 0xd4, 0x3f, 0xc3, 0xbe, 0xa2, 0x73, 0x89, 0xf5,
+	0x1a, 0xbb, 0xf9, 0x19, 0xf6, 0xfc, 0x40, 0xc2, 0xde, 0xd8, 0x01, 0x76, 0xff, 0x00, 0x33, 0x9d,
+	0xd9, 0x7d, 0x30, 0x74, 0xb6, 0x43, 0x27, 0x60, 0xa9, 0x2c, 0xaa, 0xcc, 0x78, 0x39, 0xe8, 0x9b,
+	0x41, 0x3e, 0x05, 0xd3, 0x8b, 0xe4, 0x8a, 0x24, 0x76, 0x3b, 0xbd, 0x4e, 0x01, 0xeb, 0xc7, 0x55,
+	0xf7, 0x5f, 0x03, 0x5a, 0x67, 0x17, 0xd3, 0x9b, 0x01, 0x5f, 0x11, 0x26, 0x42, 0x82, 0xbe, 0x82,
+	0x03, 0xac, 0x35, 0xf6, 0xde, 0xcf, 0xfc, 0x48, 0xe2, 0xb8, 0x5f, 0xd9, 0x95, 0x87, 0xd0, 0xf1,
+	0x89, 0x87, 0xef, 0xcb, 0x7a, 0xba, 0xbb, 0x0e, 0xd8, 0x92, 0x30, 0x82, 0x15, 0x29, 0x7f, 0xa9,
+	0xe5, 0x6b, 0xac, 0x22, 0xa5, 0x31, 0xe5, 0x33, 0x46, 0x56, 0x84, 0xa5, 0xeb, 0x5a, 0x2a, 0x32,
+	0x1f, 0x55, 0x34, 0x00, 0x38, 0x97, 0x04, 0xbf, 0x0f, 0xb7, 0x3d, 0xb7, 0xc1, 0x4c, 0x69, 0xaa,
+	0x9b, 0x34, 0x8f, 0x1b, 0xfb, 0x01, 0x50, 0x49, 0x53, 0xb8, 0x3b, 0xda, 0xfa, 0x3c, 0x7c, 0x51,
+	0x9c, 0x2a, 0xc1, 0x6e, 0x04, 0x56, 0x71, 0xe0, 0x05, 0xd4, 0xb1, 0xaf, 0xe4, 0x83, 0x39, 0x5c,
+	0xcf, 0x6c, 0x58, 0x41, 0x3d, 0x68, 0xce, 0x0b, 0x82, 0xbc, 0x25, 0xdf, 0x3c, 0x42, 0x5e, 0x9e,
+	0x39, 0xef, 0x40, 0x2b, 0x7f, 0x7b, 0x43, 0xb9, 0xef, 0xfe, 0x6d, 0xc0, 0x5e, 0x5f, 0x70, 0x4d,
+	0x3e, 0x6a, 0xf4, 0x12, 0xf6, 0x28, 0xa7, 0x9a, 0x62, 0xe6, 0x18, 0x3b, 0xdb, 0x7b, 0x04, 0x16,
+	0xc9, 0x48, 0x9c, 0xea, 0xd6, 0x9e, 0x17, 0x26, 0x5e, 0x02, 0x88, 0x62, 0xdb, 0xb2, 0xb9, 0x2f,
+	0x9d, 0x97, 0x8b, 0xe8, 0xde, 0x83, 0xd9, 0x0f, 0xa8, 0x0c, 0x11, 0x02, 0x98, 0x93, 0x05, 0xe5,
+	0x33, 0x4d, 0x97, 0xf9, 0x77, 0xdb, 0x06, 0x6b, 0xab, 0xf5, 0xdf, 0x16, 0x69, 0xd6, 0x92, 0x34,
+	0x3b, 0x9b, 0x22, 0xd1, 0x2b, 0xb0, 0xbd, 0xd4, 0xd0, 0x4c, 0xac, 0x88, 0x94, 0xd4, 0x27, 0xd9,
+	0x22, 0xd9, 0x65, 0xb7, 0x52, 0x80, 0xfb, 0x06, 0x1a, 0xc9, 0xd5, 0xe9, 0xc4, 0xc6, 0x4f, 0x8e,
+	0xb1, 0x45, 0x9a, 0x4a, 0x73, 0xc1, 0xf2, 0xc9, 0x2d, 0x8e, 0x58, 0x91, 0xf3, 0x43, 0xb2, 0x53,
+	0x68, 0x0e, 0x7c, 0xaa, 0x85, 0x9c, 0x6a, 0xac, 0x09, 0xea, 0x40, 0x43, 0xdc, 0xde, 0x2a, 0x92,
+	0x4f, 0xd2, 0x01, 0xec, 0x79, 0x01, 0xe6, 0x3c, 0x9b, 0x25, 0xd3, 0xfd, 0x08, 0x7b, 0xd7, 0x52,
+	0xfc, 0x45, 0x3c, 0x1d, 0x4f, 0x19, 0xe5, 0x61, 0x94, 0x42, 0xf7, 0xd1, 0x73, 0x68, 0x62, 0xce,
+	0x85, 0x4e, 0x6c, 0xe7, 0x17, 0x1e, 0x6c, 0x4a, 0x52, 0xf1, 0x37, 0x99, 0xf8, 0xb4, 0x0c, 0xf7,
+	0xcb, 0xb2, 0x07, 0x6b, 0x32, 0x10, 0x80, 0xc2, 0x2b, 0xe2, 0xa7, 0xa1, 0xc6, 0x41, 0xd4, 0x5e,
+	0xfd, 0x09, 0x66, 0x32, 0xaf, 0x08, 0xa0, 0x31, 0xba, 0x1c, 0x0f, 0xce, 0x6e, 0xec, 0x4a, 0xfc,
+	0xdc, 0x9f, 0x4c, 0x2f, 0xc7, 0x03, 0x3b, 0xd6, 0xda, 0x1c, 0xfc, 0x76, 0x3d, 0x19, 0x0f, 0xc6,
+	0x6f, 0x2f, 0xcf, 0x46, 0x76, 0x15, 0x59, 0x50, 0x1f, 0x4e, 0x46, 0x17, 0x76, 0x0d, 0x75, 0xa1,
+	0x3d, 0x9a, 0xfc, 0x3c, 0xfb, 0xe9, 0x66, 0xf0, 0xcb, 0xbb, 0xc1, 0xb8, 0xff, 0xbb, 0x5d, 0x8f,
+	0xd1, 0xfd, 0xb3, 0xb7, 0x57, 0xef, 0x46, 0xa3, 0xd9, 0xcd, 0xe4, 0xca, 0x36, 0xe7, 0x8d, 0xe4,
+	0x0f, 0xfa, 0xfb, 0xff, 0x07, 0x00, 0x72, 0x4d, 0x84, 0xa4, 0xb5, 0x07, 0x00, 0x00,
 }
@@ -0,0 +1,198 @@
+// Package resample converts sampled signals between sample rates using
+// band-limited (windowed-sinc) interpolation.
+package resample
+
+import (
+	"math"
+)
+
+var (
+	// Number of zero crossings of the sinc kernel on either side of its
+	// centre; more gives a sharper transition band.
+	zeroCrossings = 32
+
+	// Number of precomputed kernel phases per input sample.
+	phasesPerSample = 512
+
+	// Kaiser window shape; 8.6 gives roughly 90 dB stopband attenuation.
+	kaiserBeta = 8.6
+
+	// The cutoff is placed slightly below the Nyquist frequency of the
+	// lower of the two rates to leave room for the transition band.
+	cutoffFraction = 0.95
+)
+
+// Converter interpolates input samples at arbitrary positions using a
+// polyphase windowed-sinc filter.
+type Converter struct {
+	from, to  int
+	cutoff    float64
+	halfWidth float64
+	taps      int
+	table     []float64
+}
+
+// besselI0 is the zeroth order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum := 1.0
+	term := 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-17 {
+			break
+		}
+	}
+	return sum
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// New returns a converter from one sample rate to another.
+func New(from, to int) *Converter {
+	cutoff := cutoffFraction
+	if to < from {
+		cutoff *= float64(to) / float64(from)
+	}
+
+	halfWidth := float64(zeroCrossings) / cutoff
+
+	c := &Converter{
+		from:      from,
+		to:        to,
+		cutoff:    cutoff,
+		halfWidth: halfWidth,
+		taps:      int(math.Ceil(halfWidth)),
+	}
+
+	n := int(math.Ceil(halfWidth*float64(phasesPerSample))) + 2
+	c.table = make([]float64, n)
+	norm := besselI0(kaiserBeta)
+	for i := range c.table {
+		t := float64(i) / float64(phasesPerSample)
+		if t >= halfWidth {
+			continue
+		}
+		r := t / halfWidth
+		window := besselI0(kaiserBeta*math.Sqrt(1-r*r)) / norm
+		c.table[i] = cutoff * sinc(cutoff*t) * window
+	}
+
+	return c
+}
+
+// Taps returns the number of input samples needed on either side of an
+// interpolated position.
+func (c *Converter) Taps() int {
+	return c.taps
+}
+
+// Position returns the input position corresponding to output sample j.
+func (c *Converter) Position(j int) float64 {
+	return float64(j) * float64(c.from) / float64(c.to)
+}
+
+// OutputLength returns the number of output samples corresponding to n
+// input samples.
+func (c *Converter) OutputLength(n int) int {
+	return int((int64(n)*int64(c.to) + int64(c.from) - 1) / int64(c.from))
+}
+
+func (c *Converter) kernel(t float64) float64 {
+	t = math.Abs(t) * float64(phasesPerSample)
+	i := int(t)
+	if i+1 >= len(c.table) {
+		return 0
+	}
+	frac := t - float64(i)
+	return c.table[i] + frac*(c.table[i+1]-c.table[i])
+}
+
+// Interpolate returns the signal value at input position x, where in
+// holds the input samples starting at index base. Samples outside of in
+// are taken to be zero.
+func (c *Converter) Interpolate(in []float64, base int, x float64) float64 {
+	centre := int(math.Floor(x))
+	lo := centre - c.taps + 1
+	hi := centre + c.taps
+	if lo < base {
+		lo = base
+	}
+	if hi >= base+len(in) {
+		hi = base + len(in) - 1
+	}
+
+	var rv float64
+	for k := lo; k <= hi; k++ {
+		rv += in[k-base] * c.kernel(x-float64(k))
+	}
+	return rv
+}
+
+// Slice converts a whole signal.
+func Slice(xs []float64, from, to int) []float64 {
+	if from == to {
+		return xs
+	}
+	c := New(from, to)
+	rv := make([]float64, c.OutputLength(len(xs)))
+	for j := range rv {
+		rv[j] = c.Interpolate(xs, 0, c.Position(j))
+	}
+	return rv
+}
+
+// Channel converts a stream of samples.
+func Channel(ch <-chan float64, from, to int) <-chan float64 {
+	if from == to {
+		return ch
+	}
+
+	c := New(from, to)
+	out := make(chan float64, to)
+
+	go func() {
+		var buf []float64
+		base := 0
+		received := 0
+		j := 0
+
+		emit := func(limit int) {
+			for {
+				x := c.Position(j)
+				if int(math.Floor(x))+c.taps >= limit {
+					return
+				}
+				out <- c.Interpolate(buf, base, x)
+				j++
+
+				// Drop samples no longer needed by later outputs.
+				keepFrom := int(math.Floor(c.Position(j))) - c.taps + 1
+				if drop := keepFrom - base; drop > len(buf)/2 && drop > 4096 {
+					buf = append(buf[:0], buf[drop:]...)
+					base += drop
+				}
+			}
+		}
+
+		for x := range ch {
+			buf = append(buf, x)
+			received++
+			emit(received)
+		}
+
+		total := c.OutputLength(received)
+		for ; j < total; j++ {
+			out <- c.Interpolate(buf, base, c.Position(j))
+		}
+
+		close(out)
+	}()
+
+	return out
+}
@@ -0,0 +1,76 @@
+package resample
+
+import (
+	"math"
+	"testing"
+)
+
+func sine(freq float64, sampleRate, n int) []float64 {
+	rv := make([]float64, n)
+	for i := range rv {
+		rv[i] = math.Sin(2 * math.Pi * freq * float64(i) / float64(sampleRate))
+	}
+	return rv
+}
+
+func maxErrorInInterior(got, want []float64, margin int) float64 {
+	var worst float64
+	for i := margin; i < len(want)-margin && i < len(got); i++ {
+		worst = math.Max(worst, math.Abs(got[i]-want[i]))
+	}
+	return worst
+}
+
+func TestUpsampleSine(t *testing.T) {
+	got := Slice(sine(1000, 44100, 44100), 44100, 48000)
+	want := sine(1000, 48000, 48000)
+
+	if len(got) != 48000 {
+		t.Errorf("Slice() gave %d samples, want %d", len(got), 48000)
+	}
+
+	if e := maxErrorInInterior(got, want, 100); e > 1e-3 {
+		t.Errorf("upsampled sine deviates by %v", e)
+	}
+}
+
+func TestChannelMatchesSlice(t *testing.T) {
+	input := sine(440, 48000, 20000)
+	want := Slice(input, 48000, 44100)
+
+	ch := make(chan float64)
+	go func() {
+		for _, x := range input {
+			ch <- x
+		}
+		close(ch)
+	}()
+
+	var got []float64
+	for x := range Channel(ch, 48000, 44100) {
+		got = append(got, x)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("Channel() gave %d samples, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("Channel()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDownsampleRemovesAliases(t *testing.T) {
+	got := Slice(sine(15000, 48000, 48000), 48000, 22050)
+
+	var energy float64
+	for _, x := range got[1000 : len(got)-1000] {
+		energy += x * x
+	}
+	rms := math.Sqrt(energy / float64(len(got)-2000))
+
+	if rms > 1e-3 {
+		t.Errorf("15 kHz tone downsampled to 22.05 kHz has RMS %v, want ~0", rms)
+	}
+}
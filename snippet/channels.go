@@ -0,0 +1,137 @@
+package snippet
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type readSettings struct {
+	downmix      func(channels []Snippet) (Snippet, error)
+	blockSize    int
+	cachedBlocks int
+}
+
+// ReadOption configures how an audio file is read: which channel or
+// mixdown of channels, and for file-backed snippets, how it is cached.
+type ReadOption interface {
+	Apply(*readSettings)
+}
+
+// Channel selects a single channel (counting from zero) of the input.
+type Channel int
+
+func (c Channel) Apply(s *readSettings) {
+	s.downmix = func(channels []Snippet) (Snippet, error) {
+		if int(c) < 0 || int(c) >= len(channels) {
+			return nil, fmt.Errorf("channel %d out of range (input has %d channels)", int(c), len(channels))
+		}
+		return channels[c], nil
+	}
+}
+
+// AverageChannels mixes all channels of the input down to one. This is the
+// default.
+type AverageChannels struct{}
+
+func (_ AverageChannels) Apply(s *readSettings) {
+	s.downmix = func(channels []Snippet) (Snippet, error) {
+		if len(channels) == 1 {
+			return channels[0], nil
+		}
+		weights := make([]float64, len(channels))
+		for i := range weights {
+			weights[i] = 1.0 / float64(len(channels))
+		}
+		return weightedSum(channels, weights), nil
+	}
+}
+
+// Mid selects the mid signal (L+R)/2 of a stereo input.
+type Mid struct{}
+
+func (_ Mid) Apply(s *readSettings) {
+	s.downmix = func(channels []Snippet) (Snippet, error) {
+		if len(channels) != 2 {
+			return nil, fmt.Errorf("mid signal requires stereo input (input has %d channels)", len(channels))
+		}
+		return weightedSum(channels, []float64{0.5, 0.5}), nil
+	}
+}
+
+// Side selects the side signal (L-R)/2 of a stereo input.
+type Side struct{}
+
+func (_ Side) Apply(s *readSettings) {
+	s.downmix = func(channels []Snippet) (Snippet, error) {
+		if len(channels) != 2 {
+			return nil, fmt.Errorf("side signal requires stereo input (input has %d channels)", len(channels))
+		}
+		return weightedSum(channels, []float64{0.5, -0.5}), nil
+	}
+}
+
+// ParseChannel parses a channel selection as given on the command line:
+// a channel number, "average", "mid" or "side".
+func ParseChannel(name string) (ReadOption, error) {
+	switch name {
+	case "", "average":
+		return AverageChannels{}, nil
+	case "mid":
+		return Mid{}, nil
+	case "side":
+		return Side{}, nil
+	}
+
+	n, err := strconv.Atoi(name)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid channel selection %q (want a channel number, \"average\", \"mid\" or \"side\")", name)
+	}
+	return Channel(n), nil
+}
+
+func weightedSum(channels []Snippet, weights []float64) Snippet {
+	n := channels[0].TotalSamples()
+	rv := &inMemorySnippet{
+		sampleRate: channels[0].SampleRate(),
+		samples:    make([]float64, n),
+	}
+	for c, ch := range channels {
+		for i, x := range ch.Slice(0, n) {
+			rv.samples[i] += weights[c] * x
+		}
+	}
+	return rv
+}
+
+// Mixdown combines the channels of a multichannel input into a single
+// snippet according to the given options.
+func Mixdown(channels []Snippet, opts ...ReadOption) (Snippet, error) {
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("no channels to mix down")
+	}
+
+	settings := &readSettings{}
+	AverageChannels{}.Apply(settings)
+	for _, opt := range opts {
+		opt.Apply(settings)
+	}
+
+	return settings.downmix(channels)
+}
+
+func deinterleave(sampleRate, channels int, interleaved []float64) []Snippet {
+	frames := len(interleaved) / channels
+
+	var rv []Snippet
+	for c := 0; c < channels; c++ {
+		samples := make([]float64, frames)
+		for i := range samples {
+			samples[i] = interleaved[i*channels+c]
+		}
+		rv = append(rv, &inMemorySnippet{
+			sampleRate: sampleRate,
+			samples:    samples,
+		})
+	}
+	return rv
+}
@@ -0,0 +1,46 @@
+package snippet
+
+import (
+	"testing"
+)
+
+func TestMixdown(t *testing.T) {
+	channels := deinterleave(8000, 2, []float64{
+		1.0, 0.0,
+		0.5, 0.5,
+		0.0, -1.0,
+	})
+
+	testcases := []struct {
+		opt  ReadOption
+		want []float64
+	}{
+		{AverageChannels{}, []float64{0.5, 0.5, -0.5}},
+		{Channel(1), []float64{0.0, 0.5, -1.0}},
+		{Mid{}, []float64{0.5, 0.5, -0.5}},
+		{Side{}, []float64{0.5, 0.0, 0.5}},
+	}
+
+	for _, tc := range testcases {
+		snip, err := Mixdown(channels, tc.opt)
+		if err != nil {
+			t.Errorf("Mixdown(%T) = %v", tc.opt, err)
+			continue
+		}
+		got := snip.Slice(0, snip.TotalSamples())
+		if len(got) != len(tc.want) {
+			t.Errorf("Mixdown(%T) = %v, want %v", tc.opt, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("Mixdown(%T) = %v, want %v", tc.opt, got, tc.want)
+				break
+			}
+		}
+	}
+
+	if _, err := Mixdown(channels, Channel(2)); err == nil {
+		t.Errorf("Mixdown(Channel(2)) of stereo input succeeded, want error")
+	}
+}
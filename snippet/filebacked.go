@@ -0,0 +1,601 @@
+package snippet
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"sync"
+
+	"azul3d.org/engine/audio"
+
+	"github.com/steinarvk/abora/wav"
+)
+
+var (
+	defaultBlockSize    = 65536
+	defaultCachedBlocks = 64
+)
+
+// BlockSize sets the number of frames decoded at a time by a file-backed
+// snippet.
+type BlockSize int
+
+func (n BlockSize) Apply(s *readSettings) { s.blockSize = int(n) }
+
+// CachedBlocks sets the number of decoded blocks a file-backed snippet keeps
+// in memory.
+type CachedBlocks int
+
+func (n CachedBlocks) Apply(s *readSettings) { s.cachedBlocks = int(n) }
+
+// blockDecoder provides random access to the interleaved frames of a file.
+type blockDecoder interface {
+	sampleRate() int
+	channels() int
+	totalFrames() int
+	readFrames(frame int, buf []float64) (int, error)
+	close() error
+}
+
+type wavDecoder struct {
+	f *os.File
+	r *wav.Reader
+}
+
+func (d *wavDecoder) sampleRate() int  { return d.r.Format().SampleRate }
+func (d *wavDecoder) channels() int    { return d.r.Format().Channels }
+func (d *wavDecoder) totalFrames() int { return d.r.TotalFrames() }
+func (d *wavDecoder) close() error     { return d.f.Close() }
+
+func (d *wavDecoder) readFrames(frame int, buf []float64) (int, error) {
+	if err := d.r.Seek(frame); err != nil {
+		return 0, err
+	}
+	total := 0
+	for total < len(buf) {
+		n, err := d.r.Read(buf[total:])
+		total += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// flacSeekPoint is a point at which decoding of a FLAC file can begin: the
+// first frame of a FLAC frame and its byte offset from the first FLAC frame.
+type flacSeekPoint struct {
+	frame  int
+	offset int64
+}
+
+// flacDecoder decodes FLAC files forwards. When asked for frames that
+// precede the current position, or that lie beyond a later seek point, it
+// restarts decoding at the nearest seek point before them (or at the start
+// of the file, if it has no SEEKTABLE).
+type flacDecoder struct {
+	filename   string
+	f          *os.File
+	decoder    audio.Decoder
+	config     audio.Config
+	total      int
+	position   int
+	scratch    audio.Slice
+	seekPoints []flacSeekPoint
+	audioStart int64
+}
+
+func (d *flacDecoder) sampleRate() int  { return d.config.SampleRate }
+func (d *flacDecoder) channels() int    { return d.config.Channels }
+func (d *flacDecoder) totalFrames() int { return d.total }
+func (d *flacDecoder) close() error     { return d.f.Close() }
+
+// reopenAt starts decoding afresh at a seek point. The decoder is given the
+// metadata blocks followed by the FLAC frames from the seek point on.
+func (d *flacDecoder) reopenAt(p flacSeekPoint) error {
+	if d.f != nil {
+		d.f.Close()
+	}
+
+	f, err := os.Open(d.filename)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = f
+	if p.offset > 0 {
+		r = io.MultiReader(
+			io.NewSectionReader(f, 0, d.audioStart),
+			io.NewSectionReader(f, d.audioStart+p.offset, math.MaxInt64-d.audioStart-p.offset))
+	}
+
+	decoder, _, err := audio.NewDecoder(r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	d.f = f
+	d.decoder = decoder
+	d.config = decoder.Config()
+	d.position = p.frame
+	return nil
+}
+
+// seekPointBefore returns the last seek point at or before frame.
+func (d *flacDecoder) seekPointBefore(frame int) flacSeekPoint {
+	i := sort.Search(len(d.seekPoints), func(i int) bool { return d.seekPoints[i].frame > frame })
+	if i == 0 {
+		return flacSeekPoint{}
+	}
+	return d.seekPoints[i-1]
+}
+
+// read decodes up to len(buf) samples (interleaved) at the current position.
+func (d *flacDecoder) read(buf []float64) (int, error) {
+	if d.scratch == nil || d.scratch.Len() < len(buf) {
+		d.scratch = audio.Float64{}.Make(len(buf), len(buf))
+	}
+
+	total := 0
+	for total < len(buf) {
+		want := len(buf) - total
+		chunk := d.scratch
+		if chunk.Len() > want {
+			chunk = audio.Float64(make([]float64, want))
+		}
+		read, err := d.decoder.Read(chunk)
+		for i := 0; i < read; i++ {
+			buf[total+i] = chunk.At(i)
+		}
+		total += read
+		if err == audio.EOS {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+
+	d.position += total / d.config.Channels
+	return total, nil
+}
+
+func (d *flacDecoder) readFrames(frame int, buf []float64) (int, error) {
+	// Restarting at a seek point is cheaper than decoding up to it.
+	if p := d.seekPointBefore(frame); frame < d.position || p.frame > d.position {
+		if err := d.reopenAt(p); err != nil {
+			return 0, err
+		}
+	}
+
+	skip := make([]float64, defaultBlockSize*d.config.Channels)
+	for d.position < frame {
+		want := (frame - d.position) * d.config.Channels
+		if want > len(skip) {
+			want = len(skip)
+		}
+		n, err := d.read(skip[:want])
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			return 0, fmt.Errorf("seek to frame %d beyond end of %q", frame, d.filename)
+		}
+	}
+
+	return d.read(buf)
+}
+
+const (
+	flacStreamInfo = 0
+	flacSeekTable  = 3
+
+	// flacPlaceholder marks unused entries of a SEEKTABLE.
+	flacPlaceholder = 0xffffffffffffffff
+)
+
+// flacMetadata reads the metadata blocks at the start of a FLAC file. It
+// returns the total number of frames from STREAMINFO (zero if the header
+// does not say), the seek points of the SEEKTABLE (if any) and the offset
+// at which the FLAC frames begin. If r is not a FLAC file, all are zero.
+func flacMetadata(r io.Reader) (total int, points []flacSeekPoint, audioStart int64, err error) {
+	signature := make([]byte, 4)
+	if _, err := io.ReadFull(r, signature); err != nil {
+		return 0, nil, 0, err
+	}
+	if string(signature) != "fLaC" {
+		return 0, nil, 0, nil
+	}
+
+	offset := int64(len(signature))
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return 0, nil, 0, err
+		}
+		last := header[0]&0x80 != 0
+		kind := header[0] & 0x7f
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		offset += int64(len(header)) + length
+
+		switch kind {
+		case flacStreamInfo, flacSeekTable:
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return 0, nil, 0, err
+			}
+			if kind == flacStreamInfo && len(data) >= 18 {
+				// Sample rate (20 bits), channels (3), bits per sample (5), total samples (36).
+				packed := binary.BigEndian.Uint64(data[10:18])
+				total = int(packed & (1<<36 - 1))
+			}
+			for i := 0; kind == flacSeekTable && i+18 <= len(data); i += 18 {
+				sample := binary.BigEndian.Uint64(data[i:])
+				if sample == flacPlaceholder {
+					continue
+				}
+				points = append(points, flacSeekPoint{
+					frame:  int(sample),
+					offset: int64(binary.BigEndian.Uint64(data[i+8:])),
+				})
+			}
+		default:
+			if _, err := io.CopyN(ioutil.Discard, r, length); err != nil {
+				return 0, nil, 0, err
+			}
+		}
+
+		if last {
+			return total, points, offset, nil
+		}
+	}
+}
+
+func newFLACDecoder(filename string) (*flacDecoder, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	total, points, audioStart, err := flacMetadata(bufio.NewReader(f))
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &flacDecoder{
+		filename:   filename,
+		seekPoints: points,
+		audioStart: audioStart,
+	}
+	if err := d.reopenAt(flacSeekPoint{}); err != nil {
+		return nil, err
+	}
+
+	if len(points) == 0 {
+		log.Printf("%q has no seek table; seeking backwards will decode from the start", filename)
+	}
+
+	if total == 0 {
+		log.Printf("length of %q unknown; decoding to count frames", filename)
+		buf := make([]float64, defaultBlockSize*d.config.Channels)
+		for {
+			n, err := d.read(buf)
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				break
+			}
+		}
+		total = d.position
+
+		if err := d.reopenAt(flacSeekPoint{}); err != nil {
+			return nil, err
+		}
+	}
+	d.total = total
+
+	return d, nil
+}
+
+func openDecoder(filename string) (blockDecoder, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	isWAV, err := sniffWAV(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if !isWAV {
+		f.Close()
+		return newFLACDecoder(filename)
+	}
+
+	r, err := wav.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &wavDecoder{f: f, r: r}, nil
+}
+
+// blockSource decodes blocks of interleaved frames of a file on demand and
+// keeps the most recently used ones in memory. It is shared by all the
+// snippets of the file, whatever their channel selection.
+//
+// The cache is only locked to look up and insert blocks, so that cached
+// blocks can be read while another is being decoded. Concurrent requests
+// for the same block wait for a single decode.
+type blockSource struct {
+	mutex     sync.Mutex
+	blockSize int
+	maxBlocks int
+	blocks    map[int]*list.Element
+	lru       *list.List
+	inflight  map[int]*blockCall
+
+	// The format of the file, recorded when it is opened.
+	sampleRate int
+	channels   int
+	frames     int
+
+	// decoderMutex serializes access to the decoder, which has a position.
+	decoderMutex sync.Mutex
+	decoder      blockDecoder
+}
+
+type cachedBlock struct {
+	index   int
+	samples []float64
+}
+
+func newBlockSource(decoder blockDecoder, blockSize, maxBlocks int) *blockSource {
+	return &blockSource{
+		decoder:    decoder,
+		sampleRate: decoder.sampleRate(),
+		channels:   decoder.channels(),
+		frames:     decoder.totalFrames(),
+		blockSize:  blockSize,
+		maxBlocks:  maxBlocks,
+		blocks:     map[int]*list.Element{},
+		lru:        list.New(),
+		inflight:   map[int]*blockCall{},
+	}
+}
+
+// blockCall is a decode in progress.
+type blockCall struct {
+	done    chan struct{}
+	samples []float64
+}
+
+func (b *blockSource) block(index int) []float64 {
+	b.mutex.Lock()
+	if elem, ok := b.blocks[index]; ok {
+		b.lru.MoveToFront(elem)
+		b.mutex.Unlock()
+		return elem.Value.(*cachedBlock).samples
+	}
+	if call, ok := b.inflight[index]; ok {
+		b.mutex.Unlock()
+		<-call.done
+		return call.samples
+	}
+	call := &blockCall{done: make(chan struct{})}
+	b.inflight[index] = call
+	b.mutex.Unlock()
+
+	var err error
+	defer func() {
+		b.mutex.Lock()
+		delete(b.inflight, index)
+		if err == nil && call.samples != nil {
+			b.add(index, call.samples)
+		}
+		b.mutex.Unlock()
+		close(call.done)
+	}()
+
+	call.samples, err = b.decode(index)
+	if err != nil {
+		// The Snippet interface has no way to report errors, and the
+		// file has already been opened and parsed successfully. The
+		// error may be transient, so the silence is not cached.
+		log.Printf("error decoding block %d: %v", index, err)
+		call.samples = make([]float64, b.blockSize*b.channels)
+	}
+
+	return call.samples
+}
+
+func (b *blockSource) decode(index int) ([]float64, error) {
+	b.decoderMutex.Lock()
+	defer b.decoderMutex.Unlock()
+
+	samples := make([]float64, b.blockSize*b.channels)
+	n, err := b.decoder.readFrames(index*b.blockSize, samples)
+	if err != nil {
+		return nil, err
+	}
+	return samples[:n], nil
+}
+
+// add caches a block, evicting the least recently used ones beyond the
+// limit. The caller must hold the mutex.
+func (b *blockSource) add(index int, samples []float64) {
+	b.blocks[index] = b.lru.PushFront(&cachedBlock{index, samples})
+	for b.lru.Len() > b.maxBlocks {
+		oldest := b.lru.Back()
+		b.lru.Remove(oldest)
+		delete(b.blocks, oldest.Value.(*cachedBlock).index)
+	}
+}
+
+// view returns a snippet of the whole file with the channel selection of
+// the settings, which is validated up front rather than on first decode.
+func (b *blockSource) view(settings *readSettings) (Snippet, error) {
+	probe := make([]Snippet, b.channels)
+	for i := range probe {
+		probe[i] = &inMemorySnippet{sampleRate: b.sampleRate}
+	}
+	if _, err := settings.downmix(probe); err != nil {
+		return nil, err
+	}
+
+	return &fileSnippet{
+		src:     b,
+		downmix: settings.downmix,
+		length:  b.frames,
+	}, nil
+}
+
+// fileSnippet is a view into a file that is decoded lazily, block by block.
+type fileSnippet struct {
+	src     *blockSource
+	downmix func(channels []Snippet) (Snippet, error)
+	offset  int
+	length  int
+}
+
+func (f *fileSnippet) SampleRate() int {
+	return f.src.sampleRate
+}
+
+func (f *fileSnippet) TotalSamples() int {
+	return f.length
+}
+
+func (f *fileSnippet) Slice(i, sz int) []float64 {
+	j := i + sz
+	if j >= f.length {
+		j = f.length
+	}
+	if j <= i {
+		return nil
+	}
+
+	rv := make([]float64, 0, j-i)
+	bs := f.src.blockSize
+	channels := f.src.channels
+	for pos := f.offset + i; pos < f.offset+j; {
+		block := f.src.block(pos / bs)
+		begin := pos % bs
+		end := begin + (f.offset + j - pos)
+		if end > len(block)/channels {
+			end = len(block) / channels
+		}
+		if begin >= end {
+			break
+		}
+
+		mixed, err := f.downmix(deinterleave(f.SampleRate(), channels, block[begin*channels:end*channels]))
+		if err != nil {
+			// The channel selection was validated when the file was
+			// opened.
+			log.Printf("error mixing down block %d: %v", pos/bs, err)
+			rv = append(rv, make([]float64, end-begin)...)
+		} else {
+			rv = append(rv, mixed.Slice(0, end-begin)...)
+		}
+		pos += end - begin
+	}
+
+	return rv
+}
+
+func (f *fileSnippet) Subsnippet(i, sz int) Snippet {
+	if i > f.length {
+		i = f.length
+	}
+	if i+sz > f.length {
+		sz = f.length - i
+	}
+	return &fileSnippet{
+		src:     f.src,
+		downmix: f.downmix,
+		offset:  f.offset + i,
+		length:  sz,
+	}
+}
+
+func openSource(filename string, opts []ReadOption) (*blockSource, *readSettings, error) {
+	settings := &readSettings{
+		blockSize:    defaultBlockSize,
+		cachedBlocks: defaultCachedBlocks,
+	}
+	AverageChannels{}.Apply(settings)
+	for _, opt := range opts {
+		opt.Apply(settings)
+	}
+
+	decoder, err := openDecoder(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("opened %q: sample rate %v, %d channels, %d frames", filename, decoder.sampleRate(), decoder.channels(), decoder.totalFrames())
+
+	return newBlockSource(decoder, settings.blockSize, settings.cachedBlocks), settings, nil
+}
+
+// Open returns a snippet backed by an audio file, which is decoded lazily
+// as samples are requested. Only a bounded number of decoded blocks is kept
+// in memory, so this is suitable for long recordings. The file remains open
+// for as long as the snippet is in use.
+func Open(filename string, opts ...ReadOption) (Snippet, error) {
+	src, settings, err := openSource(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	snip, err := src.view(settings)
+	if err != nil {
+		src.decoder.close()
+		return nil, err
+	}
+	return snip, nil
+}
+
+// OpenWithChannels is like Open, but also returns a snippet for each
+// channel of the file. All of them share a single decoder and block cache.
+func OpenWithChannels(filename string, opts ...ReadOption) (Snippet, []Snippet, error) {
+	src, settings, err := openSource(filename, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mixed, err := src.view(settings)
+	if err != nil {
+		src.decoder.close()
+		return nil, nil, err
+	}
+
+	var channels []Snippet
+	for c := 0; c < src.channels; c++ {
+		channelSettings := *settings
+		Channel(c).Apply(&channelSettings)
+		snip, err := src.view(&channelSettings)
+		if err != nil {
+			src.decoder.close()
+			return nil, nil, err
+		}
+		channels = append(channels, snip)
+	}
+
+	return mixed, channels, nil
+}
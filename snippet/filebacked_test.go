@@ -0,0 +1,289 @@
+package snippet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func writeTestWAV(t *testing.T, filename string, channels int, samples []int16) {
+	var data []byte
+	le := binary.LittleEndian
+	put16 := func(x uint16) { data = le.AppendUint16(data, x) }
+	put32 := func(x uint32) { data = le.AppendUint32(data, x) }
+
+	data = append(data, "RIFF"...)
+	put32(uint32(36 + 2*len(samples)))
+	data = append(data, "WAVEfmt "...)
+	put32(16)
+	put16(1)
+	put16(uint16(channels))
+	put32(8000)
+	put32(uint32(8000 * 2 * channels))
+	put16(uint16(2 * channels))
+	put16(16)
+	data = append(data, "data"...)
+	put32(uint32(2 * len(samples)))
+	for _, x := range samples {
+		put16(uint16(x))
+	}
+
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		t.Fatalf("unable to write test file: %v", err)
+	}
+}
+
+func TestOpenMatchesRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snippet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var samples []int16
+	for i := 0; i < 100; i++ {
+		samples = append(samples, int16(i*300), int16(-i*100))
+	}
+	filename := filepath.Join(dir, "test.wav")
+	writeTestWAV(t, filename, 2, samples)
+
+	for _, opt := range []ReadOption{AverageChannels{}, Channel(1), Side{}} {
+		want, err := Read(filename, opt)
+		if err != nil {
+			t.Fatalf("Read(%T) = %v", opt, err)
+		}
+
+		got, err := Open(filename, opt, BlockSize(7), CachedBlocks(2))
+		if err != nil {
+			t.Fatalf("Open(%T) = %v", opt, err)
+		}
+
+		if got.TotalSamples() != want.TotalSamples() {
+			t.Fatalf("Open(%T).TotalSamples() = %d, want %d", opt, got.TotalSamples(), want.TotalSamples())
+		}
+
+		for _, r := range [][2]int{{0, 100}, {5, 20}, {90, 50}, {13, 1}, {0, 7}} {
+			gotSlice := got.Slice(r[0], r[1])
+			wantSlice := want.Slice(r[0], r[1])
+			if len(gotSlice) != len(wantSlice) {
+				t.Errorf("Open(%T).Slice(%d, %d) has length %d, want %d", opt, r[0], r[1], len(gotSlice), len(wantSlice))
+				continue
+			}
+			for i := range gotSlice {
+				if gotSlice[i] != wantSlice[i] {
+					t.Errorf("Open(%T).Slice(%d, %d)[%d] = %v, want %v", opt, r[0], r[1], i, gotSlice[i], wantSlice[i])
+					break
+				}
+			}
+		}
+
+		sub := got.Subsnippet(30, 40).Subsnippet(5, 10)
+		subWant := want.Subsnippet(30, 40).Subsnippet(5, 10)
+		if sub.TotalSamples() != subWant.TotalSamples() || sub.Slice(0, 10)[9] != subWant.Slice(0, 10)[9] {
+			t.Errorf("Open(%T).Subsnippet(30, 40).Subsnippet(5, 10) differs from in-memory snippet", opt)
+		}
+	}
+
+	if _, err := Open(filename, Channel(2)); err == nil {
+		t.Errorf("Open(Channel(2)) of stereo input succeeded, want error")
+	}
+}
+
+func TestOpenWithChannels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snippet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var samples []int16
+	for i := 0; i < 50; i++ {
+		samples = append(samples, int16(i*300), int16(-i*100))
+	}
+	filename := filepath.Join(dir, "test.wav")
+	writeTestWAV(t, filename, 2, samples)
+
+	mixed, channels, err := OpenWithChannels(filename, BlockSize(8), CachedBlocks(2))
+	if err != nil {
+		t.Fatalf("OpenWithChannels() = %v", err)
+	}
+	if len(channels) != 2 {
+		t.Fatalf("OpenWithChannels() returned %d channels, want 2", len(channels))
+	}
+
+	for c, snip := range channels {
+		want, err := Read(filename, Channel(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := snip.Slice(3, 40)
+		for i, x := range want.Slice(3, 40) {
+			if got[i] != x {
+				t.Errorf("channel %d sample %d = %v, want %v", c, i+3, got[i], x)
+				break
+			}
+		}
+	}
+
+	mixedSlice := mixed.Slice(0, 50)
+	left, right := channels[0].Slice(0, 50), channels[1].Slice(0, 50)
+	for i := range mixedSlice {
+		if want := (left[i] + right[i]) / 2; mixedSlice[i] != want {
+			t.Errorf("mixed sample %d = %v, want %v", i, mixedSlice[i], want)
+			break
+		}
+	}
+
+	if _, _, err := OpenWithChannels(filename, Channel(2)); err == nil {
+		t.Errorf("OpenWithChannels(Channel(2)) of stereo input succeeded, want error")
+	}
+}
+
+// failingDecoder is a mono decoder of ones that fails the first time it is
+// asked for each block.
+type failingDecoder struct {
+	failed map[int]bool
+}
+
+func (d *failingDecoder) sampleRate() int  { return 8000 }
+func (d *failingDecoder) channels() int    { return 1 }
+func (d *failingDecoder) totalFrames() int { return 16 }
+func (d *failingDecoder) close() error     { return nil }
+
+func (d *failingDecoder) readFrames(frame int, buf []float64) (int, error) {
+	if !d.failed[frame] {
+		d.failed[frame] = true
+		return 0, errors.New("transient failure")
+	}
+	for i := range buf {
+		buf[i] = 1
+	}
+	return len(buf), nil
+}
+
+func TestFailedBlocksAreNotCached(t *testing.T) {
+	src := newBlockSource(&failingDecoder{failed: map[int]bool{}}, 8, 2)
+	settings := &readSettings{}
+	AverageChannels{}.Apply(settings)
+	snip, err := src.view(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := snip.Slice(0, 4)[0]; got != 0 {
+		t.Errorf("first read of failing block = %v, want 0", got)
+	}
+	if got := snip.Slice(0, 4)[0]; got != 1 {
+		t.Errorf("second read of failing block = %v, want 1", got)
+	}
+}
+
+func TestFLACMetadata(t *testing.T) {
+	var data []byte
+	be := binary.BigEndian
+	block := func(kind byte, last bool, body []byte) {
+		if last {
+			kind |= 0x80
+		}
+		n := len(body)
+		data = append(data, kind, byte(n>>16), byte(n>>8), byte(n))
+		data = append(data, body...)
+	}
+
+	data = append(data, "fLaC"...)
+	streamInfo := make([]byte, 34)
+	be.PutUint64(streamInfo[10:], 44100<<44|1<<41|15<<36|123456)
+	block(flacStreamInfo, false, streamInfo)
+	block(1, false, make([]byte, 10))
+	var seekTable []byte
+	for _, p := range [][2]uint64{{0, 0}, {4096, 1000}, {flacPlaceholder, 0}} {
+		seekTable = be.AppendUint64(seekTable, p[0])
+		seekTable = be.AppendUint64(seekTable, p[1])
+		seekTable = be.AppendUint16(seekTable, 4096)
+	}
+	block(flacSeekTable, true, seekTable)
+	data = append(data, "frames"...)
+
+	total, points, audioStart, err := flacMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("flacMetadata() = %v", err)
+	}
+	if total != 123456 {
+		t.Errorf("total = %d, want 123456", total)
+	}
+	if want := int64(len(data) - len("frames")); audioStart != want {
+		t.Errorf("audioStart = %d, want %d", audioStart, want)
+	}
+	if len(points) != 2 || points[1] != (flacSeekPoint{4096, 1000}) {
+		t.Errorf("points = %v, want [{0 0} {4096 1000}]", points)
+	}
+
+	d := &flacDecoder{seekPoints: points}
+	for _, c := range [][2]int{{0, 0}, {4095, 0}, {4096, 4096}, {100000, 4096}} {
+		if got := d.seekPointBefore(c[0]).frame; got != c[1] {
+			t.Errorf("seekPointBefore(%d) = %d, want %d", c[0], got, c[1])
+		}
+	}
+}
+
+// gatedDecoder is a mono decoder of ones that counts its decodes, and
+// decodes of block 1 wait until the gate is opened.
+type gatedDecoder struct {
+	gate    chan struct{}
+	mutex   sync.Mutex
+	decodes map[int]int
+}
+
+func (d *gatedDecoder) sampleRate() int  { return 8000 }
+func (d *gatedDecoder) channels() int    { return 1 }
+func (d *gatedDecoder) totalFrames() int { return 16 }
+func (d *gatedDecoder) close() error     { return nil }
+
+func (d *gatedDecoder) readFrames(frame int, buf []float64) (int, error) {
+	d.mutex.Lock()
+	d.decodes[frame]++
+	d.mutex.Unlock()
+
+	if frame == 8 {
+		<-d.gate
+	}
+	for i := range buf {
+		buf[i] = 1
+	}
+	return len(buf), nil
+}
+
+func TestBlockDecodesAreCoalesced(t *testing.T) {
+	decoder := &gatedDecoder{gate: make(chan struct{}), decodes: map[int]int{}}
+	src := newBlockSource(decoder, 8, 2)
+
+	src.block(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := src.block(1)[0]; got != 1 {
+				t.Errorf("block(1)[0] = %v, want 1", got)
+			}
+		}()
+	}
+
+	// A cached block can be read while another is being decoded.
+	if got := src.block(0)[0]; got != 1 {
+		t.Errorf("block(0)[0] = %v, want 1", got)
+	}
+
+	close(decoder.gate)
+	wg.Wait()
+
+	if decoder.decodes[0] != 1 || decoder.decodes[8] != 1 {
+		t.Errorf("decodes = %v, want each block decoded once", decoder.decodes)
+	}
+}
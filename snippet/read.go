@@ -0,0 +1,118 @@
+package snippet
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"azul3d.org/engine/audio"
+	_ "azul3d.org/engine/audio/flac"
+
+	"github.com/steinarvk/abora/wav"
+)
+
+// sniffWAV checks whether a file is a WAV file, leaving the file
+// positioned at the start.
+func sniffWAV(f io.ReadSeeker) (bool, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return wav.IsWAV(header), nil
+}
+
+func readWAV(filename string, f io.ReadSeeker) ([]Snippet, error) {
+	r, err := wav.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	format := r.Format()
+
+	log.Printf("reading WAV input from %q: sample rate %v (full format %+v)", filename, format.SampleRate, format)
+
+	samples := make([]float64, r.TotalFrames()*format.Channels)
+
+	buf := samples
+	for len(buf) > 0 {
+		read, err := r.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		buf = buf[read:]
+	}
+
+	return deinterleave(format.SampleRate, format.Channels, samples), nil
+}
+
+func readFLAC(filename string, f io.Reader) ([]Snippet, error) {
+	decoder, _, err := audio.NewDecoder(f)
+	if err != nil {
+		return nil, err
+	}
+
+	config := decoder.Config()
+
+	log.Printf("reading FLAC input from %q: sample rate %v (full config %v)", filename, config.SampleRate, config)
+
+	seconds := 1
+	bufsize := seconds * config.SampleRate * config.Channels
+	underlying := audio.Float64{}
+	buf := underlying.Make(bufsize, bufsize)
+
+	var samples []float64
+
+	for {
+		read, err := decoder.Read(buf)
+		if err != nil && err != audio.EOS {
+			return nil, err
+		}
+
+		for i := 0; i < read; i++ {
+			val := buf.At(i)
+			samples = append(samples, val)
+		}
+
+		if err == audio.EOS {
+			break
+		}
+	}
+
+	return deinterleave(config.SampleRate, config.Channels, samples), nil
+}
+
+// ReadAllChannels decodes a whole audio file into memory, returning one
+// snippet per channel. The format (WAV or FLAC) is detected from the file
+// header.
+func ReadAllChannels(filename string) ([]Snippet, error) {
+	fileHandle, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fileHandle.Close()
+
+	isWAV, err := sniffWAV(fileHandle)
+	if err != nil {
+		return nil, err
+	}
+
+	if isWAV {
+		return readWAV(filename, fileHandle)
+	}
+
+	return readFLAC(filename, fileHandle)
+}
+
+// Read decodes a whole audio file into memory. Multichannel input is mixed
+// down according to the options (by default, by averaging all channels).
+func Read(filename string, opts ...ReadOption) (Snippet, error) {
+	channels, err := ReadAllChannels(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return Mixdown(channels, opts...)
+}
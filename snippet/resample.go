@@ -0,0 +1,80 @@
+package snippet
+
+import (
+	"math"
+
+	"github.com/steinarvk/abora/resample"
+)
+
+type resampledSnippet struct {
+	s      Snippet
+	c      *resample.Converter
+	rate   int
+	offset int
+	length int
+}
+
+// Resample returns a view of a snippet at a different sample rate.
+// Samples are computed on demand.
+func Resample(s Snippet, rate int) Snippet {
+	if s.SampleRate() == rate {
+		return s
+	}
+	c := resample.New(s.SampleRate(), rate)
+	return &resampledSnippet{
+		s:      s,
+		c:      c,
+		rate:   rate,
+		length: c.OutputLength(s.TotalSamples()),
+	}
+}
+
+func (r *resampledSnippet) SampleRate() int {
+	return r.rate
+}
+
+func (r *resampledSnippet) TotalSamples() int {
+	return r.length
+}
+
+func (r *resampledSnippet) Slice(i, sz int) []float64 {
+	j := i + sz
+	if j >= r.length {
+		j = r.length
+	}
+	if j <= i {
+		return nil
+	}
+
+	first := r.offset + i
+	last := r.offset + j - 1
+
+	lo := int(math.Floor(r.c.Position(first))) - r.c.Taps() + 1
+	hi := int(math.Floor(r.c.Position(last))) + r.c.Taps()
+	if lo < 0 {
+		lo = 0
+	}
+	in := r.s.Slice(lo, hi-lo+1)
+
+	rv := make([]float64, j-i)
+	for k := range rv {
+		rv[k] = r.c.Interpolate(in, lo, r.c.Position(first+k))
+	}
+	return rv
+}
+
+func (r *resampledSnippet) Subsnippet(i, sz int) Snippet {
+	if i > r.length {
+		i = r.length
+	}
+	if i+sz > r.length {
+		sz = r.length - i
+	}
+	return &resampledSnippet{
+		s:      r.s,
+		c:      r.c,
+		rate:   r.rate,
+		offset: r.offset + i,
+		length: sz,
+	}
+}
@@ -0,0 +1,29 @@
+package snippet
+
+import (
+	"math"
+	"testing"
+
+	"github.com/steinarvk/abora/resample"
+)
+
+func TestResampleMatchesSlice(t *testing.T) {
+	samples := make([]float64, 5000)
+	for i := range samples {
+		samples[i] = math.Sin(float64(i) * 0.05)
+	}
+	want := resample.Slice(samples, 8000, 11025)
+
+	snip := Resample(New(8000, samples), 11025)
+	if snip.TotalSamples() != len(want) {
+		t.Fatalf("TotalSamples() = %d, want %d", snip.TotalSamples(), len(want))
+	}
+
+	sub := snip.Subsnippet(1000, 2000)
+	got := sub.Slice(500, 100)
+	for i, x := range got {
+		if math.Abs(x-want[1500+i]) > 1e-12 {
+			t.Fatalf("Subsnippet(1000, 2000).Slice(500, 100)[%d] = %v, want %v", i, x, want[1500+i])
+		}
+	}
+}
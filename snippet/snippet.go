@@ -1,15 +1,7 @@
-// Package snippet implements an in-memory sound snippet.
+// Package snippet implements sound snippets, either held in memory or
+// decoded lazily from a file.
 package snippet
 
-import (
-	"errors"
-	"log"
-	"os"
-
-	"azul3d.org/engine/audio"
-	_ "azul3d.org/engine/audio/flac"
-)
-
 type Snippet interface {
 	SampleRate() int
 	TotalSamples() int
@@ -21,6 +13,9 @@ func Duration(s Snippet) float64 {
 	return float64(s.TotalSamples()) / float64(s.SampleRate())
 }
 
+// Scan sends the samples of a snippet over a channel. This is convenient,
+// but slow for bulk processing, where reading blocks of samples with Slice
+// is preferable.
 func Scan(s Snippet) <-chan float64 {
 	sz := 4096
 	ch := make(chan float64, sz)
@@ -51,6 +46,14 @@ type inMemorySnippet struct {
 	samples    []float64
 }
 
+// New returns an in-memory snippet containing the given samples.
+func New(sampleRate int, samples []float64) Snippet {
+	return &inMemorySnippet{
+		sampleRate: sampleRate,
+		samples:    samples,
+	}
+}
+
 func (m *inMemorySnippet) Subsnippet(i, sz int) Snippet {
 	return &inMemorySnippet{
 		sampleRate: m.sampleRate,
@@ -73,51 +76,3 @@ func (m *inMemorySnippet) Slice(i, sz int) []float64 {
 	}
 	return m.samples[i:j]
 }
-
-func Read(filename string) (Snippet, error) {
-	fileHandle, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-
-	decoder, _, err := audio.NewDecoder(fileHandle)
-	if err != nil {
-		fileHandle.Close()
-		return nil, err
-	}
-
-	config := decoder.Config()
-
-	if config.Channels != 1 {
-		return nil, errors.New("expected mono input")
-	}
-
-	log.Printf("reading FLAC input from %q: sample rate %v (full config %v)", filename, config.SampleRate, config)
-
-	seconds := 1
-	bufsize := seconds * config.SampleRate
-	underlying := audio.Float64{}
-	buf := underlying.Make(bufsize, bufsize)
-
-	rv := &inMemorySnippet{
-		sampleRate: config.SampleRate,
-	}
-
-	for {
-		read, err := decoder.Read(buf)
-		if err != nil && err != audio.EOS {
-			return nil, err
-		}
-
-		for i := 0; i < read; i++ {
-			val := buf.At(i)
-			rv.samples = append(rv.samples, val)
-		}
-
-		if err == audio.EOS {
-			break
-		}
-	}
-
-	return rv, nil
-}
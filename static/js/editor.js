@@ -9,6 +9,44 @@ var extendingLineseg = null;
 
 var fullModel = [];
 
+// Frequencies are laid out linearly in a warped coordinate that depends
+// on the frequency scale of the spectrogram.
+function warp(scale, hz) {
+  if (scale == "log" || scale == "semitone") {
+    return Math.log(hz);
+  }
+  if (scale == "mel") {
+    return 2595 * Math.log10(1 + hz / 700);
+  }
+  return hz;
+}
+
+function unwarp(scale, x) {
+  if (scale == "log" || scale == "semitone") {
+    return Math.exp(x);
+  }
+  if (scale == "mel") {
+    return 700 * (Math.pow(10, x / 2595) - 1);
+  }
+  return x;
+}
+
+function fromPixelspaceX(trans, x) {
+  return x * trans.xMul + trans.xAdd;
+}
+
+function fromPixelspaceY(trans, y) {
+  return unwarp(trans.scale, y * trans.yMul + trans.yAdd);
+}
+
+function toPixelspaceX(trans, t) {
+  return (t - trans.xAdd) / trans.xMul;
+}
+
+function toPixelspaceY(trans, freq) {
+  return (warp(trans.scale, freq) - trans.yAdd) / trans.yMul;
+}
+
 function createLineseg(x, y) {
   var xTrans = 0;
 
@@ -103,32 +141,18 @@ function createLineseg(x, y) {
     }
   }
 
-  function fromPixelspaceX(trans, x) {
-    return x * trans.xMul + trans.xAdd;
-  }
-
-  function fromPixelspaceY(trans, y) {
-    return y * trans.yMul + trans.yAdd;
-  }
-
   rv.setTransformation = function(oldTrans, newTrans) {
-    var nxAdd = newTrans.xAdd, nxMul = newTrans.xMul;
-    var nyAdd = newTrans.yAdd, nyMul = newTrans.yMul;
-    console.log("setting trans " + nxAdd);
-    console.log("setting trans " + nxMul);
-    console.log("setting trans " + nyAdd);
-    console.log("setting trans " + nyMul);
     function transformX(x) {
-      return (fromPixelspaceX(oldTrans, x) - nxAdd) / nxMul;
+      return toPixelspaceX(newTrans, fromPixelspaceX(oldTrans, x));
     }
     function transformY(y) {
-      return (fromPixelspaceY(oldTrans, y) - nyAdd) / nyMul;
+      return toPixelspaceY(newTrans, fromPixelspaceY(oldTrans, y));
     }
     dots.forEach(function(dot) {
       console.log("dot was at x " + dot.get("left"));
       console.log("dot was at y " + dot.get("top"));
       console.log("dot was at time " + fromPixelspaceX(oldTrans, dot.get("left")));
-      console.log("dot was at freq " + fromPixelspaceX(oldTrans, dot.get("top")));
+      console.log("dot was at freq " + fromPixelspaceY(oldTrans, dot.get("top")));
       dot.set("left", transformX(dot.get("left")));;
       dot.set("top", transformY(dot.get("top")));;
     });
@@ -142,7 +166,7 @@ function createLineseg(x, y) {
       console.log("dot is at x " + dot.get("left"));
       console.log("dot is at y " + dot.get("top"));
       console.log("dot is at time " + fromPixelspaceX(newTrans, dot.get("left")));
-      console.log("dot is at freq " + fromPixelspaceX(newTrans, dot.get("top")));
+      console.log("dot is at freq " + fromPixelspaceY(newTrans, dot.get("top")));
     });
   }
 
@@ -154,6 +178,17 @@ function createLineseg(x, y) {
     rv.setColour("purple");
   };
 
+  rv.toSegment = function(trans) {
+    var points = [];
+    dots.forEach(function(dot) {
+      points.push({
+        Time: fromPixelspaceX(trans, dot.get("left")),
+        Frequency: fromPixelspaceY(trans, dot.get("top")),
+      });
+    });
+    return {Points: points};
+  }
+
   rv.toStringForm = function(trans) {
     var rv = [];
     dots.forEach(function(dot) {
@@ -180,8 +215,32 @@ function createLineseg(x, y) {
   return rv;
 }
 
+function createLinesegFromSegment(segment, trans) {
+  var points = segment.Points;
+  var lineseg = createLineseg(toPixelspaceX(trans, points[0].Time), toPixelspaceY(trans, points[0].Frequency));
+  for (var i = 1; i < points.length; i++) {
+    var x = toPixelspaceX(trans, points[i].Time);
+    var y = toPixelspaceY(trans, points[i].Frequency);
+    if (i == 1) {
+      lineseg.setEndpoint(x, y);
+    } else {
+      lineseg.addPoint(x, y);
+    }
+  }
+  lineseg.setColour("blue");
+  return lineseg;
+}
+
+var dirty = false;
+
+function markDirty() {
+  dirty = true;
+  $("#saveStatus").text("Unsaved changes");
+}
+
 canvas.on("mouse:down", function(option) {
   console.log(option);
+  markDirty();
   var x = option.e.offsetX, y = option.e.offsetY;
   if (extendingLineseg && option.e.ctrlKey) {
     console.log("handling extendingLineSeg");
@@ -211,8 +270,10 @@ canvas.on("mouse:down", function(option) {
 canvas.on("mouse:move", function(option) {
   if (drawingLineseg) {
     drawingLineseg.setEndpoint(option.e.offsetX, option.e.offsetY);
+    markDirty();
   } else if(extendingLineseg) {
     extendingLineseg.setEndpoint(option.e.offsetX, option.e.offsetY);
+    markDirty();
   }
 
   canvas.renderAll();
@@ -230,6 +291,10 @@ canvas.on("mouse:up", function(option) {
 document.addEventListener("keydown", function(evt) {
   var x = evt.offsetX, y = evt.offsetY;
 
+  if (evt.key === "Delete" || evt.key === "Backspace") {
+    markDirty();
+  }
+
   if (evt.key === "Delete") {
     if (selectedItem) {
       selectedItem.remove();
@@ -254,6 +319,11 @@ document.addEventListener("keydown", function(evt) {
   canvas.renderAll();
 }, false);
 
+var currentChannel = -1;
+var currentScale = "linear";
+var currentTransform = "pwelch";
+var currentWindow = "hann";
+
 function makeParams(offset, duration) {
   var w = canvas.width, h = canvas.height;
   var params = {
@@ -261,6 +331,10 @@ function makeParams(offset, duration) {
     pxHeight: h,
     duration: duration,
     t: offset,
+    channel: currentChannel,
+    scale: currentScale,
+    transform: currentTransform,
+    window: currentWindow,
   };
   return params;
 }
@@ -281,19 +355,28 @@ var currentDuration = 1000;
 
 var transformation = null;
 
-function refreshView() {
+function refreshView(onTransformation) {
   var params = makeParams(currentOffset, currentDuration);
   withMetadata(params, function(metadata) {
+    var scale = metadata.FrequencyScale;
     var newTrans = {
+      scale: scale,
       xAdd: currentOffset,
       xMul: 1.0 / metadata.TimeResolution,
-      yAdd: metadata.HighFrequency,
-      yMul: (metadata.LowFrequency - metadata.HighFrequency) / metadata.FrequencyBuckets
+      yAdd: warp(scale, metadata.HighFrequency),
+      yMul: (warp(scale, metadata.LowFrequency) - warp(scale, metadata.HighFrequency)) / metadata.FrequencyBuckets
     };
     fullModel.forEach(function(x) {
       x.setTransformation(transformation, newTrans);
     });
     transformation = newTrans;
+    updateChannelSelector(metadata.Channels);
+    inputDuration = metadata.InputDuration;
+    refreshPitch(params);
+    refreshNotes();
+    if (onTransformation) {
+      onTransformation();
+    }
     canvas.renderAll();
   });
   displayBackgroundSpectrogram(params);
@@ -302,6 +385,7 @@ function refreshView() {
 
 $("body").append($("<button/>").text("Forward").click(function() {
   currentOffset += 2;
+  markDirty();
   refreshView();
 }));
 
@@ -310,9 +394,306 @@ $("body").append($("<button/>").text("Back").click(function() {
   if (currentOffset < 0) {
     currentOffset = 0;
   }
+  markDirty();
   refreshView();
 }));
 
+var channelSelector = $("<select id='channel'/>").change(function() {
+  currentChannel = parseInt($(this).val());
+  markDirty();
+  refreshView();
+});
+
+function updateChannelSelector(channels) {
+  if (channelSelector.children().length == channels + 1) {
+    return;
+  }
+  channelSelector.empty();
+  channelSelector.append($("<option/>").val(-1).text("Mixdown"));
+  for (var i = 0; i < channels; i++) {
+    channelSelector.append($("<option/>").val(i).text("Channel " + i));
+  }
+  channelSelector.val(currentChannel);
+}
+
+$("body").append(channelSelector);
+
+var scaleSelector = $("<select id='scale'/>").change(function() {
+  currentScale = $(this).val();
+  refreshView();
+});
+["linear", "log", "mel", "semitone"].forEach(function(scale) {
+  scaleSelector.append($("<option/>").val(scale).text(scale));
+});
+$("body").append(scaleSelector);
+
+var transformSelector = $("<select id='transform'/>").change(function() {
+  currentTransform = $(this).val();
+  refreshView();
+});
+transformSelector.append($("<option/>").val("pwelch").text("Welch"));
+transformSelector.append($("<option/>").val("constantq").text("Constant-Q"));
+transformSelector.append($("<option/>").val("reassigned").text("Reassigned"));
+$("body").append(transformSelector);
+
+var windowSelector = $("<select id='window'/>").change(function() {
+  currentWindow = $(this).val();
+  refreshView();
+});
+["hann", "hamming", "blackmanharris", "kaiser", "gaussian", "rectangular"].forEach(function(name) {
+  windowSelector.append($("<option/>").val(name).text(name));
+});
+$("body").append(windowSelector);
+
+var pitchLines = [];
+
+function clearPitch() {
+  pitchLines.forEach(function(line) {
+    canvas.remove(line);
+  });
+  pitchLines = [];
+}
+
+// Draws the pitch track as one polyline per voiced run.
+function displayPitch(frames) {
+  clearPitch();
+  var run = [];
+  function flush() {
+    if (run.length >= 2) {
+      var line = new fabric.Polyline(run, {
+        stroke: "white",
+        strokeWidth: 2,
+        fill: null,
+        selectable: false,
+        evented: false,
+      });
+      pitchLines.push(line);
+      canvas.add(line);
+    }
+    run = [];
+  }
+  frames.forEach(function(frame) {
+    if (!frame.Voiced) {
+      flush();
+      return;
+    }
+    run.push({
+      x: toPixelspaceX(transformation, frame.Time),
+      y: toPixelspaceY(transformation, frame.Frequency),
+    });
+  });
+  flush();
+  canvas.renderAll();
+}
+
+function refreshPitch(params) {
+  if (!$("#showPitch").is(":checked")) {
+    clearPitch();
+    return;
+  }
+  var trans = transformation;
+  $.get("/pitch", params).done(function(frames) {
+    if (trans === transformation) {
+      displayPitch(frames);
+    }
+  });
+}
+
+$("body").append($("<label/>").text("Pitch").prepend($("<input type='checkbox' id='showPitch'/>").change(function() {
+  refreshPitch(makeParams(currentOffset, currentDuration));
+})));
+
+var noteMarkers = [];
+var noteBoundaries = [];
+// The channel and time range in which noteBoundaries were detected.
+var notesRegion = null;
+var inputDuration = null;
+
+// Space left before a note when jumping to it, in seconds.
+var noteMargin = 0.25;
+
+// Note boundaries are detected for the view and up to as much again on
+// either side, so that nearby notes can be jumped to without another
+// request. Jumps further afield search onwards a chunk at a time.
+var notesChunkSeconds = 60;
+
+function withNotes(start, end, f) {
+  start = Math.max(0, start);
+  if (inputDuration !== null) {
+    end = Math.min(end, inputDuration);
+  }
+  if (notesRegion !== null && notesRegion.channel === currentChannel &&
+      notesRegion.start <= start && end <= notesRegion.end) {
+    f();
+    return;
+  }
+  var channel = currentChannel;
+  $.get("/notes", {t: start, duration: end - start, channel: channel}).done(function(boundaries) {
+    noteBoundaries = boundaries;
+    notesRegion = {channel: channel, start: start, end: end};
+    f();
+  }).fail(function(xhr) {
+    $("#saveStatus").text("Unable to detect notes: " + xhr.responseText);
+  });
+}
+
+function withViewNotes(f) {
+  var margin = Math.min(currentDuration, notesChunkSeconds);
+  withNotes(currentOffset - margin, currentOffset + currentDuration + margin, f);
+}
+
+function clearNotes() {
+  noteMarkers.forEach(function(marker) {
+    canvas.remove(marker);
+  });
+  noteMarkers = [];
+}
+
+function displayNotes() {
+  clearNotes();
+  noteBoundaries.forEach(function(boundary) {
+    var x = toPixelspaceX(transformation, boundary.Time);
+    if (x < 0 || x > canvas.width) {
+      return;
+    }
+    var marker = new fabric.Line([x, 0, x, canvas.height], {
+      stroke: boundary.Kind === "onset" ? "lime" : "red",
+      strokeWidth: 1,
+      opacity: 0.3 + 0.7 * boundary.Confidence,
+      selectable: false,
+      evented: false,
+    });
+    noteMarkers.push(marker);
+    canvas.add(marker);
+  });
+  canvas.renderAll();
+}
+
+function refreshNotes() {
+  if (!$("#showNotes").is(":checked")) {
+    clearNotes();
+    return;
+  }
+  withViewNotes(displayNotes);
+}
+
+// findNote returns the time of the nearest onset after (or before) position
+// among the detected boundaries, or null if there is none.
+function findNote(direction, position) {
+  var target = null;
+  noteBoundaries.forEach(function(boundary) {
+    if (boundary.Kind !== "onset") {
+      return;
+    }
+    if (direction > 0 && boundary.Time > position + 0.01 && target === null) {
+      target = boundary.Time;
+    }
+    if (direction < 0 && boundary.Time < position - 0.01) {
+      target = boundary.Time;
+    }
+  });
+  return target;
+}
+
+function jumpToNote(direction) {
+  var position = currentOffset + noteMargin;
+  // Successive chunks overlap by a view, so that notes near their edges
+  // are detected in context.
+  var search = function(start, end) {
+    withNotes(start, end, function() {
+      var target = findNote(direction, position);
+      if (target !== null) {
+        currentOffset = Math.max(0, target - noteMargin);
+        markDirty();
+        refreshView();
+        return;
+      }
+      if (direction > 0 && inputDuration !== null && end < inputDuration) {
+        search(end - currentDuration, end + notesChunkSeconds);
+      }
+      if (direction < 0 && start > 0) {
+        search(start - notesChunkSeconds, start + currentDuration);
+      }
+    });
+  };
+  var margin = Math.min(currentDuration, notesChunkSeconds);
+  search(currentOffset - margin, currentOffset + currentDuration + margin);
+}
+
+$("body").append($("<label/>").text("Notes").prepend($("<input type='checkbox' id='showNotes'/>").change(refreshNotes)));
+
+$("body").append($("<button/>").text("Previous note").click(function() {
+  jumpToNote(-1);
+}));
+
+$("body").append($("<button/>").text("Next note").click(function() {
+  jumpToNote(1);
+}));
+
+var player = null;
+
+function stopPlayback() {
+  if (player) {
+    player.pause();
+    player = null;
+  }
+  $("#play").text("Play");
+  $("#preview").text("Preview");
+}
+
+function startPlayback(url, button) {
+  player = new Audio(url);
+  player.addEventListener("ended", stopPlayback);
+  player.play();
+  button.text("Stop");
+}
+
+$("body").append($("<button id='play'/>").text("Play").click(function() {
+  if (player) {
+    stopPlayback();
+    return;
+  }
+  var params = makeParams(currentOffset, currentDuration);
+  params.rate = $("#playbackRate").val();
+  params.bandpass = $("#bandpass").is(":checked") ? 1 : 0;
+  startPlayback("/audio?" + $.param(params), $("#play"));
+}));
+
+$("body").append($("<button id='preview'/>").text("Preview").click(function() {
+  if (player) {
+    stopPlayback();
+    return;
+  }
+  var params = makeParams(currentOffset, currentDuration);
+  params.balance = $("#balance").val();
+  fetch("/preview?" + $.param(params), {
+    method: "POST",
+    body: JSON.stringify({Segments: currentSegments()}),
+  }).then(function(response) {
+    if (!response.ok) {
+      return response.text().then(function(text) {
+        throw new Error(text);
+      });
+    }
+    return response.blob();
+  }).then(function(blob) {
+    startPlayback(URL.createObjectURL(blob), $("#preview"));
+  }).catch(function(err) {
+    $("#saveStatus").text("Preview failed: " + err.message);
+  });
+}));
+
+$("body").append($("<label/>").text("Original").append(
+  $("<input type='range' id='balance' min='0' max='1' step='0.05' value='0.5'/>")).append("Synthesized"));
+
+var playbackRateSelector = $("<select id='playbackRate'/>");
+[1, 0.5, 0.25].forEach(function(rate) {
+  playbackRateSelector.append($("<option/>").val(rate).text(rate + "x"));
+});
+$("body").append(playbackRateSelector);
+
+$("body").append($("<label/>").text("Band-pass").prepend($("<input type='checkbox' id='bandpass'/>")));
+
 $("body").append($("<textarea id='dump'/>"));
 
 $("body").append($("<button/>").text("Dump").click(function() {
@@ -324,6 +705,84 @@ $("body").append($("<button/>").text("Dump").click(function() {
   $("#dump").text(rv.join("\n"));
 }));
 
-refreshView();
+var projectName = null;
+var saving = false;
+
+function currentSegments() {
+  var segments = [];
+  fullModel.forEach(function(x) {
+    var segment = x.toSegment(transformation);
+    var points = segment.Points;
+    if (points.length >= 2 && points[points.length-1].Time > points[0].Time) {
+      segments.push(segment);
+    }
+  });
+  return segments;
+}
+
+function saveProject() {
+  if (!transformation || projectName === null || saving) {
+    return;
+  }
+
+  var segments = currentSegments();
+
+  var project = {
+    Segments: segments,
+    Editor: {
+      Offset: currentOffset,
+      Channel: currentChannel,
+    },
+  };
+
+  dirty = false;
+  saving = true;
+  $.ajax({
+    url: "/project?" + $.param({name: projectName}),
+    method: "POST",
+    contentType: "application/json",
+    data: JSON.stringify(project),
+  }).done(function() {
+    if (!dirty) {
+      $("#saveStatus").text("Saved " + segments.length + " segments to project " + projectName);
+    }
+  }).fail(function(xhr) {
+    dirty = true;
+    $("#saveStatus").text("Save failed: " + xhr.responseText);
+  }).always(function() {
+    saving = false;
+  });
+}
+
+$("body").append($("<button/>").text("Save").click(saveProject));
+$("body").append($("<span id='saveStatus'/>"));
+
+setInterval(function() {
+  if (dirty) {
+    saveProject();
+  }
+}, 3000);
+
+function loadProject() {
+  $.get("/project").done(function(project) {
+    projectName = project.Name;
+    currentOffset = project.Editor.Offset;
+    currentChannel = project.Editor.Channel;
+    refreshView(function() {
+      (project.Segments || []).forEach(function(segment) {
+        createLinesegFromSegment(segment, transformation);
+      });
+      canvas.renderAll();
+      if (project.Exists) {
+        $("#saveStatus").text("Loaded " + project.Segments.length + " segments from project " + projectName);
+      }
+    });
+  }).fail(function(xhr) {
+    $("#saveStatus").text("Unable to load project: " + xhr.responseText);
+    refreshView();
+  });
+}
+
+loadProject();
 
 });
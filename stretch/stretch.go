@@ -0,0 +1,112 @@
+// Package stretch changes the duration of audio without changing its
+// pitch.
+package stretch
+
+import (
+	"math"
+)
+
+const (
+	frameSeconds     = 0.04
+	toleranceSeconds = 0.01
+
+	// Only every nth sample is considered when comparing waveforms.
+	comparisonStride = 2
+)
+
+func hann(n int) []float64 {
+	rv := make([]float64, n)
+	for i := range rv {
+		rv[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+	}
+	return rv
+}
+
+func at(xs []float64, i int) float64 {
+	if i < 0 || i >= len(xs) {
+		return 0
+	}
+	return xs[i]
+}
+
+// bestMatch finds the position within tolerance of nominal whose waveform
+// best continues the one at target, by normalized cross-correlation.
+func bestMatch(xs []float64, target, nominal, tolerance, length int) int {
+	best := nominal
+	bestScore := math.Inf(-1)
+
+	for delta := -tolerance; delta <= tolerance; delta++ {
+		pos := nominal + delta
+		if pos < 0 {
+			continue
+		}
+
+		dot, energy := 0.0, 0.0
+		for i := 0; i < length; i += comparisonStride {
+			x := at(xs, pos+i)
+			dot += x * at(xs, target+i)
+			energy += x * x
+		}
+
+		score := dot
+		if energy > 0 {
+			score /= math.Sqrt(energy)
+		}
+		if score > bestScore {
+			best, bestScore = pos, score
+		}
+	}
+
+	return best
+}
+
+// Stretch returns the signal lengthened by the given factor (a factor of
+// 2 plays at half speed), preserving pitch.
+//
+// It uses WSOLA (waveform-similarity overlap-add): output frames are
+// overlapped at a fixed hop while the input is read at a slower or faster
+// hop, and each input frame is shifted slightly so that it lines up with
+// the waveform of the previous one.
+func Stretch(xs []float64, sampleRate int, factor float64) []float64 {
+	if factor == 1 || len(xs) == 0 {
+		return append([]float64(nil), xs...)
+	}
+
+	frameSize := 2 * int(frameSeconds*float64(sampleRate)/2)
+	if frameSize < 4 {
+		frameSize = 4
+	}
+	outputHop := frameSize / 2
+	inputHop := float64(outputHop) / factor
+	tolerance := int(toleranceSeconds * float64(sampleRate))
+
+	window := hann(frameSize)
+
+	n := int(float64(len(xs)) * factor)
+	out := make([]float64, n+frameSize)
+	weight := make([]float64, n+frameSize)
+
+	prev := 0
+	for k := 0; k*outputHop < n; k++ {
+		pos := int(float64(k)*inputHop + 0.5)
+		if k > 0 {
+			pos = bestMatch(xs, prev+outputHop, pos, tolerance, outputHop)
+		}
+
+		offset := k * outputHop
+		for i, w := range window {
+			out[offset+i] += w * at(xs, pos+i)
+			weight[offset+i] += w
+		}
+
+		prev = pos
+	}
+
+	for i := range out {
+		if weight[i] > 1e-6 {
+			out[i] /= weight[i]
+		}
+	}
+
+	return out[:n]
+}
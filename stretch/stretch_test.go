@@ -0,0 +1,58 @@
+package stretch
+
+import (
+	"math"
+	"testing"
+)
+
+func zeroCrossings(xs []float64) int {
+	rv := 0
+	for i := 1; i < len(xs); i++ {
+		if (xs[i-1] < 0) != (xs[i] < 0) {
+			rv++
+		}
+	}
+	return rv
+}
+
+func TestStretchPreservesPitch(t *testing.T) {
+	const rate = 44100
+	const freq = 440.0
+
+	xs := make([]float64, rate)
+	for i := range xs {
+		xs[i] = math.Sin(2 * math.Pi * freq * float64(i) / rate)
+	}
+
+	for _, factor := range []float64{0.5, 2, 3} {
+		ys := Stretch(xs, rate, factor)
+
+		if want := int(float64(len(xs)) * factor); len(ys) != want {
+			t.Errorf("Stretch(%v) gave %d samples, want %d", factor, len(ys), want)
+			continue
+		}
+
+		// Skip the edges, where frames are only partially overlapped.
+		middle := ys[len(ys)/4 : 3*len(ys)/4]
+		seconds := float64(len(middle)) / rate
+		got := float64(zeroCrossings(middle)) / 2 / seconds
+		if math.Abs(got-freq) > 5 {
+			t.Errorf("Stretch(%v) has frequency %v Hz, want %v", factor, got, freq)
+		}
+
+		energy := 0.0
+		for _, y := range middle {
+			energy += y * y
+		}
+		if rms := math.Sqrt(energy / float64(len(middle))); math.Abs(rms-math.Sqrt(0.5)) > 0.05 {
+			t.Errorf("Stretch(%v) has RMS %v, want %v", factor, rms, math.Sqrt(0.5))
+		}
+
+		for i, y := range middle {
+			if math.Abs(y) > 1.01 {
+				t.Errorf("Stretch(%v) has sample #%d = %v beyond original range", factor, i, y)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package chirp
+
+import (
+	"github.com/steinarvk/abora/synth/envelope"
+	"github.com/steinarvk/abora/synth/oscillator"
+	"github.com/steinarvk/abora/synth/varying"
+)
+
+// Block is a Chirp that can render many samples at once, which avoids the
+// overhead of calling Advance, Sample and Done for every sample.
+type Block interface {
+	Chirp
+
+	// Process advances by dt and then writes the sample to out[i], for
+	// each i in turn. If the chirp is done after some sample, it stops
+	// there and returns the number of samples written (including that
+	// one); otherwise it returns len(out). A chirp that ends on the last
+	// sample also returns len(out), so callers check Done.
+	Process(out []float64, dt float64) int
+}
+
+// Process renders samples of c as described by Block, using the Block
+// implementation if c has one.
+func Process(c Chirp, out []float64, dt float64) int {
+	if b, ok := c.(Block); ok {
+		return b.Process(out, dt)
+	}
+	for i := range out {
+		c.Advance(dt)
+		out[i] = c.Sample()
+		if c.Done() {
+			return i + 1
+		}
+	}
+	return len(out)
+}
+
+// grow returns a slice of length n, reusing buf if it is large enough.
+func grow(buf []float64, n int) []float64 {
+	if cap(buf) < n {
+		return make([]float64, n)
+	}
+	return buf[:n]
+}
+
+func (c *chirp) Process(out []float64, dt float64) int {
+	n := len(out)
+
+	// The oscillator is advanced by the frequency before it changes.
+	c.duBuf = grow(c.duBuf, n)
+	varying.Sample(c.freq, c.duBuf, dt)
+	for i, f := range c.duBuf {
+		c.duBuf[i] = f * dt
+	}
+	oscillator.Process(c.osc, out, c.duBuf)
+
+	c.ampBuf = grow(c.ampBuf, n)
+	done := envelope.Process(c.env, c.ampBuf, dt)
+
+	for i := 0; i < done; i++ {
+		out[i] *= c.ampBuf[i]
+	}
+
+	if c.tremolo != nil {
+		varying.Process(c.tremolo, c.ampBuf, dt)
+		for i := 0; i < done; i++ {
+			out[i] *= c.ampBuf[i]
+		}
+	}
+
+	return done
+}
@@ -45,6 +45,9 @@ type chirp struct {
 	env     envelope.Envelope
 	freq    varying.Varying
 	tremolo varying.Varying
+
+	// Buffers for Process.
+	duBuf, ampBuf []float64
 }
 
 func (c *chirp) Sample() float64 {
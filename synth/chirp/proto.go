@@ -2,8 +2,10 @@ package chirp
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/steinarvk/abora/synth/envelope"
+	"github.com/steinarvk/abora/synth/interpolation"
 	"github.com/steinarvk/abora/synth/oscillator"
 	"github.com/steinarvk/abora/synth/varying"
 
@@ -116,6 +118,16 @@ func maybeAdd(xs []*pb.Point, x *pb.Point) []*pb.Point {
 }
 
 func makeVarying(def *pb.Point, xs []*pb.Point, name string, errOut *error, extractor func(*pb.PointSettings) *pb.DoubleOrHold) varying.Varying {
+	pts := makePoints(def, xs, name, errOut, extractor)
+	if *errOut != nil {
+		return nil
+	}
+	return varying.NewInterpolated(pts)
+}
+
+// makePoints resolves the values (and holds) of a sequence of points,
+// beginning with the default if the sequence doesn't start at 0.
+func makePoints(def *pb.Point, xs []*pb.Point, name string, errOut *error, extractor func(*pb.PointSettings) *pb.DoubleOrHold) []varying.Point {
 	if *errOut != nil {
 		return nil
 	}
@@ -174,23 +186,92 @@ func makeVarying(def *pb.Point, xs []*pb.Point, name string, errOut *error, extr
 			return nil
 		}
 
+		// Linear segments are left to the varying's own interpolation.
+		var curve interpolation.Curve
+		if p.Curve != pb.Curve_LINEAR {
+			var err error
+			curve, err = interpolation.CurveFromProto(p.Curve)
+			if err != nil {
+				*errOut = fmt.Errorf("interpolation sequence for %v: %v", name, err)
+				return nil
+			}
+		}
+
 		pts = append(pts, varying.Point{
 			Time:  p.T,
 			Value: value,
+			Curve: curve,
 		})
 
 		lastTime = p.T
 	}
 
-	return varying.NewInterpolated(pts)
+	return pts
 }
 
-func FromProto(spec *pb.Chirp, context *pb.Context) (*TimedChirp, error) {
-	context = OverrideContext(
+func allZero(points []varying.Point) bool {
+	for _, p := range points {
+		if p.Value != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// chirpContext applies the score's context and the chirp's own override
+// on top of the built-in defaults.
+func chirpContext(spec *pb.Chirp, context *pb.Context) *pb.Context {
+	return OverrideContext(
 		OverrideContext(
 			OverrideContext(nil, defaultsContext),
 			context),
 		spec.ContextOverride)
+}
+
+// Contours returns the points between which the frequency and amplitude of
+// a chirp are interpolated when it is synthesized, with holds resolved and
+// the context's initial values filled in.
+func Contours(spec *pb.Chirp, context *pb.Context) (freq, amplitude []varying.Point, err error) {
+	context = chirpContext(spec, context)
+
+	initialPoint := &pb.Point{T: 0, Settings: context.Initial}
+
+	var freqDH, ampDH []*pb.Point
+	for _, point := range spec.Points {
+		if point.Settings == nil {
+			continue
+		}
+		if point.Settings.Freq != nil {
+			freqDH = maybeAdd(freqDH, point)
+		}
+		if point.Settings.Amplitude != nil {
+			ampDH = maybeAdd(ampDH, point)
+		}
+	}
+
+	freq = makePoints(initialPoint, freqDH, "Freq", &err, func(s *pb.PointSettings) *pb.DoubleOrHold {
+		return s.GetFreq()
+	})
+	amplitude = makePoints(initialPoint, ampDH, "Amplitude", &err, func(s *pb.PointSettings) *pb.DoubleOrHold {
+		return s.GetAmplitude()
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return freq, amplitude, nil
+}
+
+func FromProto(spec *pb.Chirp, context *pb.Context) (*TimedChirp, error) {
+	if spec.Duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive (got %v)", spec.Duration)
+	}
+
+	if spec.BeginTime < 0 {
+		return nil, fmt.Errorf("begin time must not be negative (got %v)", spec.BeginTime)
+	}
+
+	context = chirpContext(spec, context)
 
 	initialPoint := &pb.Point{T: 0, Settings: context.Initial}
 
@@ -235,7 +316,7 @@ func FromProto(spec *pb.Chirp, context *pb.Context) (*TimedChirp, error) {
 	tremFreqV := makeVarying(initialPoint, tremFreqDH, "TremoloFreq", &err, func(s *pb.PointSettings) *pb.DoubleOrHold {
 		return s.GetTremoloFreq()
 	})
-	vibStrV := makeVarying(initialPoint, vibStrDH, "VibratoStrength", &err, func(s *pb.PointSettings) *pb.DoubleOrHold {
+	vibStrPts := makePoints(initialPoint, vibStrDH, "VibratoStrength", &err, func(s *pb.PointSettings) *pb.DoubleOrHold {
 		return s.GetVibratoStrength()
 	})
 	vibFreqV := makeVarying(initialPoint, vibFreqDH, "VibratoFreq", &err, func(s *pb.PointSettings) *pb.DoubleOrHold {
@@ -248,7 +329,7 @@ func FromProto(spec *pb.Chirp, context *pb.Context) (*TimedChirp, error) {
 
 	env, err := envelope.FromProto(context.Envelope, spec.Duration)
 	if err != nil {
-		return nil, fmt.Errorf("constructing envelope from %v of duration %v: %v", context.Envelope, err)
+		return nil, fmt.Errorf("constructing envelope from %v of duration %v: %v", context.Envelope, spec.Duration, err)
 	}
 
 	osc, err := oscillator.FromProto(context.Oscillator)
@@ -256,9 +337,14 @@ func FromProto(spec *pb.Chirp, context *pb.Context) (*TimedChirp, error) {
 		return nil, fmt.Errorf("constructing oscillator from %v: %v", context.Oscillator, err)
 	}
 
-	modifiedFreqV := varying.NewOscillating(freqV,
-		varying.OscillationFreq(vibFreqV),
-		varying.MultiplicativeOscillation(vibStrV))
+	// Without vibrato the frequency is left unwrapped, so that its integral
+	// is known and the chirp can seek.
+	modifiedFreqV := freqV
+	if !allZero(vibStrPts) {
+		modifiedFreqV = varying.NewOscillating(freqV,
+			varying.OscillationFreq(vibFreqV),
+			varying.MultiplicativeOscillation(varying.NewInterpolated(vibStrPts)))
+	}
 
 	tremoloV := varying.NewOscillating(varying.Constant(1),
 		varying.OscillationFreq(tremFreqV),
@@ -273,3 +359,26 @@ func FromProto(spec *pb.Chirp, context *pb.Context) (*TimedChirp, error) {
 		Chirp: rv,
 	}, nil
 }
+
+// ScoreFromProto constructs every chirp of a score, with the score's
+// defaults applied beneath each chirp's own context override. If any chirps
+// are invalid, the error describes all of them.
+func ScoreFromProto(spec *pb.Chirps) ([]TimedChirp, error) {
+	var rv []TimedChirp
+	var problems []string
+
+	for i, chirpSpec := range spec.Chirp {
+		chrp, err := FromProto(chirpSpec, spec.Defaults)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("chirp #%d (at %vs): %v", i, chirpSpec.BeginTime, err))
+			continue
+		}
+		rv = append(rv, *chrp)
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%d of %d chirps invalid: %s", len(problems), len(spec.Chirp), strings.Join(problems, "; "))
+	}
+
+	return rv, nil
+}
@@ -0,0 +1,62 @@
+package chirp
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/steinarvk/abora/proto"
+)
+
+func TestScoreFromProto(t *testing.T) {
+	data, err := ioutil.ReadFile("../../testdata/chirps/scale.pb_text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := &pb.Chirps{}
+	if err := proto.UnmarshalText(string(data), spec); err != nil {
+		t.Fatal(err)
+	}
+
+	chirps, err := ScoreFromProto(spec)
+	if err != nil {
+		t.Fatalf("ScoreFromProto() = %v", err)
+	}
+
+	if len(chirps) != len(spec.Chirp) {
+		t.Fatalf("ScoreFromProto() gave %d chirps, want %d", len(chirps), len(spec.Chirp))
+	}
+
+	for i, c := range chirps {
+		if c.Time != spec.Chirp[i].BeginTime {
+			t.Errorf("chirp %d scheduled at %v, want %v", i, c.Time, spec.Chirp[i].BeginTime)
+		}
+	}
+}
+
+func TestScoreFromProtoReportsAllErrors(t *testing.T) {
+	spec := &pb.Chirps{
+		Chirp: []*pb.Chirp{
+			{BeginTime: 0, Duration: 1},
+			{BeginTime: 1, Duration: 0},
+			{BeginTime: 2, Duration: 1},
+			{BeginTime: 3, Duration: 1, Points: []*pb.Point{
+				{T: 0.5, Settings: &pb.PointSettings{Freq: &pb.DoubleOrHold{}}},
+			}},
+		},
+	}
+
+	_, err := ScoreFromProto(spec)
+	if err == nil {
+		t.Fatalf("ScoreFromProto() succeeded, want error")
+	}
+
+	for _, want := range []string{"2 of 4", "chirp #1", "chirp #3"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("ScoreFromProto() = %q, want it to mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package chirp
+
+import (
+	"github.com/steinarvk/abora/synth/envelope"
+	"github.com/steinarvk/abora/synth/oscillator"
+	"github.com/steinarvk/abora/synth/varying"
+)
+
+// Seeker is a Chirp that can move directly to any time, so that it can be
+// rendered from its middle.
+type Seeker interface {
+	Chirp
+
+	// Seek moves to t seconds after the start. It returns false if this
+	// is not possible (because some component cannot seek), in which case
+	// the chirp is left in an unspecified state and should be discarded.
+	Seek(t float64) bool
+}
+
+// Seek moves c to t seconds after its start, reporting whether it could.
+func Seek(c Chirp, t float64) bool {
+	s, ok := c.(Seeker)
+	return ok && s.Seek(t)
+}
+
+// Seek requires the frequency to be a varying.Integrator, since the phase
+// of the oscillator is its integral. Chirps with vibrato cannot seek.
+func (c *chirp) Seek(t float64) bool {
+	phase, ok := varying.Integral(c.freq, t)
+	if !ok {
+		return false
+	}
+	return oscillator.Seek(c.osc, phase) &&
+		envelope.Seek(c.env, t) &&
+		varying.Seek(c.freq, t) &&
+		varying.Seek(c.tremolo, t)
+}
+
+func (e *TremoloEnvelope) Seek(t float64) bool {
+	return oscillator.Seek(e.osc, t)
+}
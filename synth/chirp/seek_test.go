@@ -0,0 +1,59 @@
+package chirp
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/steinarvk/abora/proto"
+)
+
+func TestSeekMatchesAdvance(t *testing.T) {
+	data, err := ioutil.ReadFile("../../testdata/chirps/scale.pb_text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := &pb.Chirps{}
+	if err := proto.UnmarshalText(string(data), spec); err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		dt       = 1.0 / 44100
+		compared = 500
+	)
+
+	for i, chirpSpec := range spec.Chirp {
+		// Late enough to be past any glide, but before the end.
+		skip := int(0.85 * chirpSpec.Duration / dt)
+
+		played, err := FromProto(chirpSpec, spec.Defaults)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make([]float64, skip+compared)
+		Process(played.Chirp, want, dt)
+
+		sought, err := FromProto(chirpSpec, spec.Defaults)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Seek(sought.Chirp, float64(skip)*dt) {
+			t.Fatalf("chirp %d: Seek() failed", i)
+		}
+		got := make([]float64, compared)
+		Process(sought.Chirp, got, dt)
+
+		for j, x := range got {
+			// The phase reached by Advance is a sum over samples, not
+			// an exact integral, so the results differ very slightly.
+			if math.Abs(x-want[skip+j]) > 1e-2 {
+				t.Errorf("chirp %d: sample %d after seeking = %v, want %v", i, j, x, want[skip+j])
+				break
+			}
+		}
+	}
+}
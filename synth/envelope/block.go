@@ -0,0 +1,112 @@
+package envelope
+
+import (
+	"github.com/steinarvk/abora/synth/varying"
+)
+
+// Block is an Envelope that can compute many amplitudes at once, which
+// avoids the overhead of calling Advance, Amplitude and Done for every
+// sample.
+type Block interface {
+	Envelope
+
+	// Process advances by dt and then writes the amplitude to out[i],
+	// for each i in turn. If the envelope is done after some sample, it
+	// stops there and returns the number of samples written (including
+	// that one); otherwise it returns len(out).
+	Process(out []float64, dt float64) int
+}
+
+// Process computes the amplitudes of env as described by Block, using the
+// Block implementation if env has one.
+func Process(env Envelope, out []float64, dt float64) int {
+	if b, ok := env.(Block); ok {
+		return b.Process(out, dt)
+	}
+	for i := range out {
+		env.Advance(dt)
+		out[i] = env.Amplitude()
+		if env.Done() {
+			return i + 1
+		}
+	}
+	return len(out)
+}
+
+func (e *brickWall) Process(out []float64, dt float64) int {
+	for i := range out {
+		e.Advance(dt)
+		out[i] = e.Amplitude()
+		if e.Done() {
+			return i + 1
+		}
+	}
+	return len(out)
+}
+
+func (x Constant) Process(out []float64, _ float64) int {
+	for i := range out {
+		out[i] = float64(x)
+		if x.Done() {
+			return i + 1
+		}
+	}
+	return len(out)
+}
+
+func (x *interpolatedEnvelope) Process(out []float64, dt float64) int {
+	varying.Process(x.amplitude, out, dt)
+	if !x.finite {
+		return len(out)
+	}
+	for i := range out {
+		x.timeLeft -= dt
+		if x.Done() {
+			out[i] = 0.0
+			return i + 1
+		}
+	}
+	return len(out)
+}
+
+// grow returns a slice of length n, reusing buf if it is large enough.
+func grow(buf []float64, n int) []float64 {
+	if cap(buf) < n {
+		return make([]float64, n)
+	}
+	return buf[:n]
+}
+
+func (c *compositeEnvelope) Process(out []float64, dt float64) int {
+	n := len(out)
+	c.buf = grow(c.buf, len(out))
+
+	for i := range out {
+		out[i] = 1.0
+	}
+
+	for _, e := range c.components {
+		if done := Process(e, c.buf, dt); done < n {
+			n = done
+		}
+		for i, x := range c.buf {
+			out[i] *= x
+		}
+	}
+
+	return n
+}
+
+func (e *withVaryings) Process(out []float64, dt float64) int {
+	n := Process(e.env, out, dt)
+
+	e.buf = grow(e.buf, len(out))
+	for _, v := range e.vary {
+		varying.Process(v, e.buf, dt)
+		for i := 0; i < n; i++ {
+			out[i] *= e.buf[i]
+		}
+	}
+
+	return n
+}
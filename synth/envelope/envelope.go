@@ -2,6 +2,7 @@ package envelope
 
 import (
 	"log"
+	"math"
 
 	"github.com/steinarvk/abora/synth/interpolation"
 	"github.com/steinarvk/abora/synth/varying"
@@ -17,11 +18,12 @@ type Envelope interface {
 }
 
 type brickWall struct {
-	limit float64
+	limit  float64
+	length float64
 }
 
 func BrickWall(t float64) Envelope {
-	return &brickWall{t}
+	return &brickWall{limit: t, length: t}
 }
 
 func (e *brickWall) Amplitude() float64 {
@@ -75,6 +77,7 @@ func sectionRelease(beforeReleaseDur, releaseDur float64, interpol interpolation
 	return &interpolatedEnvelope{
 		amplitude: vary,
 		timeLeft:  beforeReleaseDur + releaseDur,
+		length:    beforeReleaseDur + releaseDur,
 		finite:    true,
 	}
 }
@@ -94,6 +97,10 @@ func CosADSR(totalDuration float64, spec ADSRSpec) Envelope {
 	return adsrWith(spec, totalDuration, interpolation.Cosine)
 }
 
+func ExponentialADSR(totalDuration float64, spec ADSRSpec) Envelope {
+	return adsrWith(spec, totalDuration, interpolation.Exponential)
+}
+
 func adsrWith(spec ADSRSpec, totalDuration float64, interpol interpolation.Function) Envelope {
 	beforeReleaseDur := totalDuration - spec.ReleaseDuration
 	if beforeReleaseDur < 0 {
@@ -116,6 +123,7 @@ type interpolatedEnvelope struct {
 	amplitude varying.Varying
 	finite    bool
 	timeLeft  float64
+	length    float64
 }
 
 func (x *interpolatedEnvelope) Amplitude() float64 {
@@ -136,20 +144,93 @@ func (x *interpolatedEnvelope) Done() bool {
 	return x.finite && x.timeLeft <= 0
 }
 
-type compositeEnvelope []Envelope
+// Breakpoint is a point of a breakpoint envelope: its level at a time, and
+// the curve of the segment from it to the next point (linear if nil).
+type Breakpoint struct {
+	Time  float64
+	Level float64
+	Curve interpolation.Curve
+}
+
+type breakpointEnvelope struct {
+	points []Breakpoint
+	t      float64
+	index  int
+}
+
+// Breakpoints returns an envelope passing through the points, which must be
+// in ascending order of time. Before the first point it has the first level
+// and after the last point the last level. It is done after the last point
+// if the last level is zero. Curves that overshoot are clamped to [0, 1].
+func Breakpoints(points []Breakpoint) Envelope {
+	rv := &breakpointEnvelope{points: points}
+	// Skip any segments of zero length at the start.
+	rv.Advance(0)
+	return rv
+}
+
+func (e *breakpointEnvelope) Amplitude() float64 {
+	n := len(e.points)
+	if n == 0 {
+		return 0.0
+	}
+	if e.t < e.points[0].Time {
+		return e.points[0].Level
+	}
+	if e.index+1 >= n {
+		return e.points[n-1].Level
+	}
+	p0 := e.points[e.index]
+	p1 := e.points[e.index+1]
+	t := (e.t - p0.Time) / (p1.Time - p0.Time)
+	if p0.Curve == nil {
+		return interpolation.Linear(t, p0.Level, p1.Level)
+	}
+	return math.Max(0, math.Min(1, p0.Curve(t, e.segment())))
+}
+
+func (e *breakpointEnvelope) segment() interpolation.Segment {
+	var rv interpolation.Segment
+	for k, j := range interpolation.Neighbours(len(e.points), e.index) {
+		rv.T[k] = e.points[j].Time
+		rv.X[k] = e.points[j].Level
+	}
+	return rv
+}
+
+func (e *breakpointEnvelope) Advance(dt float64) {
+	e.t += dt
+	for e.index+1 < len(e.points) && e.t >= e.points[e.index+1].Time {
+		e.index++
+	}
+}
+
+func (e *breakpointEnvelope) Done() bool {
+	n := len(e.points)
+	if n == 0 {
+		return true
+	}
+	last := e.points[n-1]
+	return last.Level == 0 && e.t >= last.Time
+}
+
+type compositeEnvelope struct {
+	components []Envelope
+	buf        []float64
+}
 
 func Composite(components ...Envelope) Envelope {
-	return compositeEnvelope(components)
+	return &compositeEnvelope{components: components}
 }
 
-func (c compositeEnvelope) Advance(dt float64) {
-	for _, e := range c {
+func (c *compositeEnvelope) Advance(dt float64) {
+	for _, e := range c.components {
 		e.Advance(dt)
 	}
 }
 
-func (c compositeEnvelope) Done() bool {
-	for _, e := range c {
+func (c *compositeEnvelope) Done() bool {
+	for _, e := range c.components {
 		if e.Done() {
 			return true
 		}
@@ -157,9 +238,9 @@ func (c compositeEnvelope) Done() bool {
 	return false
 }
 
-func (c compositeEnvelope) Amplitude() float64 {
+func (c *compositeEnvelope) Amplitude() float64 {
 	value := 1.0
-	for _, e := range c {
+	for _, e := range c.components {
 		value *= e.Amplitude()
 	}
 	return value
@@ -168,6 +249,7 @@ func (c compositeEnvelope) Amplitude() float64 {
 type withVaryings struct {
 	env  Envelope
 	vary []varying.Varying
+	buf  []float64
 }
 
 func WithVarying(env Envelope, components ...varying.Varying) Envelope {
@@ -3,6 +3,8 @@ package envelope
 import (
 	"fmt"
 
+	"github.com/steinarvk/abora/synth/interpolation"
+
 	pb "github.com/steinarvk/abora/proto"
 )
 
@@ -11,11 +13,47 @@ func FromProto(spec *pb.Envelope, duration float64) (Envelope, error) {
 	default:
 		return nil, fmt.Errorf("unhandled kind of envelope: %v", spec)
 	case *pb.Envelope_Adsr:
-		return LinearADSR(duration, ADSRSpec{
+		curve, err := interpolation.FunctionFromProto(opts.Adsr.Curve)
+		if err != nil {
+			return nil, err
+		}
+		return adsrWith(ADSRSpec{
 			AttackDuration:  opts.Adsr.AttackDuration,
 			DecayDuration:   opts.Adsr.DecayDuration,
 			SustainLevel:    opts.Adsr.SustainLevel,
 			ReleaseDuration: opts.Adsr.ReleaseDuration,
-		}), nil
+		}, duration, curve), nil
+	case *pb.Envelope_Breakpoints:
+		points, err := breakpointsFromProto(opts.Breakpoints)
+		if err != nil {
+			return nil, err
+		}
+		return Composite(Breakpoints(points), BrickWall(duration)), nil
+	}
+}
+
+func breakpointsFromProto(spec *pb.BreakpointEnvelope) ([]Breakpoint, error) {
+	if len(spec.Points) == 0 {
+		return nil, fmt.Errorf("breakpoint envelope has no points")
+	}
+
+	var rv []Breakpoint
+	for i, p := range spec.Points {
+		if i > 0 && p.T < spec.Points[i-1].T {
+			return nil, fmt.Errorf("breakpoint %d: time %v before previous time %v", i, p.T, spec.Points[i-1].T)
+		}
+		if p.Level < 0 || p.Level > 1 {
+			return nil, fmt.Errorf("breakpoint %d: level %v out of range [0, 1]", i, p.Level)
+		}
+		curve, err := interpolation.CurveFromProto(p.Curve)
+		if err != nil {
+			return nil, fmt.Errorf("breakpoint %d: %v", i, err)
+		}
+		rv = append(rv, Breakpoint{
+			Time:  p.T,
+			Level: p.Level,
+			Curve: curve,
+		})
 	}
+	return rv, nil
 }
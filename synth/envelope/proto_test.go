@@ -0,0 +1,69 @@
+package envelope
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/steinarvk/abora/proto"
+)
+
+func amplitudesAt(env Envelope, dt float64, times ...float64) []float64 {
+	var rv []float64
+	t := 0.0
+	for _, want := range times {
+		for t+dt/2 < want {
+			env.Advance(dt)
+			t += dt
+		}
+		rv = append(rv, env.Amplitude())
+	}
+	return rv
+}
+
+func TestBreakpointsFromProto(t *testing.T) {
+	spec := &pb.Envelope{}
+	text := `breakpoints: <
+		points: <t: 0 level: 0>
+		points: <t: 0.1 level: 1 curve: EXPONENTIAL>
+		points: <t: 0.2 level: 0.5 curve: HOLD>
+		points: <t: 0.3 level: 0>
+	>`
+	if err := proto.UnmarshalText(text, spec); err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := FromProto(spec, 1.0)
+	if err != nil {
+		t.Fatalf("FromProto() = %v", err)
+	}
+
+	dt := 0.001
+	got := amplitudesAt(env, dt, 0.05, 0.1, 0.15, 0.2, 0.25)
+	want := []float64{0.5, 1, 0.538, 0.5, 0.5}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 0.01 {
+			t.Errorf("amplitude #%d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	for i := 0; i < 100 && !env.Done(); i++ {
+		env.Advance(dt)
+	}
+	if !env.Done() {
+		t.Errorf("envelope not done after the last breakpoint")
+	}
+}
+
+func TestBreakpointsFromProtoRejectsDescendingTimes(t *testing.T) {
+	spec := &pb.Envelope{EnvelopeKind: &pb.Envelope_Breakpoints{Breakpoints: &pb.BreakpointEnvelope{
+		Points: []*pb.Breakpoint{
+			{T: 0.5, Level: 1},
+			{T: 0.1, Level: 0},
+		},
+	}}}
+	if _, err := FromProto(spec, 1.0); err == nil {
+		t.Errorf("FromProto() succeeded, want error")
+	}
+}
@@ -0,0 +1,65 @@
+package envelope
+
+import (
+	"github.com/steinarvk/abora/synth/varying"
+)
+
+// Seeker is an Envelope that can move directly to any time, rather than
+// replaying Advance from the start.
+type Seeker interface {
+	Envelope
+
+	// Seek moves to t seconds after the start. It returns false if this
+	// is not possible (because some component cannot seek), in which case
+	// the envelope is left in an unspecified state and should be
+	// discarded.
+	Seek(t float64) bool
+}
+
+// Seek moves env to t seconds after its start, reporting whether it could.
+func Seek(env Envelope, t float64) bool {
+	s, ok := env.(Seeker)
+	return ok && s.Seek(t)
+}
+
+func (e *brickWall) Seek(t float64) bool {
+	e.limit = e.length - t
+	return true
+}
+
+func (_ Constant) Seek(_ float64) bool { return true }
+
+func (x *interpolatedEnvelope) Seek(t float64) bool {
+	if x.finite {
+		x.timeLeft = x.length - t
+	}
+	return varying.Seek(x.amplitude, t)
+}
+
+func (e *breakpointEnvelope) Seek(t float64) bool {
+	e.t = 0
+	e.index = 0
+	e.Advance(t)
+	return true
+}
+
+func (c *compositeEnvelope) Seek(t float64) bool {
+	for _, e := range c.components {
+		if !Seek(e, t) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *withVaryings) Seek(t float64) bool {
+	if !Seek(e.env, t) {
+		return false
+	}
+	for _, v := range e.vary {
+		if !varying.Seek(v, t) {
+			return false
+		}
+	}
+	return true
+}
@@ -31,6 +31,9 @@ func SimpleSeq(n int, power float64) []Harmonic {
 type withHarmonics struct {
 	osc  []oscillator.Oscillator
 	harm []Harmonic
+
+	// Buffers for Process.
+	duBuf, valueBuf []float64
 }
 
 func (h *withHarmonics) Clone() oscillator.Oscillator {
@@ -61,6 +64,48 @@ func (h *withHarmonics) Advance(dt float64) {
 	}
 }
 
+// grow returns a slice of length n, reusing buf if it is large enough.
+func grow(buf []float64, n int) []float64 {
+	if cap(buf) < n {
+		return make([]float64, n)
+	}
+	return buf[:n]
+}
+
+func (h *withHarmonics) Process(out, du []float64) {
+	n := len(out)
+	h.duBuf = grow(h.duBuf, n)
+	h.valueBuf = grow(h.valueBuf, n)
+
+	for i := range out {
+		out[i] = 0
+	}
+
+	for k, harm := range h.harm {
+		freqMul, constFreq := harm.FreqMul.(varying.Constant)
+		ampMul, constAmp := harm.AmpMul.(varying.Constant)
+
+		if !constFreq || !constAmp {
+			// The multipliers advance with the phase, not with time.
+			for i, x := range du {
+				h.osc[k].Advance(harm.FreqMul.Value() * x)
+				harm.AmpMul.Advance(x)
+				harm.FreqMul.Advance(x)
+				out[i] += h.osc[k].Value() * harm.AmpMul.Value()
+			}
+			continue
+		}
+
+		for i, x := range du {
+			h.duBuf[i] = float64(freqMul) * x
+		}
+		oscillator.Process(h.osc[k], h.valueBuf, h.duBuf)
+		for i, x := range h.valueBuf {
+			out[i] += x * float64(ampMul)
+		}
+	}
+}
+
 func WithHarmonics(osc oscillator.Oscillator, harm []Harmonic) oscillator.Oscillator {
 	rv := &withHarmonics{
 		harm: harm,
@@ -70,3 +115,18 @@ func WithHarmonics(osc oscillator.Oscillator, harm []Harmonic) oscillator.Oscill
 	}
 	return rv
 }
+
+// Seek requires each frequency multiplier to be a varying.Integrator. Like
+// Advance, the multipliers move with the phase, not with time.
+func (h *withHarmonics) Seek(u float64) bool {
+	for k, harm := range h.harm {
+		phase, ok := varying.Integral(harm.FreqMul, u)
+		if !ok {
+			return false
+		}
+		if !oscillator.Seek(h.osc[k], phase) || !varying.Seek(harm.FreqMul, u) || !varying.Seek(harm.AmpMul, u) {
+			return false
+		}
+	}
+	return true
+}
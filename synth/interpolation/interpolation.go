@@ -14,3 +14,94 @@ func Cosine(t, x0, x1 float64) float64 {
 	v := 1.0 - math.Cos(t*math.Pi*0.5)
 	return Linear(v, x0, x1)
 }
+
+// exponentialRate is the rate of Exponential: the fraction of the way
+// remaining shrinks by a factor of e for every 1/exponentialRate of the
+// segment.
+const exponentialRate = 5.0
+
+// Exponential moves quickly at first and then settles towards x1, like
+// the charging or discharging of a capacitor. It is scaled to reach x1
+// exactly at t = 1.
+func Exponential(t, x0, x1 float64) float64 {
+	v := (1.0 - math.Exp(-exponentialRate*t)) / (1.0 - math.Exp(-exponentialRate))
+	return Linear(v, x0, x1)
+}
+
+// Hold stays at x0 until the end of the segment.
+func Hold(t, x0, x1 float64) float64 {
+	if t >= 1 {
+		return x1
+	}
+	return x0
+}
+
+// Geometric interpolates the logarithm of the value, so that it changes by
+// a constant ratio per unit of t; for frequencies, that is a glide at a
+// constant number of cents per second. It is linear unless both values
+// are positive.
+func Geometric(t, x0, x1 float64) float64 {
+	if x0 <= 0 || x1 <= 0 {
+		return Linear(t, x0, x1)
+	}
+	return x0 * math.Pow(x1/x0, t)
+}
+
+// Segment is the part of a curve through a sequence of points between
+// (T[1], X[1]) and (T[2], X[2]), along with the neighbouring points
+// (T[0], X[0]) and (T[3], X[3]). At the ends of the sequence, the end
+// point stands in for the missing neighbour.
+type Segment struct {
+	T [4]float64
+	X [4]float64
+}
+
+// Neighbours returns the indices of the points making up the segment from
+// point i to point i+1 of a sequence of n points.
+func Neighbours(n, i int) [4]int {
+	var rv [4]int
+	for k := range rv {
+		j := i - 1 + k
+		if j < 0 {
+			j = 0
+		}
+		if j > n-1 {
+			j = n - 1
+		}
+		rv[k] = j
+	}
+	return rv
+}
+
+// Curve is like Function, but may take the neighbouring points into
+// account. It returns the value at fraction t of the way through the
+// segment.
+type Curve func(float64, Segment) float64
+
+// Local returns a Curve that interpolates between the end points of each
+// segment with f.
+func Local(f Function) Curve {
+	return func(t float64, s Segment) float64 {
+		return f(t, s.X[1], s.X[2])
+	}
+}
+
+// CatmullRom is a cubic Hermite spline with Catmull-Rom tangents: the
+// slope at each point is that of the line between its neighbours. The
+// result is smooth across points, but may overshoot them.
+func CatmullRom(t float64, s Segment) float64 {
+	dt := s.T[2] - s.T[1]
+	m1 := slope(s, 0, 2) * dt
+	m2 := slope(s, 1, 3) * dt
+
+	t2 := t * t
+	t3 := t2 * t
+	return (2*t3-3*t2+1)*s.X[1] + (t3-2*t2+t)*m1 + (-2*t3+3*t2)*s.X[2] + (t3-t2)*m2
+}
+
+func slope(s Segment, i, j int) float64 {
+	if s.T[j] == s.T[i] {
+		return 0
+	}
+	return (s.X[j] - s.X[i]) / (s.T[j] - s.T[i])
+}
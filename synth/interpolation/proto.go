@@ -0,0 +1,41 @@
+package interpolation
+
+import (
+	"fmt"
+
+	pb "github.com/steinarvk/abora/proto"
+)
+
+// FunctionFromProto returns the function for a curve that depends only on
+// the end points of each segment.
+func FunctionFromProto(curve pb.Curve) (Function, error) {
+	switch curve {
+	case pb.Curve_LINEAR:
+		return Linear, nil
+	case pb.Curve_COSINE:
+		return Cosine, nil
+	case pb.Curve_EXPONENTIAL:
+		return Exponential, nil
+	case pb.Curve_HOLD:
+		return Hold, nil
+	case pb.Curve_LOG_FREQUENCY:
+		return Geometric, nil
+	case pb.Curve_CATMULL_ROM:
+		return nil, fmt.Errorf("curve %v depends on neighbouring points", curve)
+	default:
+		return nil, fmt.Errorf("unhandled curve: %v", curve)
+	}
+}
+
+// CurveFromProto returns the curve for a segment, which may also take the
+// neighbouring points into account.
+func CurveFromProto(curve pb.Curve) (Curve, error) {
+	if curve == pb.Curve_CATMULL_ROM {
+		return CatmullRom, nil
+	}
+	f, err := FunctionFromProto(curve)
+	if err != nil {
+		return nil, err
+	}
+	return Local(f), nil
+}
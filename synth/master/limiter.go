@@ -0,0 +1,81 @@
+package master
+
+import (
+	"math"
+)
+
+// slidingMin returns, for each index i, the smallest of xs[i:i+width+1],
+// treating values beyond the end as 1.
+func slidingMin(xs []float64, width int) []float64 {
+	rv := make([]float64, len(xs))
+	var deque []int
+
+	for j := len(xs) - 1; j >= 0; j-- {
+		for len(deque) > 0 && xs[deque[len(deque)-1]] >= xs[j] {
+			deque = deque[:len(deque)-1]
+		}
+		deque = append(deque, j)
+		for deque[0] > j+width {
+			deque = deque[1:]
+		}
+		rv[j] = xs[deque[0]]
+	}
+
+	return rv
+}
+
+// limit reduces the gain wherever the true peak would exceed the ceiling.
+//
+// The required gain reduction is known ahead of time, so the gain starts
+// ramping down a look-ahead period before each peak and reaches the
+// required level exactly in time; afterwards it recovers exponentially
+// with the given release time.
+func limit(xs []float64, sampleRate int, ceiling, lookAhead, release float64) {
+	peaks := oversampledPeaks(xs, sampleRate)
+
+	required := make([]float64, len(xs))
+	for i, peak := range peaks {
+		required[i] = 1
+		if peak > ceiling {
+			required[i] = ceiling / peak
+		}
+	}
+
+	window := int(lookAhead * float64(sampleRate))
+	if window < 1 {
+		window = 1
+	}
+
+	// Any average of minima over windows ending after index i stays below
+	// required[i], so the smoothed ramp never lets a peak through.
+	minima := slidingMin(required, window)
+
+	// The virtual samples before the beginning are padded with the first
+	// minimum, which preserves that guarantee for early peaks.
+	n := window + 1
+	smoothed := make([]float64, len(xs))
+	sum := float64(n) * minima[0]
+	for i, m := range minima {
+		sum += m
+		if i-n >= 0 {
+			sum -= minima[i-n]
+		} else {
+			sum -= minima[0]
+		}
+		smoothed[i] = sum / float64(n)
+	}
+
+	recovery := 1.0
+	if release > 0 {
+		recovery = 1 - math.Exp(-1/(release*float64(sampleRate)))
+	}
+
+	gain := 1.0
+	for i := range xs {
+		gain += (1 - gain) * recovery
+		if smoothed[i] < gain {
+			gain = smoothed[i]
+		}
+		xs[i] *= gain
+	}
+}
@@ -0,0 +1,117 @@
+package master
+
+import (
+	"math"
+
+	"github.com/steinarvk/abora/filter"
+)
+
+const (
+	loudnessBlockSeconds = 0.4
+	loudnessBlockOverlap = 0.75
+	absoluteGateLUFS     = -70.0
+	relativeGateLU       = -10.0
+)
+
+// kWeighting returns the two filter stages of the ITU-R BS.1770 K-weighting
+// curve, derived for the given sample rate.
+func kWeighting(sampleRate int) (*filter.Biquad, *filter.Biquad) {
+	rate := float64(sampleRate)
+
+	// High-frequency shelf, modelling the acoustic effect of the head.
+	f0 := 1681.974450955533
+	gain := 3.999843853973347
+	q := 0.7071752369554196
+
+	k := math.Tan(math.Pi * f0 / rate)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+
+	shelf := &filter.Biquad{
+		B0: (vh + vb*k/q + k*k) / a0,
+		B1: 2 * (k*k - vh) / a0,
+		B2: (vh - vb*k/q + k*k) / a0,
+		A1: 2 * (k*k - 1) / a0,
+		A2: (1 - k/q + k*k) / a0,
+	}
+
+	// High-pass (the "RLB" weighting).
+	f0 = 38.13547087602444
+	q = 0.5003270373238773
+
+	k = math.Tan(math.Pi * f0 / rate)
+	a0 = 1 + k/q + k*k
+
+	highpass := &filter.Biquad{
+		B0: 1,
+		B1: -2,
+		B2: 1,
+		A1: 2 * (k*k - 1) / a0,
+		A2: (1 - k/q + k*k) / a0,
+	}
+
+	return shelf, highpass
+}
+
+func meanSquareToLUFS(z float64) float64 {
+	return -0.691 + 10*math.Log10(z)
+}
+
+// Loudness measures the integrated loudness of a mono signal in LUFS, as
+// specified by ITU-R BS.1770-4 and EBU R128. It returns -Inf for silence
+// or signals too short to contain a single gating block.
+func Loudness(xs []float64, sampleRate int) float64 {
+	shelf, highpass := kWeighting(sampleRate)
+
+	squared := make([]float64, len(xs))
+	for i, x := range xs {
+		y := highpass.Filter(shelf.Filter(x))
+		squared[i] = y * y
+	}
+
+	blockSize := int(loudnessBlockSeconds * float64(sampleRate))
+	hop := int(float64(blockSize) * (1 - loudnessBlockOverlap))
+	if blockSize < 1 || hop < 1 {
+		return math.Inf(-1)
+	}
+
+	var blocks []float64
+	sum := 0.0
+	for i, y := range squared {
+		sum += y
+		if i >= blockSize {
+			sum -= squared[i-blockSize]
+		}
+		if i+1 >= blockSize && (i+1-blockSize)%hop == 0 {
+			blocks = append(blocks, sum/float64(blockSize))
+		}
+	}
+
+	gatedMean := func(threshold float64) (float64, int) {
+		total := 0.0
+		n := 0
+		for _, z := range blocks {
+			if meanSquareToLUFS(z) > threshold {
+				total += z
+				n++
+			}
+		}
+		if n == 0 {
+			return 0, 0
+		}
+		return total / float64(n), n
+	}
+
+	z, n := gatedMean(absoluteGateLUFS)
+	if n == 0 {
+		return math.Inf(-1)
+	}
+
+	z, n = gatedMean(meanSquareToLUFS(z) + relativeGateLU)
+	if n == 0 {
+		return math.Inf(-1)
+	}
+
+	return meanSquareToLUFS(z)
+}
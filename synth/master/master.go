@@ -0,0 +1,235 @@
+// Package master implements a mastering stage for rendered audio:
+// normalization to a peak or loudness target, and a look-ahead true-peak
+// limiter.
+//
+// Normalization needs to see the whole signal before emitting anything, so
+// this stage works on complete buffers rather than streams.
+package master
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/steinarvk/abora/resample"
+)
+
+const (
+	truePeakOversampling = 4
+
+	// DefaultPeakLevel is the default target for peak normalization, in dBFS.
+	DefaultPeakLevel = -1.0
+
+	// DefaultLoudness is the default target for loudness normalization, in
+	// LUFS.
+	DefaultLoudness = -16.0
+)
+
+// DBToGain converts decibels to a linear gain factor.
+func DBToGain(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// GainToDB converts a linear gain factor to decibels.
+func GainToDB(gain float64) float64 {
+	return 20 * math.Log10(gain)
+}
+
+// Peak returns the largest absolute sample value.
+func Peak(xs []float64) float64 {
+	rv := 0.0
+	for _, x := range xs {
+		if math.Abs(x) > rv {
+			rv = math.Abs(x)
+		}
+	}
+	return rv
+}
+
+// oversampledPeaks estimates the true (inter-sample) peak level around
+// each sample by oversampling.
+func oversampledPeaks(xs []float64, sampleRate int) []float64 {
+	up := resample.Slice(xs, sampleRate, truePeakOversampling*sampleRate)
+	rv := make([]float64, len(xs))
+	for i := range rv {
+		peak := math.Abs(xs[i])
+		for k := 0; k < truePeakOversampling; k++ {
+			j := i*truePeakOversampling + k
+			if j < len(up) && math.Abs(up[j]) > peak {
+				peak = math.Abs(up[j])
+			}
+		}
+		rv[i] = peak
+	}
+	return rv
+}
+
+// TruePeak estimates the largest absolute value of the reconstructed
+// signal, including peaks between samples.
+func TruePeak(xs []float64, sampleRate int) float64 {
+	return Peak(oversampledPeaks(xs, sampleRate))
+}
+
+func scale(xs []float64, gain float64) {
+	for i := range xs {
+		xs[i] *= gain
+	}
+}
+
+type option interface {
+	Apply(*settings)
+}
+
+type normalization int
+
+const (
+	noNormalization normalization = iota
+	peakNormalization
+	loudnessNormalization
+)
+
+type settings struct {
+	normalization normalization
+	targetLevel   float64
+
+	limit       bool
+	ceilingDB   float64
+	lookAhead   float64
+	releaseTime float64
+}
+
+// NormalizePeak scales the signal so that its largest sample is at the
+// given level in dBFS.
+type NormalizePeak float64
+
+func (n NormalizePeak) Apply(s *settings) {
+	s.normalization = peakNormalization
+	s.targetLevel = float64(n)
+}
+
+// NormalizeLoudness scales the signal so that its integrated loudness is
+// at the given level in LUFS.
+type NormalizeLoudness float64
+
+func (n NormalizeLoudness) Apply(s *settings) {
+	s.normalization = loudnessNormalization
+	s.targetLevel = float64(n)
+}
+
+// Limit applies the true-peak limiter after normalization, with the given
+// ceiling in dBTP.
+type Limit float64
+
+func (l Limit) Apply(s *settings) {
+	s.limit = true
+	s.ceilingDB = float64(l)
+}
+
+// LookAhead sets the limiter's look-ahead (and attack) time in seconds.
+type LookAhead float64
+
+func (l LookAhead) Apply(s *settings) { s.lookAhead = float64(l) }
+
+// Release sets the limiter's release time constant in seconds.
+type Release float64
+
+func (r Release) Apply(s *settings) { s.releaseTime = float64(r) }
+
+// Process applies the mastering stage to a buffer of mono samples,
+// modifying it in place.
+func Process(xs []float64, sampleRate int, opts ...option) error {
+	s := &settings{
+		lookAhead:   0.005,
+		releaseTime: 0.05,
+	}
+	for _, opt := range opts {
+		opt.Apply(s)
+	}
+
+	if s.lookAhead < 0 || s.releaseTime < 0 {
+		return errors.New("limiter times must be non-negative")
+	}
+
+	switch s.normalization {
+	case peakNormalization:
+		peak := Peak(xs)
+		if peak == 0 {
+			return errors.New("cannot peak-normalize silence")
+		}
+		gain := DBToGain(s.targetLevel) / peak
+		log.Printf("peak normalization: peak %.2f dBFS, gain %+.2f dB", GainToDB(peak), GainToDB(gain))
+		scale(xs, gain)
+
+	case loudnessNormalization:
+		loudness := Loudness(xs, sampleRate)
+		if math.IsInf(loudness, -1) {
+			return errors.New("cannot loudness-normalize silence")
+		}
+		gain := DBToGain(s.targetLevel - loudness)
+		log.Printf("loudness normalization: %.2f LUFS, gain %+.2f dB", loudness, GainToDB(gain))
+		scale(xs, gain)
+	}
+
+	if s.limit {
+		limit(xs, sampleRate, DBToGain(s.ceilingDB), s.lookAhead, s.releaseTime)
+	}
+
+	return nil
+}
+
+// Channel collects a stream of samples, applies the mastering stage to
+// them, and replays the result.
+func Channel(ch <-chan float64, sampleRate int, opts ...option) (<-chan float64, error) {
+	var xs []float64
+	for x := range ch {
+		xs = append(xs, x)
+	}
+
+	if err := Process(xs, sampleRate, opts...); err != nil {
+		return nil, err
+	}
+
+	out := make(chan float64, sampleRate)
+	go func() {
+		for _, x := range xs {
+			out <- x
+		}
+		close(out)
+	}()
+	return out, nil
+}
+
+// Options returns the mastering options selected by e.g. command-line
+// flags. The normalization mode is "", "peak" or "loudness"; a NaN target
+// level selects the default for the mode, and a zero ceiling disables the
+// limiter.
+func Options(mode string, targetLevel float64, limitCeiling float64) ([]option, error) {
+	var rv []option
+
+	if math.IsNaN(targetLevel) {
+		targetLevel = DefaultPeakLevel
+		if mode == "loudness" {
+			targetLevel = DefaultLoudness
+		}
+	}
+
+	switch mode {
+	case "":
+	case "peak":
+		rv = append(rv, NormalizePeak(targetLevel))
+	case "loudness":
+		rv = append(rv, NormalizeLoudness(targetLevel))
+	default:
+		return nil, fmt.Errorf("unknown normalization mode %q (want \"peak\" or \"loudness\")", mode)
+	}
+
+	if limitCeiling > 0 {
+		return nil, fmt.Errorf("limiter ceiling must be negative (got %v dBTP)", limitCeiling)
+	}
+	if limitCeiling < 0 {
+		rv = append(rv, Limit(limitCeiling))
+	}
+
+	return rv, nil
+}
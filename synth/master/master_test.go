@@ -0,0 +1,74 @@
+package master
+
+import (
+	"math"
+	"testing"
+)
+
+func sine(freq, amplitude float64, sampleRate int, seconds float64) []float64 {
+	rv := make([]float64, int(seconds*float64(sampleRate)))
+	for i := range rv {
+		rv[i] = amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate))
+	}
+	return rv
+}
+
+func TestLoudnessOfReferenceTone(t *testing.T) {
+	// A full-scale 997 Hz sine reads as -3.01 LUFS at any sample rate.
+	for _, rate := range []int{44100, 48000} {
+		got := Loudness(sine(997, 1, rate, 5), rate)
+		if math.Abs(got-(-3.01)) > 0.05 {
+			t.Errorf("Loudness() at %d Hz = %v LUFS, want -3.01", rate, got)
+		}
+	}
+
+	if got := Loudness(make([]float64, 48000), 48000); !math.IsInf(got, -1) {
+		t.Errorf("Loudness() of silence = %v, want -Inf", got)
+	}
+}
+
+func TestNormalizeLoudness(t *testing.T) {
+	xs := sine(440, 0.1, 44100, 3)
+	if err := Process(xs, 44100, NormalizeLoudness(-16)); err != nil {
+		t.Fatal(err)
+	}
+	if got := Loudness(xs, 44100); math.Abs(got-(-16)) > 0.01 {
+		t.Errorf("Loudness() after normalization = %v, want -16", got)
+	}
+}
+
+func TestNormalizePeak(t *testing.T) {
+	xs := sine(440, 3, 44100, 1)
+	if err := Process(xs, 44100, NormalizePeak(-6)); err != nil {
+		t.Fatal(err)
+	}
+	if got := GainToDB(Peak(xs)); math.Abs(got-(-6)) > 1e-9 {
+		t.Errorf("Peak() after normalization = %v dBFS, want -6", got)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	const rate = 44100
+
+	// A quiet tone with a loud burst in the middle.
+	xs := sine(1000, 0.25, rate, 2)
+	for i := rate / 2; i < rate; i++ {
+		xs[i] *= 6
+	}
+
+	if err := Process(xs, rate, Limit(-1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := GainToDB(TruePeak(xs, rate)); got > -0.9 {
+		t.Errorf("TruePeak() after limiting = %v dBTP, want <= -1", got)
+	}
+
+	// Well away from the burst, the signal should be untouched.
+	if got := Peak(xs[rate+rate/2:]); math.Abs(got-0.25) > 0.001 {
+		t.Errorf("Peak() after release = %v, want 0.25", got)
+	}
+	if got := Peak(xs[:rate/4]); math.Abs(got-0.25) > 0.001 {
+		t.Errorf("Peak() before burst = %v, want 0.25", got)
+	}
+}
@@ -1,51 +1,190 @@
 package mix
 
 import (
+	"math"
+
 	"github.com/steinarvk/abora/synth/chirp"
 
 	"github.com/bradfitz/slice"
 )
 
-func AsChannel(chirps []chirp.TimedChirp, sampleRate int, timeLimit float64) <-chan float64 {
+const (
+	// blockSize is the number of samples AsChannel renders at a time.
+	blockSize = 4096
+)
+
+// Mixer renders the sum of a set of chirps into buffers, sample by sample
+// identical to rendering the chirps one sample at a time. The mix ends when
+// the last chirp is done, or at the time limit (if positive).
+type Mixer struct {
+	sampleRate int
+	timeLimit  float64
+
+	pending []chirp.TimedChirp
+	active  []chirp.Chirp
+	frame   int
+	ended   bool
+
+	buf []float64
+}
+
+// NewMixer returns a mixer for the chirps. It sorts the chirps by time.
+func NewMixer(chirps []chirp.TimedChirp, sampleRate int, timeLimit float64) *Mixer {
 	pending := chirps
 	slice.Sort(pending, func(i, j int) bool {
 		return chirps[i].Time < chirps[j].Time
 	})
 
-	bufsz := sampleRate
-	ch := make(chan float64, bufsz)
+	return &Mixer{
+		sampleRate: sampleRate,
+		timeLimit:  timeLimit,
+		pending:    pending,
+	}
+}
 
-	go func() {
-		var active []chirp.Chirp
-		frame := 0
-		step := 1.0 / float64(sampleRate)
+func (m *Mixer) frameTime(frame int) float64 {
+	return float64(frame) / float64(m.sampleRate)
+}
 
-		for len(active) > 0 || len(pending) > 0 {
-			t := float64(frame) / float64(sampleRate)
-			if timeLimit > 0 && t > timeLimit {
-				break
-			}
+// startFrame returns the first frame at or after from at which a chirp
+// beginning at t is playing.
+func (m *Mixer) startFrame(t float64, from int) int {
+	frame := int(math.Ceil(t * float64(m.sampleRate)))
+	for frame > from && m.frameTime(frame-1) >= t {
+		frame--
+	}
+	for m.frameTime(frame) < t {
+		frame++
+	}
+	if frame < from {
+		frame = from
+	}
+	return frame
+}
 
-			for len(pending) > 0 && pending[0].Time <= t {
-				active = append(active, pending[0].Chirp)
-				pending = pending[1:]
-			}
+// lastFrame returns the last frame within the time limit.
+func (m *Mixer) lastFrame() int {
+	frame := int(m.timeLimit * float64(m.sampleRate))
+	for m.frameTime(frame+1) <= m.timeLimit {
+		frame++
+	}
+	for frame >= 0 && m.frameTime(frame) > m.timeLimit {
+		frame--
+	}
+	return frame
+}
+
+// Read mixes the next samples into out. It returns the number of samples
+// written, which is less than len(out) only at the end of the mix, and 0
+// once the mix has ended.
+func (m *Mixer) Read(out []float64) int {
+	if m.ended {
+		return 0
+	}
+
+	n := len(out)
+	if m.timeLimit > 0 {
+		if remaining := m.lastFrame() + 1 - m.frame; remaining < n {
+			n = remaining
+		}
+		if n <= 0 {
+			m.ended = true
+			return 0
+		}
+	}
+
+	for i := range out[:n] {
+		out[i] = 0.0
+	}
+
+	step := 1.0 / float64(m.sampleRate)
+	m.buf = grow(m.buf, n)
+
+	// Render the active chirps up to the frame at which the next chirp
+	// starts, then start it, and so on.
+	pos := 0
+	for pos < n {
+		if len(m.active) == 0 && len(m.pending) == 0 {
+			m.ended = true
+			break
+		}
 
-			rv := 0.0
+		next := n
+		if len(m.pending) > 0 {
+			if start := m.startFrame(m.pending[0].Time, m.frame+pos) - m.frame; start < next {
+				next = start
+			}
+		}
 
-			var newActive []chirp.Chirp
-			for _, chirp := range active {
-				chirp.Advance(step)
-				rv += chirp.Sample()
-				if !chirp.Done() {
-					newActive = append(newActive, chirp)
+		end := pos
+		var stillActive []chirp.Chirp
+		for _, c := range m.active {
+			buf := m.buf[:next-pos]
+			done := chirp.Process(c, buf, step)
+			for i, x := range buf[:done] {
+				out[pos+i] += x
+			}
+			// A chirp can also end on the last sample of the buffer.
+			if done < len(buf) || c.Done() {
+				if pos+done > end {
+					end = pos + done
 				}
+			} else {
+				stillActive = append(stillActive, c)
 			}
-			active = newActive
+		}
+		m.active = stillActive
+
+		if len(m.active) == 0 && len(m.pending) == 0 {
+			// All chirps ended within this stretch.
+			pos = end
+			m.ended = true
+			break
+		}
+		pos = next
+
+		t := m.frameTime(m.frame + pos)
+		for len(m.pending) > 0 && m.pending[0].Time <= t {
+			m.active = append(m.active, m.pending[0].Chirp)
+			m.pending = m.pending[1:]
+		}
+	}
 
-			ch <- rv
+	m.frame += pos
+	return pos
+}
+
+// Render mixes the chirps and returns all the samples.
+func Render(chirps []chirp.TimedChirp, sampleRate int, timeLimit float64) []float64 {
+	m := NewMixer(chirps, sampleRate, timeLimit)
 
-			frame++
+	var rv []float64
+	buf := make([]float64, blockSize)
+	for {
+		n := m.Read(buf)
+		if n == 0 {
+			return rv
+		}
+		rv = append(rv, buf[:n]...)
+	}
+}
+
+// AsChannel mixes the chirps, sending the samples over a channel.
+func AsChannel(chirps []chirp.TimedChirp, sampleRate int, timeLimit float64) <-chan float64 {
+	m := NewMixer(chirps, sampleRate, timeLimit)
+
+	ch := make(chan float64, sampleRate)
+
+	go func() {
+		buf := make([]float64, blockSize)
+		for {
+			n := m.Read(buf)
+			if n == 0 {
+				break
+			}
+			for _, x := range buf[:n] {
+				ch <- x
+			}
 		}
 
 		close(ch)
@@ -53,3 +192,11 @@ func AsChannel(chirps []chirp.TimedChirp, sampleRate int, timeLimit float64) <-c
 
 	return ch
 }
+
+// grow returns a slice of length n, reusing buf if it is large enough.
+func grow(buf []float64, n int) []float64 {
+	if cap(buf) < n {
+		return make([]float64, n)
+	}
+	return buf[:n]
+}
@@ -0,0 +1,195 @@
+package mix
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/steinarvk/abora/synth/chirp"
+	"github.com/steinarvk/abora/synth/envelope"
+	"github.com/steinarvk/abora/synth/harmonics"
+	"github.com/steinarvk/abora/synth/oscillator"
+	"github.com/steinarvk/abora/synth/varying"
+
+	aborapb "github.com/steinarvk/abora/proto"
+
+	"github.com/bradfitz/slice"
+)
+
+const sampleRate = 44100
+
+// perSample is the reference mix, advancing every chirp one sample at a
+// time.
+func perSample(chirps []chirp.TimedChirp, sampleRate int, timeLimit float64) []float64 {
+	pending := chirps
+	slice.Sort(pending, func(i, j int) bool {
+		return chirps[i].Time < chirps[j].Time
+	})
+
+	var rv []float64
+	var active []chirp.Chirp
+	step := 1.0 / float64(sampleRate)
+
+	for frame := 0; len(active) > 0 || len(pending) > 0; frame++ {
+		t := float64(frame) / float64(sampleRate)
+		if timeLimit > 0 && t > timeLimit {
+			break
+		}
+
+		for len(pending) > 0 && pending[0].Time <= t {
+			active = append(active, pending[0].Chirp)
+			pending = pending[1:]
+		}
+
+		x := 0.0
+		var newActive []chirp.Chirp
+		for _, c := range active {
+			c.Advance(step)
+			x += c.Sample()
+			if !c.Done() {
+				newActive = append(newActive, c)
+			}
+		}
+		active = newActive
+
+		rv = append(rv, x)
+	}
+
+	return rv
+}
+
+func sineScore(tb testing.TB) []chirp.TimedChirp {
+	value := func(x float64) *aborapb.DoubleOrHold {
+		return &aborapb.DoubleOrHold{ValueOrHold: &aborapb.DoubleOrHold_Value{Value: x}}
+	}
+	point := func(t, freq float64) *aborapb.Point {
+		return &aborapb.Point{T: t, Settings: &aborapb.PointSettings{Freq: value(freq)}}
+	}
+
+	score := &aborapb.Chirps{
+		Defaults: &aborapb.Context{
+			Oscillator: &aborapb.Oscillator{Oscillators: &aborapb.Oscillator_Sine{}},
+			Initial:    &aborapb.PointSettings{Amplitude: value(0.3)},
+		},
+		Chirp: []*aborapb.Chirp{
+			{BeginTime: 0.0, Duration: 0.5, Points: []*aborapb.Point{point(0, 440), point(0.5, 880)}},
+			{BeginTime: 0.25, Duration: 0.5, Points: []*aborapb.Point{point(0, 660)}},
+			{BeginTime: 0.30001, Duration: 0.01, Points: []*aborapb.Point{point(0, 1000)}},
+			{BeginTime: 1.2, Duration: 0.3, Points: []*aborapb.Point{point(0, 330), point(0.1, 340), point(0.3, 320)}},
+		},
+	}
+
+	chirps, err := chirp.ScoreFromProto(score)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return chirps
+}
+
+func fileScore(tb testing.TB, filename string) []chirp.TimedChirp {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	spec := &aborapb.Chirps{}
+	if err := proto.UnmarshalText(string(data), spec); err != nil {
+		tb.Fatal(err)
+	}
+
+	chirps, err := chirp.ScoreFromProto(spec)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return chirps
+}
+
+// vibratoScore is a single chirp with a spectrum oscillator and vibrato.
+func vibratoScore(tb testing.TB) []chirp.TimedChirp {
+	data, err := ioutil.ReadFile("../../testdata/chirps/ah.pb_text")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	spec := &aborapb.Chirp{}
+	if err := proto.UnmarshalText(string(data), spec); err != nil {
+		tb.Fatal(err)
+	}
+
+	c, err := chirp.FromProto(spec, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return []chirp.TimedChirp{*c}
+}
+
+func harmonicScore(tb testing.TB) []chirp.TimedChirp {
+	var rv []chirp.TimedChirp
+	for i := 0; i < 8; i++ {
+		osc := harmonics.WithHarmonics(oscillator.Sin(), harmonics.SimpleSeq(10, 1.5))
+		freq := varying.NewInterpolated([]varying.Point{
+			{Time: 0, Value: 200 + 50*float64(i)},
+			{Time: 1, Value: 300 + 50*float64(i)},
+		})
+		env := envelope.LinearADSR(1.0, envelope.ADSRSpec{AttackDuration: 0.05, DecayDuration: 0.1, SustainLevel: 0.7, ReleaseDuration: 0.2})
+		rv = append(rv, chirp.At(0.5*float64(i), chirp.New(freq, osc, env)))
+	}
+	return rv
+}
+
+func TestMixerIsIdentical(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		score     func(testing.TB) []chirp.TimedChirp
+		timeLimit float64
+	}{
+		{"sine", sineScore, 0},
+		{"sine with limit", sineScore, 0.4},
+		{"scale", func(tb testing.TB) []chirp.TimedChirp { return fileScore(tb, "../../testdata/chirps/scale.pb_text") }, 0},
+		{"vibrato", vibratoScore, 4},
+		{"harmonics", harmonicScore, 0},
+	} {
+		want := perSample(test.score(t), sampleRate, test.timeLimit)
+
+		for _, blockSize := range []int{1, 100, 4096} {
+			m := NewMixer(test.score(t), sampleRate, test.timeLimit)
+			var got []float64
+			buf := make([]float64, blockSize)
+			for n := m.Read(buf); n > 0; n = m.Read(buf) {
+				got = append(got, buf[:n]...)
+			}
+
+			if len(got) != len(want) {
+				t.Errorf("%s: mixer with block size %d rendered %d samples, want %d", test.name, blockSize, len(got), len(want))
+				continue
+			}
+			for i := range want {
+				if math.Float64bits(got[i]) != math.Float64bits(want[i]) {
+					t.Errorf("%s: mixer with block size %d differs at sample %d: %v != %v", test.name, blockSize, i, got[i], want[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkPerSample(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		perSample(harmonicScore(b), sampleRate, 0)
+	}
+}
+
+func BenchmarkAsChannel(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _ = range AsChannel(harmonicScore(b), sampleRate, 0) {
+		}
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Render(harmonicScore(b), sampleRate, 0)
+	}
+}
@@ -0,0 +1,112 @@
+package oscillator
+
+import (
+	"math"
+)
+
+// The oscillators in this file are band-limited using PolyBLEP/PolyBLAMP
+// corrections: the discontinuities (or corners) of the naive waveforms are
+// smoothed over the neighbouring samples, which suppresses most of the
+// aliasing that the naive waveforms produce at high pitches. The size of
+// the correction depends on the phase increment per sample, which is taken
+// from the most recent call to Advance.
+
+// polyBLEP is the residual of a band-limited step of height 2 at phase 0.
+func polyBLEP(t, dt float64) float64 {
+	switch {
+	case t < dt:
+		t /= dt
+		return t + t - t*t - 1
+	case t > 1-dt:
+		t = (t - 1) / dt
+		return t*t + t + t + 1
+	}
+	return 0
+}
+
+// polyBLAMP is the residual of a band-limited change of slope at phase 0;
+// it is the integral of polyBLEP.
+func polyBLAMP(t, dt float64) float64 {
+	switch {
+	case t < dt:
+		t = t/dt - 1
+		return -t * t * t / 3
+	case t > 1-dt:
+		t = (t-1)/dt + 1
+		return t * t * t / 3
+	}
+	return 0
+}
+
+func wrap(u float64) float64 {
+	return u - math.Floor(u)
+}
+
+// phasor keeps track of the phase (in cycles) and the phase increment.
+type phasor struct {
+	p  float64
+	dt float64
+}
+
+func (s *phasor) Advance(du float64) {
+	s.p = wrap(s.p + du)
+	s.dt = math.Min(math.Abs(du), 0.5)
+}
+
+type sawOsc struct {
+	phasor
+}
+
+// Sawtooth returns a band-limited sawtooth oscillator, rising from -1 to 1
+// over each cycle.
+func Sawtooth() Oscillator { return &sawOsc{} }
+
+func (s *sawOsc) Value() float64 {
+	return 2*s.p - 1 - polyBLEP(s.p, s.dt)
+}
+
+func (s *sawOsc) Clone() Oscillator { return &sawOsc{s.phasor} }
+
+type pulseOsc struct {
+	phasor
+	width float64
+}
+
+// Pulse returns a band-limited pulse oscillator which is high for the
+// given fraction of each cycle.
+func Pulse(width float64) Oscillator {
+	if width <= 0 || width >= 1 {
+		width = 0.5
+	}
+	return &pulseOsc{width: width}
+}
+
+// Square returns a band-limited square wave oscillator.
+func Square() Oscillator { return Pulse(0.5) }
+
+func (s *pulseOsc) Value() float64 {
+	rv := -1.0
+	if s.p < s.width {
+		rv = 1.0
+	}
+	return rv + polyBLEP(s.p, s.dt) - polyBLEP(wrap(s.p-s.width), s.dt)
+}
+
+func (s *pulseOsc) Clone() Oscillator { return &pulseOsc{s.phasor, s.width} }
+
+type triangleOsc struct {
+	phasor
+}
+
+// Triangle returns a band-limited triangle wave oscillator.
+func Triangle() Oscillator { return &triangleOsc{} }
+
+func (s *triangleOsc) Value() float64 {
+	rv := 3 - 4*s.p
+	if s.p < 0.5 {
+		rv = 4*s.p - 1
+	}
+	return rv + 4*s.dt*(polyBLAMP(s.p, s.dt)-polyBLAMP(wrap(s.p+0.5), s.dt))
+}
+
+func (s *triangleOsc) Clone() Oscillator { return &triangleOsc{s.phasor} }
@@ -0,0 +1,61 @@
+package oscillator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBandlimitedWaveforms(t *testing.T) {
+	testcases := []struct {
+		name string
+		osc  Oscillator
+		mean float64
+	}{
+		{"square", Square(), 0.0},
+		{"sawtooth", Sawtooth(), 0.0},
+		{"triangle", Triangle(), 0.0},
+		{"pulse", Pulse(0.25), -0.5},
+	}
+
+	// 441 Hz at 44100 Hz: exactly 100 samples per cycle.
+	du := 441.0 / 44100.0
+
+	for _, tc := range testcases {
+		var sum, min, max float64
+		for i := 0; i < 1000; i++ {
+			tc.osc.Advance(du)
+			x := tc.osc.Value()
+			sum += x
+			min = math.Min(min, x)
+			max = math.Max(max, x)
+		}
+		if mean := sum / 1000; math.Abs(mean-tc.mean) > 0.01 {
+			t.Errorf("%s: mean value %v, want %v", tc.name, mean, tc.mean)
+		}
+		if max < 0.9 || max > 1.1 || min > -0.9 || min < -1.1 {
+			t.Errorf("%s: values in [%v,%v], want roughly [-1,1]", tc.name, min, max)
+		}
+
+		clone := tc.osc.Clone()
+		for i := 0; i < 37; i++ {
+			tc.osc.Advance(du)
+			clone.Advance(du)
+			if tc.osc.Value() != clone.Value() {
+				t.Errorf("%s: clone diverged from original", tc.name)
+				break
+			}
+		}
+	}
+}
+
+func TestSawtoothSmoothsDiscontinuity(t *testing.T) {
+	osc := Sawtooth()
+	for i := 0; i < 4; i++ {
+		osc.Advance(0.25)
+	}
+	// The naive sawtooth would be at -1 right after the reset; the
+	// band-limited one is halfway through its transition.
+	if x := osc.Value(); math.Abs(x) > 1e-9 {
+		t.Errorf("value at discontinuity %v, want 0", x)
+	}
+}
@@ -0,0 +1,94 @@
+package oscillator
+
+import (
+	"math"
+)
+
+// Block is an Oscillator that can render many samples at once, which
+// avoids the overhead of calling Advance and Value for every sample.
+type Block interface {
+	Oscillator
+
+	// Process advances the phase by du[i] and then writes the value to
+	// out[i], for each i in turn.
+	Process(out, du []float64)
+}
+
+// Process advances osc by du[i] and writes its value to out[i], for each i
+// in turn. It uses the Block implementation if osc has one.
+func Process(osc Oscillator, out, du []float64) {
+	if b, ok := osc.(Block); ok {
+		b.Process(out, du)
+		return
+	}
+	for i := range out {
+		osc.Advance(du[i])
+		out[i] = osc.Value()
+	}
+}
+
+// grow returns a slice of length n, reusing buf if it is large enough.
+func grow(buf []float64, n int) []float64 {
+	if cap(buf) < n {
+		return make([]float64, n)
+	}
+	return buf[:n]
+}
+
+func (_ Null) Process(out, _ []float64) {
+	for i := range out {
+		out[i] = 0.0
+	}
+}
+
+func (s *sinOsc) Process(out, du []float64) {
+	for i := range out {
+		s.u += twoPi * du[i]
+		out[i] = math.Sin(s.u)
+	}
+}
+
+func (s *sawOsc) Process(out, du []float64) {
+	for i := range out {
+		s.Advance(du[i])
+		out[i] = s.Value()
+	}
+}
+
+func (s *pulseOsc) Process(out, du []float64) {
+	for i := range out {
+		s.Advance(du[i])
+		out[i] = s.Value()
+	}
+}
+
+func (s *triangleOsc) Process(out, du []float64) {
+	for i := range out {
+		s.Advance(du[i])
+		out[i] = s.Value()
+	}
+}
+
+func (m *multiOscillator) Process(out, du []float64) {
+	n := len(out)
+	m.duBuf = grow(m.duBuf, n)
+	m.valueBuf = grow(m.valueBuf, n)
+
+	for i := range out {
+		out[i] = 0
+	}
+
+	for k, osc := range m.osc {
+		for i, x := range du {
+			m.duBuf[i] = m.mul[k] * x
+		}
+		Process(osc, m.valueBuf, m.duBuf)
+		for i, x := range m.valueBuf {
+			out[i] += x * m.w[k]
+		}
+	}
+
+	for i := range out {
+		out[i] *= m.multiMul
+	}
+}
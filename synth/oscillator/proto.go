@@ -16,5 +16,13 @@ func FromProto(spec *pb.Oscillator) (Oscillator, error) {
 		return Sin(), nil
 	case *pb.Oscillator_Spectrum:
 		return FromSpectrum(opts.Spectrum), nil
+	case *pb.Oscillator_Square:
+		return Square(), nil
+	case *pb.Oscillator_Sawtooth:
+		return Sawtooth(), nil
+	case *pb.Oscillator_Triangle:
+		return Triangle(), nil
+	case *pb.Oscillator_Pulse:
+		return Pulse(opts.Pulse.Width), nil
 	}
 }
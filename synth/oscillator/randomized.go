@@ -10,6 +10,12 @@ type multiOscillator struct {
 	w        []float64
 	mul      []float64
 	osc      []Oscillator
+
+	// Initial phase of each component, for Seek.
+	phase []float64
+
+	// Buffers for Process.
+	duBuf, valueBuf []float64
 }
 
 func (m *multiOscillator) Value() float64 {
@@ -31,6 +37,7 @@ func (m *multiOscillator) Clone() Oscillator {
 	for i, osc := range m.osc {
 		rv.w = append(rv.w, m.w[i])
 		rv.mul = append(rv.mul, m.mul[i])
+		rv.phase = append(rv.phase, m.phase[i])
 		rv.osc = append(rv.osc, osc.Clone())
 	}
 	return rv
@@ -56,10 +63,12 @@ func Randomized(osc Oscillator, n int, width float64, weight WeightingFunction)
 		w := weight(1.0 - p)
 		totalW += w
 		mul := 1 + width*(p*2-1)
+		phase := rand.Float64()
 		osc := osc.Clone()
-		osc.Advance(rand.Float64())
+		osc.Advance(phase)
 		rv.w = append(rv.w, w)
 		rv.mul = append(rv.mul, mul)
+		rv.phase = append(rv.phase, phase)
 		rv.osc = append(rv.osc, osc)
 	}
 	rv.multiMul = 1.0 / math.Sqrt(totalW)
@@ -0,0 +1,43 @@
+package oscillator
+
+// Seeker is an Oscillator whose phase can be set directly, rather than by
+// replaying Advance from the start.
+type Seeker interface {
+	Oscillator
+
+	// Seek sets the phase to u cycles, as if advanced by u from the start.
+	// It returns false if this is not possible (because some component
+	// cannot seek), in which case the oscillator is left in an unspecified
+	// state and should be discarded.
+	Seek(u float64) bool
+}
+
+// Seek sets the phase of osc to u cycles after its start, reporting
+// whether it could.
+func Seek(osc Oscillator, u float64) bool {
+	s, ok := osc.(Seeker)
+	return ok && s.Seek(u)
+}
+
+func (_ Null) Seek(_ float64) bool { return true }
+
+func (s *sinOsc) Seek(u float64) bool {
+	s.u = twoPi * wrap(u)
+	return true
+}
+
+// Seek leaves the phase increment (used for band-limiting) as it was; it
+// is set by the next call to Advance.
+func (s *phasor) Seek(u float64) bool {
+	s.p = wrap(u)
+	return true
+}
+
+func (m *multiOscillator) Seek(u float64) bool {
+	for i, osc := range m.osc {
+		if !Seek(osc, m.phase[i]+m.mul[i]*u) {
+			return false
+		}
+	}
+	return true
+}
@@ -31,12 +31,14 @@ func FromSpectrum(spec *aborapb.Spectrum) Oscillator {
 		rv.w = append(rv.w, p.Amplitude)
 		rv.mul = append(rv.mul, p.Frequency*correction)
 		osc := Sin()
+		var unitPhase float64
 		if p.Phase > 0.0 {
 			twoPiPhase := p.Phase
-			unitPhase := (math.Pi + twoPiPhase) / (2 * math.Pi)
+			unitPhase = (math.Pi + twoPiPhase) / (2 * math.Pi)
 			osc.Advance(unitPhase)
 		}
 		rv.osc = append(rv.osc, osc)
+		rv.phase = append(rv.phase, unitPhase)
 		totalW += p.Amplitude
 	}
 	rv.multiMul = 1.0 / math.Sqrt(totalW)
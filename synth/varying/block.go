@@ -0,0 +1,104 @@
+package varying
+
+// Block is a Varying that can compute many values at once, which avoids the
+// overhead of calling Advance and Value for every sample.
+type Block interface {
+	Varying
+
+	// Process advances by dt and then writes the value to out[i], for
+	// each i in turn.
+	Process(out []float64, dt float64)
+}
+
+// Process advances v by dt and writes its value to out[i], for each i in
+// turn. It uses the Block implementation if v has one.
+func Process(v Varying, out []float64, dt float64) {
+	if b, ok := v.(Block); ok {
+		b.Process(out, dt)
+		return
+	}
+	for i := range out {
+		v.Advance(dt)
+		out[i] = v.Value()
+	}
+}
+
+// Sample writes the value of v to out[i] and then advances it by dt, for
+// each i in turn.
+func Sample(v Varying, out []float64, dt float64) {
+	if len(out) == 0 {
+		return
+	}
+	out[0] = v.Value()
+	Process(v, out[1:], dt)
+	v.Advance(dt)
+}
+
+// grow returns a slice of length n, reusing buf if it is large enough.
+func grow(buf []float64, n int) []float64 {
+	if cap(buf) < n {
+		return make([]float64, n)
+	}
+	return buf[:n]
+}
+
+func (c Constant) Process(out []float64, _ float64) {
+	for i := range out {
+		out[i] = float64(c)
+	}
+}
+
+func (m *mappedVarying) Process(out []float64, dt float64) {
+	Process(m.u, out, dt)
+	for i, x := range out {
+		out[i] = m.f(x)
+	}
+}
+
+func (e *interpolatedVarying) Process(out []float64, dt float64) {
+	for i := range out {
+		e.Advance(dt)
+		out[i] = e.Value()
+	}
+}
+
+func (x *oscillatingVarying) Process(out []float64, dt float64) {
+	n := len(out)
+
+	// The oscillator is advanced by the frequency before it changes.
+	x.freqBuf = grow(x.freqBuf, n)
+	Sample(x.freq, x.freqBuf, dt)
+
+	Process(x.val, out, dt)
+
+	var additive, multiplicative []float64
+	if x.additive != nil {
+		x.additiveBuf = grow(x.additiveBuf, n)
+		additive = x.additiveBuf
+		Process(x.additive, additive, dt)
+	}
+	if x.multiplicative != nil {
+		x.multiplicativeBuf = grow(x.multiplicativeBuf, n)
+		multiplicative = x.multiplicativeBuf
+		Process(x.multiplicative, multiplicative, dt)
+	}
+
+	for i, base := range out {
+		x.osc.Advance(x.freqBuf[i] * dt)
+		x.osc.Advance(dt)
+
+		var delta float64
+		if additive != nil {
+			delta += additive[i]
+		}
+		if multiplicative != nil {
+			delta += base * multiplicative[i]
+		}
+
+		if delta > 0.0 {
+			base += delta * x.osc.Value()
+		}
+
+		out[i] = base
+	}
+}
@@ -1,12 +1,30 @@
 package varying
 
 import (
+	"math"
+	"sort"
+
 	"github.com/steinarvk/abora/synth/interpolation"
 )
 
 type Point struct {
 	Time  float64
 	Value float64
+
+	// Curve is the shape of the segment from this point to the next. If
+	// nil, the varying's interpolation function is used.
+	Curve interpolation.Curve
+}
+
+// Segment returns the segment from point i to point i+1, for use with the
+// curve of point i.
+func Segment(points []Point, i int) interpolation.Segment {
+	var rv interpolation.Segment
+	for k, j := range interpolation.Neighbours(len(points), i) {
+		rv.T[k] = points[j].Time
+		rv.X[k] = points[j].Value
+	}
+	return rv
 }
 
 type interpolatedVarying struct {
@@ -43,7 +61,7 @@ func normalizePoints(points []Point) []Point {
 	t0 := points[0].Time
 	var rv []Point
 	for _, p := range points {
-		rv = append(rv, Point{Time: p.Time - t0, Value: p.Value})
+		rv = append(rv, Point{Time: p.Time - t0, Value: p.Value, Curve: p.Curve})
 	}
 	return rv
 }
@@ -61,25 +79,98 @@ func NewInterpolated(points []Point, opts ...interpolatedOption) Varying {
 }
 
 func (e *interpolatedVarying) Value() float64 {
+	return e.valueAt(e.t, e.index)
+}
+
+// valueAt returns the value at time t (within the cycle, if cyclic), where
+// index is the point at or before t.
+func (e *interpolatedVarying) valueAt(t float64, index int) float64 {
 	n := len(e.points)
 	if n == 0 {
 		return 0.0
 	}
 	lastTime := e.points[n-1].Time
-	if e.t >= lastTime {
+	if t >= lastTime {
 		if !e.infinite {
 			return 0.0
 		}
 		return e.points[n-1].Value
 	}
-	v0 := e.points[e.index].Value
-	v1 := e.points[e.index+1].Value
+	t0 := e.points[index].Time
+	t1 := e.points[index+1].Time
+	if curve := e.points[index].Curve; curve != nil {
+		return curve((t-t0)/(t1-t0), Segment(e.points, index))
+	}
+	v0 := e.points[index].Value
+	v1 := e.points[index+1].Value
 	if v0 == v1 {
 		return v0
 	}
-	t0 := e.points[e.index].Time
-	t1 := e.points[e.index+1].Time
-	return e.interpolator((e.t-t0)/(t1-t0), v0, v1)
+	return e.interpolator((t-t0)/(t1-t0), v0, v1)
+}
+
+// locate returns the time within the cycle (if cyclic) corresponding to t
+// seconds after the start, and the index of the point at or before it.
+func (e *interpolatedVarying) locate(t float64) (float64, int) {
+	n := len(e.points)
+	if n == 0 {
+		return t, 0
+	}
+	lastTime := e.points[n-1].Time
+	if e.cyclic && e.infinite && lastTime > 0 && t >= lastTime {
+		t -= math.Floor(t/lastTime) * lastTime
+	}
+	index := sort.Search(n, func(i int) bool { return e.points[i].Time > t }) - 1
+	if index < 0 {
+		index = 0
+	}
+	return t, index
+}
+
+func (e *interpolatedVarying) ValueAt(t float64) float64 {
+	return e.valueAt(e.locate(t))
+}
+
+func (e *interpolatedVarying) Seek(t float64) bool {
+	e.t, e.index = e.locate(t)
+	return true
+}
+
+func (e *interpolatedVarying) Integral(t float64) float64 {
+	n := len(e.points)
+	if n == 0 || t <= 0 {
+		return 0.0
+	}
+	lastTime := e.points[n-1].Time
+	if t < lastTime {
+		return e.integrateTo(t)
+	}
+	if e.cyclic && e.infinite && lastTime > 0 {
+		cycles := math.Floor(t / lastTime)
+		return cycles*e.integrateTo(lastTime) + e.integrateTo(t-cycles*lastTime)
+	}
+	rv := e.integrateTo(lastTime)
+	if e.infinite {
+		rv += e.points[n-1].Value * (t - lastTime)
+	}
+	return rv
+}
+
+// integrateTo integrates the value from the first point to t, which must
+// not be after the last point.
+func (e *interpolatedVarying) integrateTo(t float64) float64 {
+	var rv float64
+	for i := 0; i+1 < len(e.points) && e.points[i].Time < t; i++ {
+		t0 := e.points[i].Time
+		t1 := math.Min(t, e.points[i+1].Time)
+		if t1 <= t0 {
+			continue
+		}
+		rv += gaussLegendre(func(x float64) float64 {
+			return e.valueAt(x, i)
+		}, t0, t1)
+	}
+	return rv
 }
 
 func (e *interpolatedVarying) Advance(dt float64) {
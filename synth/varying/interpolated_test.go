@@ -1,7 +1,10 @@
 package varying
 
 import (
+	"math"
 	"testing"
+
+	"github.com/steinarvk/abora/synth/interpolation"
 )
 
 func TestInterpolated(t *testing.T) {
@@ -32,3 +35,76 @@ func TestInterpolated(t *testing.T) {
 		t.Errorf("expected Value() = 500 at end, got %v", x.Value())
 	}
 }
+
+func TestInterpolatedPerPointCurves(t *testing.T) {
+	x := NewInterpolated([]Point{
+		{Time: 0.0, Value: 220.0, Curve: interpolation.Local(interpolation.Geometric)},
+		{Time: 1.0, Value: 880.0, Curve: interpolation.CatmullRom},
+		{Time: 2.0, Value: 440.0},
+		{Time: 3.0, Value: 440.0},
+	})
+
+	x.Advance(0.5)
+
+	// Halfway through a two-octave glide is one octave up.
+	if math.Abs(x.Value()-440.0) > 1e-9 {
+		t.Errorf("expected Value() = 440 halfway through log-frequency glide, got %v", x.Value())
+	}
+
+	x.Advance(0.5)
+
+	if math.Abs(x.Value()-880.0) > 1e-9 {
+		t.Errorf("expected spline to pass through 880, got %v", x.Value())
+	}
+
+	// The spline leaves 880 with the slope between its neighbours, so it
+	// is still rising.
+	x.Advance(0.01)
+
+	if x.Value() <= 880.0 {
+		t.Errorf("expected spline to rise after 880, got %v", x.Value())
+	}
+
+	x.Advance(1.49)
+
+	if x.Value() != 440.0 {
+		t.Errorf("expected Value() = 440 in linear segment, got %v", x.Value())
+	}
+}
+
+func TestInterpolatedSeek(t *testing.T) {
+	x := NewInterpolated([]Point{
+		{Time: 0.0, Value: 100.0},
+		{Time: 1.0, Value: 300.0},
+		{Time: 2.0, Value: 300.0, Curve: interpolation.CatmullRom},
+		{Time: 3.0, Value: 100.0},
+	}).(interface {
+		Seeker
+		Evaluator
+		Integrator
+	})
+
+	if got := x.ValueAt(0.5); got != 200.0 {
+		t.Errorf("expected ValueAt(0.5) = 200, got %v", got)
+	}
+
+	// The linear segments integrate exactly.
+	if got := x.Integral(2.0); math.Abs(got-500.0) > 1e-9 {
+		t.Errorf("expected Integral(2) = 500, got %v", got)
+	}
+	if got := x.Integral(4.0); math.Abs(got-x.Integral(3.0)-100.0) > 1e-9 {
+		t.Errorf("expected the last value to be held after the last point, got Integral(4) = %v", got)
+	}
+
+	x.Seek(2.5)
+	want := x.ValueAt(2.5)
+
+	y := NewInterpolated(x.(*interpolatedVarying).points)
+	for i := 0; i < 25; i++ {
+		y.Advance(0.1)
+	}
+
+	if math.Abs(x.Value()-want) > 1e-9 || math.Abs(y.Value()-want) > 1e-9 {
+		t.Errorf("expected Value() = %v after seeking and advancing, got %v and %v", want, x.Value(), y.Value())
+	}
+}
@@ -14,6 +14,9 @@ type oscillatingVarying struct {
 	freq           Varying
 	additive       Varying
 	multiplicative Varying
+
+	// Buffers for Process.
+	freqBuf, additiveBuf, multiplicativeBuf []float64
 }
 
 func (x *oscillatingVarying) Advance(dt float64) {
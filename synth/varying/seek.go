@@ -0,0 +1,102 @@
+package varying
+
+import (
+	"github.com/steinarvk/abora/synth/oscillator"
+)
+
+// Seeker is a Varying that can move directly to any time, rather than
+// replaying Advance from the start.
+type Seeker interface {
+	Varying
+
+	// Seek moves to t seconds after the start. It returns false if this
+	// is not possible (because some component cannot seek), in which case
+	// the varying is left in an unspecified state and should be discarded.
+	Seek(t float64) bool
+}
+
+// Evaluator is a Varying whose value at any time can be computed without
+// changing its state.
+type Evaluator interface {
+	Varying
+
+	// ValueAt returns the value t seconds after the start.
+	ValueAt(t float64) float64
+}
+
+// Integrator is a Varying whose integral can be computed directly. The
+// integral of a frequency is the phase of an oscillator driven by it.
+type Integrator interface {
+	Varying
+
+	// Integral returns the integral of the value from the start to t
+	// seconds after it.
+	Integral(t float64) float64
+}
+
+// Seek moves v to t seconds after its start, reporting whether it could.
+// A nil varying trivially succeeds.
+func Seek(v Varying, t float64) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.(Seeker)
+	return ok && s.Seek(t)
+}
+
+// Integral returns the integral of v from its start to t seconds after it,
+// if v is an Integrator.
+func Integral(v Varying, t float64) (float64, bool) {
+	i, ok := v.(Integrator)
+	if !ok {
+		return 0, false
+	}
+	return i.Integral(t), true
+}
+
+func (c Constant) Seek(_ float64) bool        { return true }
+func (c Constant) ValueAt(_ float64) float64  { return float64(c) }
+func (c Constant) Integral(t float64) float64 { return float64(c) * t }
+
+func (m *mappedVarying) Seek(t float64) bool {
+	return Seek(m.u, t)
+}
+
+// Seek requires the oscillation frequency to be an Integrator, since the
+// phase of the oscillation is its integral.
+func (x *oscillatingVarying) Seek(t float64) bool {
+	freqIntegral, ok := Integral(x.freq, t)
+	if !ok {
+		return false
+	}
+	// Advance moves the oscillator by dt besides the frequency.
+	if !oscillator.Seek(x.osc, freqIntegral+t) {
+		return false
+	}
+	for _, v := range []Varying{x.val, x.freq, x.additive, x.multiplicative} {
+		if !Seek(v, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// gaussLegendre integrates f over [a, b] using three-point Gauss-Legendre
+// quadrature on each of a few subintervals. It is exact for polynomials of
+// degree up to five, such as linear and cubic curves, and never evaluates
+// f at the end points, where curves like Hold jump.
+func gaussLegendre(f func(float64) float64, a, b float64) float64 {
+	const subintervals = 4
+	nodes := [3]float64{-0.7745966692414834, 0, 0.7745966692414834}
+	weights := [3]float64{5.0 / 9.0, 8.0 / 9.0, 5.0 / 9.0}
+
+	h := (b - a) / subintervals
+	var rv float64
+	for i := 0; i < subintervals; i++ {
+		mid := a + (float64(i)+0.5)*h
+		for j, x := range nodes {
+			rv += weights[j] * f(mid+x*h/2)
+		}
+	}
+	return rv * h / 2
+}
@@ -0,0 +1,295 @@
+// Package transcribe turns recordings of spectrally simple sounds (such as
+// whistling) into chirp specifications that can be played back by the synth.
+package transcribe
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/steinarvk/abora/analysis"
+	"github.com/steinarvk/abora/snippet"
+
+	aborapb "github.com/steinarvk/abora/proto"
+)
+
+type Params struct {
+	Analysis *analysis.Params
+
+	// LoudnessThreshold is the loudness (relative to the near-maximum
+	// loudness of the recording) below which a frame is considered silent.
+	LoudnessThreshold float64
+
+	// MaxPitchJumpSemitones is the largest pitch change between two
+	// consecutive frames that is still considered part of the same note.
+	MaxPitchJumpSemitones float64
+
+	// ReattackRatio is the loudness increase (relative to a preceding dip)
+	// that starts a new note even if the pitch is continuous.
+	ReattackRatio float64
+
+	MinNoteSeconds  float64
+	PointsPerSecond float64
+}
+
+var (
+	defaultParams = Params{
+		LoudnessThreshold:     0.1,
+		MaxPitchJumpSemitones: 1.5,
+		ReattackRatio:         2.0,
+		MinNoteSeconds:        0.05,
+		PointsPerSecond:       50.0,
+	}
+
+	defaultsContext = &aborapb.Context{
+		Oscillator: &aborapb.Oscillator{
+			Oscillators: &aborapb.Oscillator_Sine{},
+		},
+		Envelope: &aborapb.Envelope{
+			EnvelopeKind: &aborapb.Envelope_Adsr{
+				Adsr: &aborapb.ADSREnvelope{
+					AttackDuration:  0.01,
+					DecayDuration:   0.0,
+					SustainLevel:    1.0,
+					ReleaseDuration: 0.02,
+				},
+			},
+		},
+	}
+)
+
+func normalizeParams(params *Params) {
+	if params.Analysis == nil {
+		params.Analysis = &analysis.Params{}
+	}
+	if params.LoudnessThreshold == 0 {
+		params.LoudnessThreshold = defaultParams.LoudnessThreshold
+	}
+	if params.MaxPitchJumpSemitones == 0 {
+		params.MaxPitchJumpSemitones = defaultParams.MaxPitchJumpSemitones
+	}
+	if params.ReattackRatio == 0 {
+		params.ReattackRatio = defaultParams.ReattackRatio
+	}
+	if params.MinNoteSeconds == 0 {
+		params.MinNoteSeconds = defaultParams.MinNoteSeconds
+	}
+	if params.PointsPerSecond == 0 {
+		params.PointsPerSecond = defaultParams.PointsPerSecond
+	}
+}
+
+// frame is the pitch and loudness estimate for a single analysis frame.
+type frame struct {
+	t        float64
+	freq     float64
+	loudness float64
+}
+
+func semitones(f0, f1 float64) float64 {
+	return 12 * math.Log2(f1/f0)
+}
+
+// dominantFrequency finds the heaviest frequency bucket of a point and
+// refines its position by fitting a parabola through its neighbours.
+func dominantFrequency(anal *analysis.Analysis, point *analysis.AnalysisPoint) float64 {
+	best := 0
+	for i, x := range point.Values {
+		if x > point.Values[best] {
+			best = i
+		}
+	}
+
+	freq := anal.FrequencyBuckets[best].Midpoint()
+
+	if best == 0 || best == len(point.Values)-1 {
+		return freq
+	}
+
+	a, b, c := point.Values[best-1], point.Values[best], point.Values[best+1]
+	denom := a - 2*b + c
+	if denom == 0 {
+		return freq
+	}
+	offset := 0.5 * (a - c) / denom
+
+	bucket := anal.FrequencyBuckets[best]
+	return freq + offset*(bucket.HighHz-bucket.LowHz)
+}
+
+func median3(a, b, c float64) float64 {
+	xs := []float64{a, b, c}
+	sort.Float64s(xs)
+	return xs[1]
+}
+
+func trackFrames(s snippet.Snippet, params *Params) ([]frame, float64, error) {
+	anal, err := analysis.Analyze(s, params.Analysis)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	loud, err := analysis.AnalyzeLoudness(s, params.Analysis)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(anal.Points) == 0 || len(loud.Values) == 0 {
+		return nil, 0, errors.New("recording too short to transcribe")
+	}
+
+	var rv []frame
+
+	for _, point := range anal.Points {
+		centre := point.FrameNumber - anal.WindowSize/2
+		rv = append(rv, frame{
+			t:        float64(centre) / float64(anal.SampleRate),
+			freq:     dominantFrequency(anal, point),
+			loudness: loud.ValueAt(centre),
+		})
+	}
+
+	// Smooth away single-frame outliers in the pitch track.
+	smoothed := make([]float64, len(rv))
+	for i := range rv {
+		if i == 0 || i == len(rv)-1 {
+			smoothed[i] = rv[i].freq
+			continue
+		}
+		smoothed[i] = median3(rv[i-1].freq, rv[i].freq, rv[i+1].freq)
+	}
+	for i := range rv {
+		rv[i].freq = smoothed[i]
+	}
+
+	return rv, loud.ValueStats.Quantile(0.99), nil
+}
+
+// segment splits the frames into notes at silences, pitch discontinuities
+// and re-attacks.
+func segment(frames []frame, nearMax float64, params *Params) [][]frame {
+	var rv [][]frame
+	var current []frame
+	var peak, dip float64
+	dipIndex := -1
+
+	flush := func() {
+		if len(current) > 0 {
+			rv = append(rv, current)
+		}
+		current = nil
+		peak = 0
+		dipIndex = -1
+	}
+
+	threshold := params.LoudnessThreshold * nearMax
+
+	for _, f := range frames {
+		if f.loudness < threshold {
+			flush()
+			continue
+		}
+
+		if n := len(current); n > 0 {
+			last := current[n-1]
+			if math.Abs(semitones(last.freq, f.freq)) > params.MaxPitchJumpSemitones {
+				flush()
+			}
+		}
+
+		if dipIndex >= 0 && dip < 0.5*peak && f.loudness > params.ReattackRatio*dip {
+			rest := append([]frame{}, current[dipIndex:]...)
+			current = current[:dipIndex]
+			flush()
+			current = rest
+			for _, g := range current {
+				if g.loudness > peak {
+					peak = g.loudness
+				}
+			}
+		}
+
+		current = append(current, f)
+
+		if f.loudness > peak {
+			peak = f.loudness
+			dipIndex = -1
+		} else if dipIndex < 0 || f.loudness < dip {
+			dip = f.loudness
+			dipIndex = len(current) - 1
+		}
+	}
+	flush()
+
+	return rv
+}
+
+func value(x float64) *aborapb.DoubleOrHold {
+	return &aborapb.DoubleOrHold{
+		ValueOrHold: &aborapb.DoubleOrHold_Value{
+			Value: x,
+		},
+	}
+}
+
+func toChirp(note []frame, framePeriod float64, params *Params) *aborapb.Chirp {
+	begin := note[0].t
+	end := note[len(note)-1].t
+
+	rv := &aborapb.Chirp{
+		BeginTime: begin,
+		Duration:  end - begin + framePeriod,
+	}
+
+	minInterval := 1.0 / params.PointsPerSecond
+	lastEmitted := math.Inf(-1)
+
+	for i, f := range note {
+		if i != len(note)-1 && f.t-lastEmitted < minInterval {
+			continue
+		}
+		lastEmitted = f.t
+
+		// The loudness is an RMS value; a sine of amplitude A has RMS A/sqrt(2).
+		amp := math.Min(1.0, f.loudness*math.Sqrt2)
+
+		rv.Points = append(rv.Points, &aborapb.Point{
+			T: f.t - begin,
+			Settings: &aborapb.PointSettings{
+				Freq:      value(f.freq),
+				Amplitude: value(amp),
+			},
+		})
+	}
+
+	return rv
+}
+
+// Transcribe tracks the dominant pitch of a recording over time and returns
+// the notes found as chirps.
+func Transcribe(s snippet.Snippet, params *Params) (*aborapb.Chirps, error) {
+	if params == nil {
+		params = &Params{}
+	}
+	normalizeParams(params)
+
+	frames, nearMax, err := trackFrames(s, params)
+	if err != nil {
+		return nil, err
+	}
+
+	framePeriod := 1.0 / params.Analysis.EffectiveAnalysesPerSecond(s.SampleRate())
+
+	rv := &aborapb.Chirps{
+		Defaults: defaultsContext,
+	}
+
+	for _, note := range segment(frames, nearMax, params) {
+		if float64(len(note))*framePeriod < params.MinNoteSeconds {
+			continue
+		}
+		rv.Chirp = append(rv.Chirp, toChirp(note, framePeriod, params))
+	}
+
+	return rv, nil
+}
@@ -0,0 +1,57 @@
+package transcribe
+
+import (
+	"math"
+	"testing"
+
+	"github.com/steinarvk/abora/snippet"
+)
+
+type note struct {
+	begin, end float64
+	freq       float64
+}
+
+func synthesize(sampleRate int, duration float64, notes []note) snippet.Snippet {
+	samples := make([]float64, int(duration*float64(sampleRate)))
+	for _, n := range notes {
+		for i := int(n.begin * float64(sampleRate)); i < int(n.end*float64(sampleRate)); i++ {
+			t := float64(i) / float64(sampleRate)
+			samples[i] += 0.5 * math.Sin(2*math.Pi*n.freq*t)
+		}
+	}
+	return snippet.New(sampleRate, samples)
+}
+
+func TestTranscribeTwoNotes(t *testing.T) {
+	notes := []note{
+		{begin: 0.2, end: 0.6, freq: 880.0},
+		{begin: 0.8, end: 1.3, freq: 1320.0},
+	}
+	snip := synthesize(44100, 1.5, notes)
+
+	chirps, err := Transcribe(snip, nil)
+	if err != nil {
+		t.Fatalf("Transcribe() = %v", err)
+	}
+
+	if len(chirps.Chirp) != len(notes) {
+		t.Fatalf("Transcribe() found %d notes, want %d: %v", len(chirps.Chirp), len(notes), chirps)
+	}
+
+	for i, n := range notes {
+		got := chirps.Chirp[i]
+		if math.Abs(got.BeginTime-n.begin) > 0.05 {
+			t.Errorf("note %d: begin time %v, want ~%v", i, got.BeginTime, n.begin)
+		}
+		if math.Abs(got.BeginTime+got.Duration-n.end) > 0.05 {
+			t.Errorf("note %d: end time %v, want ~%v", i, got.BeginTime+got.Duration, n.end)
+		}
+		for _, p := range got.Points {
+			freq := p.Settings.Freq.GetValue()
+			if math.Abs(semitones(n.freq, freq)) > 0.1 {
+				t.Errorf("note %d: point at %v has frequency %v, want ~%v", i, p.T, freq, n.freq)
+			}
+		}
+	}
+}
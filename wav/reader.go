@@ -0,0 +1,245 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	formatPCM        = 0x0001
+	formatIEEEFloat  = 0x0003
+	formatExtensible = 0xfffe
+)
+
+// Format describes the sample encoding of a WAV file.
+type Format struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	Float         bool
+}
+
+func (f Format) bytesPerSample() int {
+	return (f.BitsPerSample + 7) / 8
+}
+
+// Reader decodes the samples of a WAV file as float64 values in [-1,1].
+// Multichannel samples are interleaved.
+type Reader struct {
+	r          io.ReadSeeker
+	format     Format
+	dataOffset int64
+	frames     int
+	frame      int
+	buf        []byte
+}
+
+// IsWAV checks whether the header (at least the first 12 bytes of a file)
+// identifies a WAV file.
+func IsWAV(header []byte) bool {
+	return len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WAVE"
+}
+
+type chunkHeader struct {
+	ID   [4]byte
+	Size uint32
+}
+
+func (r *Reader) parseFormat(data []byte) error {
+	if len(data) < 16 {
+		return fmt.Errorf("fmt chunk too short (%d bytes)", len(data))
+	}
+
+	le := binary.LittleEndian
+	tag := le.Uint16(data[0:2])
+	r.format = Format{
+		Channels:      int(le.Uint16(data[2:4])),
+		SampleRate:    int(le.Uint32(data[4:8])),
+		BitsPerSample: int(le.Uint16(data[14:16])),
+	}
+
+	if tag == formatExtensible {
+		if len(data) < 40 {
+			return fmt.Errorf("WAVE_FORMAT_EXTENSIBLE fmt chunk too short (%d bytes)", len(data))
+		}
+		// The first two bytes of the SubFormat GUID hold the actual format tag.
+		tag = le.Uint16(data[24:26])
+	}
+
+	switch tag {
+	case formatPCM:
+		switch r.format.BitsPerSample {
+		case 8, 16, 24, 32:
+		default:
+			return fmt.Errorf("unsupported PCM bit depth %d", r.format.BitsPerSample)
+		}
+	case formatIEEEFloat:
+		r.format.Float = true
+		switch r.format.BitsPerSample {
+		case 32, 64:
+		default:
+			return fmt.Errorf("unsupported float bit depth %d", r.format.BitsPerSample)
+		}
+	default:
+		return fmt.Errorf("unsupported WAV format tag 0x%04x", tag)
+	}
+
+	if r.format.Channels < 1 {
+		return fmt.Errorf("invalid number of channels %d", r.format.Channels)
+	}
+
+	return nil
+}
+
+// NewReader parses the header of a WAV file and positions the reader at
+// the first sample.
+func NewReader(rs io.ReadSeeker) (*Reader, error) {
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(rs, header); err != nil {
+		return nil, err
+	}
+	if !IsWAV(header) {
+		return nil, errors.New("not a RIFF/WAVE file")
+	}
+
+	r := &Reader{r: rs}
+	haveFormat := false
+	pos := int64(12)
+
+	for {
+		var chunk chunkHeader
+		if err := binary.Read(rs, binary.LittleEndian, &chunk); err != nil {
+			if err == io.EOF {
+				return nil, errors.New("no data chunk found")
+			}
+			return nil, err
+		}
+		pos += 8
+
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			data := make([]byte, chunk.Size)
+			if _, err := io.ReadFull(rs, data); err != nil {
+				return nil, err
+			}
+			if err := r.parseFormat(data); err != nil {
+				return nil, err
+			}
+			haveFormat = true
+
+		case "data":
+			if !haveFormat {
+				return nil, errors.New("data chunk precedes fmt chunk")
+			}
+			size := int64(chunk.Size)
+			// Streaming writers may leave the size too large, as may
+			// truncation of the file.
+			if size > end-pos {
+				size = end - pos
+			}
+			frameSize := int64(r.format.Channels * r.format.bytesPerSample())
+			r.dataOffset = pos
+			r.frames = int(size / frameSize)
+			return r, nil
+
+		default:
+			if _, err := rs.Seek(int64(chunk.Size), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+
+		pos += int64(chunk.Size)
+		if chunk.Size%2 == 1 {
+			if _, err := rs.Seek(1, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			pos++
+		}
+	}
+}
+
+func (r *Reader) Format() Format {
+	return r.format
+}
+
+// TotalFrames returns the number of frames (samples per channel) in the file.
+func (r *Reader) TotalFrames() int {
+	return r.frames
+}
+
+// Seek positions the reader at the given frame.
+func (r *Reader) Seek(frame int) error {
+	if frame < 0 || frame > r.frames {
+		return fmt.Errorf("seek to frame %d out of range [0,%d]", frame, r.frames)
+	}
+	frameSize := int64(r.format.Channels * r.format.bytesPerSample())
+	if _, err := r.r.Seek(r.dataOffset+int64(frame)*frameSize, io.SeekStart); err != nil {
+		return err
+	}
+	r.frame = frame
+	return nil
+}
+
+func (r *Reader) decode(b []byte) float64 {
+	le := binary.LittleEndian
+	switch {
+	case r.format.Float && r.format.BitsPerSample == 32:
+		return float64(math.Float32frombits(le.Uint32(b)))
+	case r.format.Float:
+		return math.Float64frombits(le.Uint64(b))
+	case r.format.BitsPerSample == 8:
+		return (float64(b[0]) - 128) / 128
+	case r.format.BitsPerSample == 16:
+		return float64(int16(le.Uint16(b))) / (1 << 15)
+	case r.format.BitsPerSample == 24:
+		x := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		return float64(x) / (1 << 23)
+	default:
+		return float64(int32(le.Uint32(b))) / (1 << 31)
+	}
+}
+
+// Read decodes whole frames into buf, returning the number of samples
+// written. It returns io.EOF once all frames have been read.
+func (r *Reader) Read(buf []float64) (int, error) {
+	channels := r.format.Channels
+	width := r.format.bytesPerSample()
+
+	n := len(buf) / channels
+	if remaining := r.frames - r.frame; n > remaining {
+		n = remaining
+	}
+	if n <= 0 {
+		if r.frame >= r.frames {
+			return 0, io.EOF
+		}
+		return 0, fmt.Errorf("buffer too small for a single frame (%d channels)", channels)
+	}
+
+	sz := n * channels * width
+	if cap(r.buf) < sz {
+		r.buf = make([]byte, sz)
+	}
+	raw := r.buf[:sz]
+	if _, err := io.ReadFull(r.r, raw); err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < n*channels; i++ {
+		buf[i] = r.decode(raw[i*width : (i+1)*width])
+	}
+	r.frame += n
+
+	return n * channels, nil
+}
@@ -0,0 +1,173 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+type testFile struct {
+	tag        uint16
+	subFormat  uint16
+	channels   int
+	bits       int
+	sampleRate int
+	samples    []byte
+}
+
+func (f testFile) bytes() []byte {
+	var fmtChunk bytes.Buffer
+	le := binary.LittleEndian
+	blockAlign := f.channels * f.bits / 8
+	binary.Write(&fmtChunk, le, f.tag)
+	binary.Write(&fmtChunk, le, uint16(f.channels))
+	binary.Write(&fmtChunk, le, uint32(f.sampleRate))
+	binary.Write(&fmtChunk, le, uint32(f.sampleRate*blockAlign))
+	binary.Write(&fmtChunk, le, uint16(blockAlign))
+	binary.Write(&fmtChunk, le, uint16(f.bits))
+	if f.tag == formatExtensible {
+		binary.Write(&fmtChunk, le, uint16(22))
+		binary.Write(&fmtChunk, le, uint16(f.bits))
+		binary.Write(&fmtChunk, le, uint32(0))
+		guid := make([]byte, 16)
+		le.PutUint16(guid, f.subFormat)
+		fmtChunk.Write(guid)
+	}
+
+	var rv bytes.Buffer
+	rv.WriteString("RIFF")
+	binary.Write(&rv, le, uint32(4+8+fmtChunk.Len()+8+len(f.samples)))
+	rv.WriteString("WAVE")
+	rv.WriteString("LIST")
+	binary.Write(&rv, le, uint32(3))
+	rv.Write([]byte{1, 2, 3, 0})
+	rv.WriteString("fmt ")
+	binary.Write(&rv, le, uint32(fmtChunk.Len()))
+	rv.Write(fmtChunk.Bytes())
+	rv.WriteString("data")
+	binary.Write(&rv, le, uint32(len(f.samples)))
+	rv.Write(f.samples)
+	return rv.Bytes()
+}
+
+func encode(xs ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, x := range xs {
+		binary.Write(&buf, binary.LittleEndian, x)
+	}
+	return buf.Bytes()
+}
+
+func TestReader(t *testing.T) {
+	testcases := []struct {
+		name string
+		file testFile
+		want []float64
+	}{
+		{
+			name: "8-bit",
+			file: testFile{tag: formatPCM, channels: 1, bits: 8, samples: []byte{128, 192, 0}},
+			want: []float64{0, 0.5, -1},
+		},
+		{
+			name: "16-bit stereo",
+			file: testFile{tag: formatPCM, channels: 2, bits: 16, samples: encode(int16(16384), int16(-32768))},
+			want: []float64{0.5, -1},
+		},
+		{
+			name: "24-bit",
+			file: testFile{tag: formatPCM, channels: 1, bits: 24, samples: []byte{0, 0, 0x40, 0, 0, 0xc0}},
+			want: []float64{0.5, -0.5},
+		},
+		{
+			name: "32-bit",
+			file: testFile{tag: formatPCM, channels: 1, bits: 32, samples: encode(int32(-1 << 30))},
+			want: []float64{-0.5},
+		},
+		{
+			name: "32-bit float",
+			file: testFile{tag: formatIEEEFloat, channels: 1, bits: 32, samples: encode(float32(0.25), float32(1.5))},
+			want: []float64{0.25, 1.5},
+		},
+		{
+			name: "64-bit float extensible",
+			file: testFile{tag: formatExtensible, subFormat: formatIEEEFloat, channels: 1, bits: 64, samples: encode(0.125)},
+			want: []float64{0.125},
+		},
+		{
+			name: "16-bit extensible",
+			file: testFile{tag: formatExtensible, subFormat: formatPCM, channels: 1, bits: 16, samples: encode(int16(-16384))},
+			want: []float64{-0.5},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc.file.sampleRate = 48000
+		r, err := NewReader(bytes.NewReader(tc.file.bytes()))
+		if err != nil {
+			t.Errorf("%s: NewReader() = %v", tc.name, err)
+			continue
+		}
+		if r.Format().SampleRate != 48000 || r.Format().Channels != tc.file.channels {
+			t.Errorf("%s: Format() = %+v", tc.name, r.Format())
+		}
+		if r.TotalFrames()*tc.file.channels != len(tc.want) {
+			t.Errorf("%s: TotalFrames() = %d, want %d", tc.name, r.TotalFrames(), len(tc.want)/tc.file.channels)
+		}
+		got := make([]float64, 16)
+		n, err := r.Read(got)
+		if err != nil {
+			t.Errorf("%s: Read() = %v", tc.name, err)
+			continue
+		}
+		got = got[:n]
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: Read() = %v, want %v", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-tc.want[i]) > 1e-9 {
+				t.Errorf("%s: Read() = %v, want %v", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestReaderSeek(t *testing.T) {
+	f := testFile{tag: formatPCM, channels: 1, bits: 16, sampleRate: 8000, samples: encode(int16(0), int16(8192), int16(16384), int16(-8192))}
+	r, err := NewReader(bytes.NewReader(f.bytes()))
+	if err != nil {
+		t.Fatalf("NewReader() = %v", err)
+	}
+	if err := r.Seek(2); err != nil {
+		t.Fatalf("Seek(2) = %v", err)
+	}
+	got := make([]float64, 4)
+	n, err := r.Read(got)
+	if err != nil || n != 2 || got[0] != 0.5 || got[1] != -0.25 {
+		t.Errorf("Read() after Seek(2) = %v, %v; want [0.5 -0.25]", got[:n], err)
+	}
+}
+
+func TestReaderEmptyData(t *testing.T) {
+	f := testFile{tag: formatPCM, channels: 1, bits: 16, sampleRate: 8000}
+	data := f.bytes()
+	// A trailing chunk after the empty data chunk must not be read as
+	// samples.
+	data = append(data, "LIST"...)
+	data = append(data, encode(uint32(4), int16(1000), int16(-1000))...)
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader() = %v", err)
+	}
+	if r.TotalFrames() != 0 {
+		t.Errorf("TotalFrames() = %d, want 0", r.TotalFrames())
+	}
+	got := make([]float64, 4)
+	if n, _ := r.Read(got); n != 0 {
+		t.Errorf("Read() = %v, want no samples", got[:n])
+	}
+}
@@ -1,56 +1,366 @@
 package wav
 
 import (
+	"bufio"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"log"
 	"math"
+	"math/rand"
 	"os"
+)
+
+type writeOption interface {
+	Apply(*writeSettings)
+}
+
+type writeSettings struct {
+	format Format
+	dither bool
+}
+
+// BitDepth sets the number of bits per integer sample: 16, 24 or 32.
+type BitDepth int
+
+func (b BitDepth) Apply(s *writeSettings) {
+	s.format.BitsPerSample = int(b)
+	s.format.Float = false
+}
 
-	"github.com/cryptix/wav"
+// FloatSamples writes 32-bit floating point samples. Unlike integer
+// samples, these can represent values outside of [-1,1].
+type FloatSamples struct{}
+
+func (_ FloatSamples) Apply(s *writeSettings) {
+	s.format.BitsPerSample = 32
+	s.format.Float = true
+}
+
+// Channels sets the number of channels. The samples written must then be
+// interleaved.
+type Channels int
+
+func (c Channels) Apply(s *writeSettings) { s.format.Channels = int(c) }
+
+// NoDither disables the triangular (TPDF) dither otherwise added when
+// writing 16- or 24-bit samples.
+type NoDither struct{}
+
+func (_ NoDither) Apply(s *writeSettings) { s.dither = false }
+
+var (
+	// The trailing part of the WAVE_FORMAT_EXTENSIBLE SubFormat GUIDs.
+	subFormatGUIDSuffix = []byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71}
 )
 
-func WriteFile(filename string, sampleRate int, ch <-chan float64) error {
+func (f Format) validate() error {
+	if f.Channels < 1 {
+		return fmt.Errorf("invalid number of channels %d", f.Channels)
+	}
+	if f.Float && f.BitsPerSample != 32 {
+		return fmt.Errorf("unsupported float bit depth %d", f.BitsPerSample)
+	}
+	switch f.BitsPerSample {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("unsupported bit depth %d", f.BitsPerSample)
+	}
+	return nil
+}
+
+func (f Format) tag() uint16 {
+	if f.Float {
+		return formatIEEEFloat
+	}
+	return formatPCM
+}
+
+// header returns the RIFF header up to and including the header of the
+// data chunk, for the given amount of sample data.
+func (f Format) header(dataSize uint32) []byte {
+	le := binary.LittleEndian
+	blockAlign := f.Channels * f.bytesPerSample()
+
+	var fmtChunk []byte
+	if f.Channels > 2 {
+		fmtChunk = le.AppendUint16(fmtChunk, formatExtensible)
+	} else {
+		fmtChunk = le.AppendUint16(fmtChunk, f.tag())
+	}
+	fmtChunk = le.AppendUint16(fmtChunk, uint16(f.Channels))
+	fmtChunk = le.AppendUint32(fmtChunk, uint32(f.SampleRate))
+	fmtChunk = le.AppendUint32(fmtChunk, uint32(f.SampleRate*blockAlign))
+	fmtChunk = le.AppendUint16(fmtChunk, uint16(blockAlign))
+	fmtChunk = le.AppendUint16(fmtChunk, uint16(f.BitsPerSample))
+	if f.Channels > 2 {
+		fmtChunk = le.AppendUint16(fmtChunk, 22)
+		fmtChunk = le.AppendUint16(fmtChunk, uint16(f.BitsPerSample))
+		fmtChunk = le.AppendUint32(fmtChunk, 0)
+		fmtChunk = le.AppendUint16(fmtChunk, f.tag())
+		fmtChunk = append(fmtChunk, subFormatGUIDSuffix...)
+	}
+
+	padding := dataSize % 2
+
+	var rv []byte
+	rv = append(rv, "RIFF"...)
+	rv = le.AppendUint32(rv, uint32(4+8+len(fmtChunk)+8)+dataSize+padding)
+	rv = append(rv, "WAVE"...)
+	rv = append(rv, "fmt "...)
+	rv = le.AppendUint32(rv, uint32(len(fmtChunk)))
+	rv = append(rv, fmtChunk...)
+	rv = append(rv, "data"...)
+	rv = le.AppendUint32(rv, dataSize)
+	return rv
+}
+
+// sampleEncoder converts samples to their binary representation, keeping
+// track of the largest value seen.
+type sampleEncoder struct {
+	format Format
+	dither bool
+	rng    *rand.Rand
+	buf    []byte
+	worst  float64
+	count  int64
+}
+
+func newSampleEncoder(format Format, dither bool) *sampleEncoder {
+	return &sampleEncoder{
+		format: format,
+		dither: dither && !format.Float && format.BitsPerSample < 32,
+		rng:    rand.New(rand.NewSource(1)),
+		buf:    make([]byte, 8),
+	}
+}
+
+func (e *sampleEncoder) encode(x float64) []byte {
+	e.count++
+	if xa := math.Abs(x); xa > e.worst {
+		e.worst = xa
+	}
+
+	le := binary.LittleEndian
+
+	if e.format.Float {
+		le.PutUint32(e.buf, math.Float32bits(float32(x)))
+		return e.buf[:4]
+	}
+
+	scale := math.Ldexp(1, e.format.BitsPerSample-1)
+	v := x * scale
+	if e.dither {
+		v += e.rng.Float64() - e.rng.Float64()
+	}
+	v = math.Floor(v + 0.5)
+	if v < -scale {
+		v = -scale
+	}
+	if v > scale-1 {
+		v = scale - 1
+	}
+
+	switch e.format.BitsPerSample {
+	case 16:
+		le.PutUint16(e.buf, uint16(int16(v)))
+		return e.buf[:2]
+	case 24:
+		u := uint32(int32(v))
+		e.buf[0], e.buf[1], e.buf[2] = byte(u), byte(u>>8), byte(u>>16)
+		return e.buf[:3]
+	default:
+		le.PutUint32(e.buf, uint32(int32(v)))
+		return e.buf[:4]
+	}
+}
+
+func writeSettingsFor(sampleRate int, opts []writeOption) (*writeSettings, error) {
+	settings := &writeSettings{
+		format: Format{
+			SampleRate:    sampleRate,
+			Channels:      1,
+			BitsPerSample: 32,
+		},
+		dither: true,
+	}
+	for _, opt := range opts {
+		opt.Apply(settings)
+	}
+	if err := settings.format.validate(); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// WriteFile writes samples to a WAV file. By default the file is mono with
+// 32-bit integer samples; the options select other formats.
+func WriteFile(filename string, sampleRate int, ch <-chan float64, opts ...writeOption) error {
+	settings, err := writeSettingsFor(sampleRate, opts)
+	if err != nil {
+		return err
+	}
+	format := settings.format
+
 	f, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
 
-	wavFile := &wav.File{
-		SampleRate:      uint32(sampleRate),
-		SignificantBits: 32,
-		Channels:        1,
+	wr := bufio.NewWriter(f)
+	if _, err := wr.Write(format.header(0)); err != nil {
+		f.Close()
+		return err
+	}
+
+	enc := newSampleEncoder(format, settings.dither)
+	var dataSize uint32
+
+	for x := range ch {
+		b := enc.encode(x)
+		if _, err := wr.Write(b); err != nil {
+			f.Close()
+			return err
+		}
+		dataSize += uint32(len(b))
+	}
+
+	if dataSize%2 == 1 {
+		if err := wr.WriteByte(0); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := wr.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	if _, err := f.Write(format.header(dataSize)); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	frames := enc.count / int64(format.Channels)
+	log.Printf("wrote WAV file %q (%d frames, %v seconds, largest: %v)", filename, frames, float64(frames)/float64(sampleRate), enc.worst)
+	if enc.worst > 1.0 && !format.Float {
+		return fmt.Errorf("wrote WAV file %q with clipping (%v > %v)", filename, enc.worst, 1.0)
 	}
-	wr, err := wavFile.NewWriter(f)
+
+	return nil
+}
+
+// WriteSamples is like WriteFile, but takes a buffer of samples.
+func WriteSamples(filename string, sampleRate int, samples []float64, opts ...writeOption) error {
+	f, err := os.Create(filename)
 	if err != nil {
+		return err
+	}
+
+	if err := Encode(f, sampleRate, samples, opts...); err != nil {
 		f.Close()
+		return fmt.Errorf("writing %q: %v", filename, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	log.Printf("wrote WAV file %q (%d samples)", filename, len(samples))
+	return nil
+}
+
+// Encode writes samples as a complete WAV file to a stream. Since the
+// header records the length of the data, all samples must be known up
+// front; the options are as for WriteFile.
+func Encode(w io.Writer, sampleRate int, samples []float64, opts ...writeOption) error {
+	settings, err := writeSettingsFor(sampleRate, opts)
+	if err != nil {
 		return err
 	}
+	format := settings.format
 
-	worst := float64(0.0)
+	dataSize := uint32(len(samples) * format.bytesPerSample())
 
-	var frames int64
+	wr := bufio.NewWriter(w)
+	if _, err := wr.Write(format.header(dataSize)); err != nil {
+		return err
+	}
 
-	for x := range ch {
-		frames++
-		xa := math.Abs(x)
-		if xa > worst {
-			worst = xa
+	enc := newSampleEncoder(format, settings.dither)
+	for _, x := range samples {
+		if _, err := wr.Write(enc.encode(x)); err != nil {
+			return err
 		}
-		val := int32(float64(2147483648) * x)
-		if err := wr.WriteInt32(val); err != nil {
-			f.Close()
+	}
+
+	if dataSize%2 == 1 {
+		if err := wr.WriteByte(0); err != nil {
 			return err
 		}
 	}
 
-	if err := wr.Close(); err != nil {
+	if err := wr.Flush(); err != nil {
 		return err
 	}
 
-	log.Printf("wrote WAV file %q (%d frames, %v seconds, largest: %v)", filename, frames, float64(frames)/float64(sampleRate), worst)
-	if worst > 1.0 {
-		return fmt.Errorf("wrote WAV file %q with clipping (%v > %v)", filename, worst, 1.0)
+	if enc.worst > 1.0 && !format.Float {
+		return fmt.Errorf("encoded WAV with clipping (%v > %v)", enc.worst, 1.0)
 	}
 
 	return nil
 }
+
+// FormatOptions returns the options selecting the given output format,
+// as specified e.g. by command-line flags.
+func FormatOptions(bitDepth int, float bool, channels int) []writeOption {
+	opts := []writeOption{BitDepth(bitDepth), Channels(channels)}
+	if float {
+		opts = append(opts, FloatSamples{})
+	}
+	return opts
+}
+
+// Duplicate turns a mono stream into an interleaved stream with the same
+// samples in each of the given number of channels.
+func Duplicate(ch <-chan float64, channels int) <-chan float64 {
+	if channels == 1 {
+		return ch
+	}
+
+	out := make(chan float64, 4096)
+	go func() {
+		for x := range ch {
+			for i := 0; i < channels; i++ {
+				out <- x
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+// DuplicateSlice is like Duplicate, but takes a buffer of samples.
+func DuplicateSlice(xs []float64, channels int) []float64 {
+	if channels == 1 {
+		return xs
+	}
+
+	rv := make([]float64, 0, len(xs)*channels)
+	for _, x := range xs {
+		for i := 0; i < channels; i++ {
+			rv = append(rv, x)
+		}
+	}
+	return rv
+}
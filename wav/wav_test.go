@@ -0,0 +1,120 @@
+package wav
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func feed(xs []float64) <-chan float64 {
+	ch := make(chan float64)
+	go func() {
+		for _, x := range xs {
+			ch <- x
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+func TestWriteFileRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	samples := []float64{0, 0.5, -0.5, 0.25, -1, 0.999, 0.125, -0.125, 0.75}
+
+	testcases := []struct {
+		name      string
+		opts      []writeOption
+		channels  int
+		tolerance float64
+	}{
+		{"default", nil, 1, 1e-9},
+		{"16-bit", []writeOption{BitDepth(16)}, 1, 2.0 / (1 << 15)},
+		{"16-bit undithered", []writeOption{BitDepth(16), NoDither{}}, 1, 1.0 / (1 << 15)},
+		{"24-bit", []writeOption{BitDepth(24)}, 1, 2.0 / (1 << 23)},
+		{"float", []writeOption{FloatSamples{}}, 1, 1e-7},
+		{"float stereo", []writeOption{FloatSamples{}, Channels(2)}, 2, 1e-7},
+		{"24-bit 3 channels", []writeOption{BitDepth(24), Channels(3)}, 3, 2.0 / (1 << 23)},
+	}
+
+	for _, tc := range testcases {
+		filename := filepath.Join(dir, tc.name+".wav")
+		if err := WriteFile(filename, 48000, feed(samples), tc.opts...); err != nil {
+			t.Errorf("%s: WriteFile() = %v", tc.name, err)
+			continue
+		}
+
+		f, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewReader(f)
+		if err != nil {
+			t.Errorf("%s: NewReader() = %v", tc.name, err)
+			f.Close()
+			continue
+		}
+
+		if r.Format().SampleRate != 48000 || r.Format().Channels != tc.channels {
+			t.Errorf("%s: Format() = %+v", tc.name, r.Format())
+		}
+
+		got := make([]float64, 100)
+		n, err := r.Read(got)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: Read() = %v", tc.name, err)
+			continue
+		}
+		got = got[:n]
+
+		wantLen := len(samples) / tc.channels * tc.channels
+		if len(got) != wantLen {
+			t.Errorf("%s: read %d samples, want %d", tc.name, len(got), wantLen)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-samples[i]) > tc.tolerance {
+				t.Errorf("%s: sample %d = %v, want %v", tc.name, i, got[i], samples[i])
+			}
+		}
+	}
+}
+
+func TestWriteFileOverRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "loud.wav")
+
+	if err := WriteFile(filename, 8000, feed([]float64{1.5, -2})); err == nil {
+		t.Errorf("WriteFile() of over-range integer samples succeeded, want error")
+	}
+
+	if err := WriteFile(filename, 8000, feed([]float64{1.5, -2}), FloatSamples{}); err != nil {
+		t.Errorf("WriteFile() of over-range float samples = %v", err)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]float64, 2)
+	if _, err := r.Read(got); err != nil || got[0] != 1.5 || got[1] != -2 {
+		t.Errorf("Read() = %v, %v; want [1.5 -2]", got, err)
+	}
+}